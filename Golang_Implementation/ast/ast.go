@@ -4,6 +4,7 @@ package ast
 
 import (
 	"bytes"
+	"strings"
 
 	"../token"
 )
@@ -12,6 +13,8 @@ import (
 type Node interface {
 	TokenLiteral() string //string literal of tokens that comprise the node
 	String() string       //allow us to print AST nodes for debugging and to compare them with other AST nodes
+	Pos() token.Position  //where the first character of the node is in the source
+	End() token.Position  //just past the last character of the node
 }
 
 // All statement nodes implement this
@@ -26,6 +29,12 @@ type Expression interface {
 	expressionNode()
 }
 
+// All type annotations implement this (ie the 'int' in 'let int x := 3')
+type TypeNode interface {
+	Node
+	typeNode()
+}
+
 //list of parsed statements that make was made up of the inputted program
 type Program struct {
 	Statements []Statement
@@ -39,6 +48,20 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string { //creates a buffer and writes the return value of each statements' String() method to it, then it returns the buffer as a string
 	var out bytes.Buffer
 
@@ -52,18 +75,22 @@ func (p *Program) String() string { //creates a buffer and writes the return val
 //%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Statement structs (and their methods to fulfill the node and statement interfaces)
 type LetStatement struct {
 	Token token.Token // the token.LET token
-	Name  *Identifier //label in assignment (ie the 'x' in 'let x = 2;')
-	Value Expression  //value in assignment (ie the '2' in 'let x = 2;' or the 'add(x,y)' in 'let z = add(x,y);')
+	Type  TypeNode    //declared type of the variable (ie the 'int' in 'let int x := 2;')
+	Name  *Identifier //label in assignment (ie the 'x' in 'let int x := 2;')
+	Value Expression  //value in assignment (ie the '2' in 'let int x := 2;' or the 'add(x,y)' in 'let int z := add(x,y);')
 }
 
 func (ls *LetStatement) statementNode()       {}                          //Creates statement node
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal } //returns the string literal of the token.LET token
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position  { return ls.Value.End() }
 func (ls *LetStatement) String() string { //Creates string containing full let statement
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
+	out.WriteString(ls.Type.String() + " ")
 	out.WriteString(ls.Name.String())
-	out.WriteString(" = ")
+	out.WriteString(" := ")
 
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String())
@@ -74,6 +101,33 @@ func (ls *LetStatement) String() string { //Creates string containing full let s
 	return out.String()
 }
 
+type SetStatement struct {
+	Token    token.Token // the token.SET token
+	Target   Expression  //what is being updated: an already declared variable (ie the 'x' in 'set x :+ 3;'), or an element or field of one (ie 'm["k"]' or 'p.x')
+	Operator string      //the update operator (one of := :+ :- :* :/)
+	Value    Expression  //value used in the update (ie the '3' in 'set x :+ 3;')
+}
+
+func (ss *SetStatement) statementNode()       {}
+func (ss *SetStatement) TokenLiteral() string { return ss.Token.Literal } //returns the string literal of the token.SET token
+func (ss *SetStatement) Pos() token.Position  { return ss.Token.Pos }
+func (ss *SetStatement) End() token.Position  { return ss.Value.End() }
+func (ss *SetStatement) String() string { //Creates string containing full set statement
+	var out bytes.Buffer
+
+	out.WriteString(ss.TokenLiteral() + " ")
+	out.WriteString(ss.Target.String())
+	out.WriteString(" " + ss.Operator + " ")
+
+	if ss.Value != nil {
+		out.WriteString(ss.Value.String())
+	}
+
+	out.WriteString(";")
+
+	return out.String()
+}
+
 type ReturnStatement struct {
 	Token       token.Token // the 'return' token
 	ReturnValue Expression  // contains the expression that’s to be returned
@@ -81,6 +135,13 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}                          //creates return statement node (statement interface)
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal } //returns the string literal of the token.RETURN token
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position {
+	if rs.ReturnValue != nil {
+		return rs.ReturnValue.End()
+	}
+	return rs.Token.End //a bare return
+}
 func (rs *ReturnStatement) String() string { //Creates string containing full return statement
 	var out bytes.Buffer
 
@@ -102,6 +163,13 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExpressionStatement) End() token.Position {
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+	return es.Token.End
+}
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -112,10 +180,13 @@ func (es *ExpressionStatement) String() string {
 type BlockStatement struct {
 	Token      token.Token // the { token
 	Statements []Statement
+	Rbrace     token.Token // the } token
 }
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position  { return bs.Rbrace.End }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -126,8 +197,242 @@ func (bs *BlockStatement) String() string {
 	return out.String()
 }
 
+type KeyStatement struct { //SquidScript's take on a switch: the keys are tested against each lock until one unlocks
+	Token token.Token     // the 'key' token
+	Keys  []Expression    //the expressions between the parentheses (ie the 'x, y' in 'key (x, y) {...}')
+	Locks []*LockClause   //every 'lock <values> {...}' in order
+	Else  *BlockStatement //body of the optional 'lock else {...}' that runs when nothing else unlocked
+
+	ElseToken token.Token // the 'lock' token of the lock else (so the comments in front of it aren't lost)
+
+	Rbrace token.Token // the } that closes the key statement
+}
+
+func (ks *KeyStatement) statementNode()       {}
+func (ks *KeyStatement) TokenLiteral() string { return ks.Token.Literal }
+func (ks *KeyStatement) Pos() token.Position  { return ks.Token.Pos }
+func (ks *KeyStatement) End() token.Position  { return ks.Rbrace.End }
+func (ks *KeyStatement) String() string {
+	var out bytes.Buffer
+
+	keys := []string{}
+	for _, k := range ks.Keys {
+		keys = append(keys, k.String())
+	}
+
+	out.WriteString("key (")
+	out.WriteString(strings.Join(keys, ", "))
+	out.WriteString(") {")
+
+	for _, lc := range ks.Locks {
+		out.WriteString(lc.String())
+	}
+
+	if ks.Else != nil {
+		out.WriteString("lock else {")
+		out.WriteString(ks.Else.String())
+		out.WriteString("}")
+	}
+
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type LockClause struct { //one 'lock <values> {...}' inside of a key statement
+	Token  token.Token     // the 'lock' token
+	Values []Expression    //either one value per key, or a single condition (ie the 'x > y' in 'lock x > y {...}')
+	Body   *BlockStatement //what runs when this lock unlocks
+}
+
+func (lc *LockClause) TokenLiteral() string { return lc.Token.Literal }
+func (lc *LockClause) Pos() token.Position  { return lc.Token.Pos }
+func (lc *LockClause) End() token.Position  { return lc.Body.End() }
+func (lc *LockClause) String() string {
+	var out bytes.Buffer
+
+	values := []string{}
+	for _, v := range lc.Values {
+		values = append(values, v.String())
+	}
+
+	out.WriteString("lock ")
+	out.WriteString(strings.Join(values, ", "))
+	out.WriteString(" {")
+	out.WriteString(lc.Body.String())
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type ForInStatement struct { //for <variable> in <iterable> {<body>} or for <variable>, <value> in <iterable> {<body>}
+	Token    token.Token     // the 'for' token
+	Variable *Identifier     //freshly bound to each element for every iteration (ie the 'num' in 'for num in 1..100'), or each key of a map
+	Value    *Identifier     //nil unless there are two variables (ie the 'v' in 'for k, v in m', or the 'x' in 'for i, x in xs')
+	Iterable Expression      //what we are looping over (ie the '1..100' in 'for num in 1..100')
+	Body     *BlockStatement //what runs for every element
+}
+
+func (fs *ForInStatement) statementNode()       {}
+func (fs *ForInStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForInStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *ForInStatement) End() token.Position  { return fs.Body.End() }
+func (fs *ForInStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for ")
+	out.WriteString(fs.Variable.String())
+	if fs.Value != nil {
+		out.WriteString(", " + fs.Value.String())
+	}
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(" {")
+	out.WriteString(fs.Body.String())
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type StructDeclaration struct { //struct <name> { <type> <field>; <type> <field>; ... }
+	Token  token.Token // the 'struct' token
+	Name   *Identifier //name of the new type
+	Fields []*Field    //the fields every value of the type has, in order
+	Rbrace token.Token // the '}' token
+}
+
+type Field struct { //a typed field of a struct (ie the 'int x' in 'struct Point { int x; int y }')
+	Type TypeNode
+	Name *Identifier
+}
+
+func (sd *StructDeclaration) statementNode()       {}
+func (sd *StructDeclaration) TokenLiteral() string { return sd.Token.Literal }
+func (sd *StructDeclaration) Pos() token.Position  { return sd.Token.Pos }
+func (sd *StructDeclaration) End() token.Position  { return sd.Rbrace.End }
+func (sd *StructDeclaration) String() string {
+	var out bytes.Buffer
+
+	fields := []string{}
+	for _, f := range sd.Fields {
+		fields = append(fields, f.Type.String()+" "+f.Name.String())
+	}
+
+	out.WriteString("struct ")
+	out.WriteString(sd.Name.String())
+	out.WriteString(" {")
+	out.WriteString(strings.Join(fields, "; "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type EnumDeclaration struct { //enum <name> { <variant>, <variant>, ... }
+	Token    token.Token   // the 'enum' token
+	Name     *Identifier   //name of the new type
+	Variants []*Identifier //every value of the type, in order (ie the Jan in 'enum Month { Jan, Feb }', which is used as Month.Jan)
+	Rbrace   token.Token   // the '}' token
+}
+
+func (ed *EnumDeclaration) statementNode()       {}
+func (ed *EnumDeclaration) TokenLiteral() string { return ed.Token.Literal }
+func (ed *EnumDeclaration) Pos() token.Position  { return ed.Token.Pos }
+func (ed *EnumDeclaration) End() token.Position  { return ed.Rbrace.End }
+func (ed *EnumDeclaration) String() string {
+	var out bytes.Buffer
+
+	variants := []string{}
+	for _, v := range ed.Variants {
+		variants = append(variants, v.String())
+	}
+
+	out.WriteString("enum ")
+	out.WriteString(ed.Name.String())
+	out.WriteString(" {")
+	out.WriteString(strings.Join(variants, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type WhileStatement struct { //while <condition> {<body>}
+	Token     token.Token     // the 'while' token
+	Condition Expression      //checked before every iteration, the loop ends once it is false
+	Body      *BlockStatement //what runs while the condition holds
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Pos }
+func (ws *WhileStatement) End() token.Position  { return ws.Body.End() }
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while ")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" {")
+	out.WriteString(ws.Body.String())
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type BreakStatement struct { //leaves the innermost loop
+	Token token.Token // the 'break' token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BreakStatement) End() token.Position  { return bs.Token.End }
+func (bs *BreakStatement) String() string       { return bs.Token.Literal + ";" }
+
+type ContinueStatement struct { //skips to the next iteration of the innermost loop
+	Token token.Token // the 'continue' token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ContinueStatement) End() token.Position  { return cs.Token.End }
+func (cs *ContinueStatement) String() string       { return cs.Token.Literal + ";" }
+
+type FunctionDeclaration struct { //func <name>(<parameters>) returns <type> {<body>}
+	Token    token.Token      // the 'func' token
+	Name     *Identifier      //the name the function gets bound to (ie the 'add' in 'func add(int x, int y) returns int {...}')
+	Function *FunctionLiteral //everything else about the function
+}
+
+func (fd *FunctionDeclaration) statementNode()       {}
+func (fd *FunctionDeclaration) TokenLiteral() string { return fd.Token.Literal }
+func (fd *FunctionDeclaration) Pos() token.Position  { return fd.Token.Pos }
+func (fd *FunctionDeclaration) End() token.Position  { return fd.Function.End() }
+func (fd *FunctionDeclaration) String() string       { return fd.Function.String() }
+
+type BadStatement struct { //placeholder for a statement that couldn't be parsed, so the rest of the program can still be worked with
+	From token.Token // the first token of the broken statement
+	To   token.Token // the last token that was skipped over
+}
+
+func (bs *BadStatement) statementNode()       {}
+func (bs *BadStatement) TokenLiteral() string { return bs.From.Literal }
+func (bs *BadStatement) Pos() token.Position  { return bs.From.Pos }
+func (bs *BadStatement) End() token.Position  { return bs.To.End }
+func (bs *BadStatement) String() string       { return "<bad statement>" }
+
 //%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Expression structs (and their methods to fulfill the node and expression interfaces)
 
+type BadExpression struct { //placeholder for an expression that couldn't be parsed
+	From token.Token // the first token of the broken expression
+	To   token.Token // the last token of the broken expression
+}
+
+func (be *BadExpression) expressionNode()      {}
+func (be *BadExpression) TokenLiteral() string { return be.From.Literal }
+func (be *BadExpression) Pos() token.Position  { return be.From.Pos }
+func (be *BadExpression) End() token.Position  { return be.To.End }
+func (be *BadExpression) String() string       { return "<bad expression>" }
+
 type Identifier struct { //For Identifier nodes
 	Token token.Token // the token.IDENT token
 	Value string      //string literal of inputted code
@@ -135,6 +440,8 @@ type Identifier struct { //For Identifier nodes
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
+func (i *Identifier) End() token.Position  { return i.Token.End }
 func (i *Identifier) String() string       { return i.Value }
 
 type Boolean struct {
@@ -144,6 +451,8 @@ type Boolean struct {
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
+func (b *Boolean) End() token.Position  { return b.Token.End }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
 type IntegerLiteral struct { //for int nodes
@@ -153,8 +462,57 @@ type IntegerLiteral struct { //for int nodes
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position  { return il.Token.End }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+type FloatLiteral struct { //for float nodes
+	Token token.Token // the token.FLOAT token
+	Value float64     //contains the actual value the float literal represents in the source code
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) End() token.Position  { return fl.Token.End }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
+type StringLiteral struct { //for string nodes without any interpolation (and for the text between the interpolations of one that has some)
+	Token token.Token // the token.STRING token
+	Value string      //the text of the string, with its escapes decoded
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position  { return sl.Token.End }
+func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+
+type InterpolatedString struct { //for strings with ${expression}s in them (ie "hello ${name}!")
+	Token token.Token  // the token.STRING token
+	Parts []Expression //the text (as *StringLiterals) and the interpolated expressions, in the order they appear in the string
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Pos() token.Position  { return is.Token.Pos }
+func (is *InterpolatedString) End() token.Position  { return is.Token.End }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("\"")
+	for _, part := range is.Parts {
+		if text, ok := part.(*StringLiteral); ok { //the literal of a piece of text is its source with quotes around it
+			out.WriteString(text.Token.Literal[1 : len(text.Token.Literal)-1])
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+	out.WriteString("\"")
+
+	return out.String()
+}
+
 type PrefixExpression struct { // for prefix expression nodes (only two in this language)
 	Token    token.Token // The prefix token (either - or !)
 	Operator string      //which of the two operators is it, bang or neg?
@@ -163,6 +521,8 @@ type PrefixExpression struct { // for prefix expression nodes (only two in this
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position  { return pe.Right.End() }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -183,6 +543,8 @@ type InfixExpression struct { // for infix expression nodes
 
 func (oe *InfixExpression) expressionNode()      {}
 func (oe *InfixExpression) TokenLiteral() string { return oe.Token.Literal }
+func (oe *InfixExpression) Pos() token.Position  { return oe.Left.Pos() }
+func (oe *InfixExpression) End() token.Position  { return oe.Right.End() }
 func (oe *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -195,6 +557,225 @@ func (oe *InfixExpression) String() string {
 	return out.String()
 }
 
+type RangeExpression struct { //first..last, which is INCLUSIVE of both ends (ie 1..3 is 1, 2 and 3)
+	Token token.Token // the .. token
+	First Expression  //first number in the range
+	Last  Expression  //last number in the range
+}
+
+func (re *RangeExpression) expressionNode()      {}
+func (re *RangeExpression) TokenLiteral() string { return re.Token.Literal }
+func (re *RangeExpression) Pos() token.Position  { return re.First.Pos() }
+func (re *RangeExpression) End() token.Position  { return re.Last.End() }
+func (re *RangeExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(re.First.String())
+	out.WriteString("..")
+	out.WriteString(re.Last.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type ArrayLiteral struct { //[<element>, <element>, ...]
+	Token    token.Token  // the '[' token
+	Elements []Expression //the values in the array in order
+	Rbracket token.Token  // the ']' token
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position  { return al.Rbracket.End }
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type HashLiteral struct { //{<key>: <value>, <key>: <value>, ...}
+	Token  token.Token // the '{' token
+	Pairs  []HashPair  //the keys and values in the order they were written
+	Rbrace token.Token // the '}' token
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position  { return hl.Rbrace.End }
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type StructLiteral struct { //<name> {<field>: <value>, <field>: <value>, ...}
+	Name   *Identifier  //the struct type being constructed
+	Fields []FieldValue //the fields in the order they were written
+	Rbrace token.Token  // the '}' token
+}
+
+type FieldValue struct {
+	Name  *Identifier
+	Value Expression
+}
+
+func (sl *StructLiteral) expressionNode()      {}
+func (sl *StructLiteral) TokenLiteral() string { return sl.Name.TokenLiteral() }
+func (sl *StructLiteral) Pos() token.Position  { return sl.Name.Pos() }
+func (sl *StructLiteral) End() token.Position  { return sl.Rbrace.End }
+func (sl *StructLiteral) String() string {
+	var out bytes.Buffer
+
+	fields := []string{}
+	for _, f := range sl.Fields {
+		fields = append(fields, f.Name.String()+": "+f.Value.String())
+	}
+
+	out.WriteString(sl.Name.String())
+	out.WriteString("{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type SelectorExpression struct { //<left>.<field> (ie p.x)
+	Token token.Token // the '.' token
+	Left  Expression  //the struct whose field is selected
+	Field *Identifier //name of the field
+}
+
+func (se *SelectorExpression) expressionNode()      {}
+func (se *SelectorExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SelectorExpression) Pos() token.Position  { return se.Left.Pos() }
+func (se *SelectorExpression) End() token.Position  { return se.Field.End() }
+func (se *SelectorExpression) String() string {
+	return "(" + se.Left.String() + "." + se.Field.String() + ")"
+}
+
+type IndexExpression struct { //<left>[<index>]. When the index is a range it is a slice instead (ie xs[1..3] is elements 1 through 3). Maps are indexed by key
+	Token    token.Token // the '[' token
+	Left     Expression  //what is being indexed
+	Index    Expression  //which element (or range of elements) to take
+	Rbracket token.Token // the ']' token
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *IndexExpression) End() token.Position  { return ie.Rbracket.End }
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+type Parameter struct { //a typed function parameter (ie the 'int x' in 'func add(int x, int y)')
+	Type TypeNode
+	Name *Identifier
+}
+
+func (pm *Parameter) TokenLiteral() string { return pm.Name.TokenLiteral() }
+func (pm *Parameter) Pos() token.Position  { return pm.Type.Pos() }
+func (pm *Parameter) End() token.Position  { return pm.Name.End() }
+func (pm *Parameter) String() string       { return pm.Type.String() + " " + pm.Name.String() }
+
+type FunctionLiteral struct {
+	Token      token.Token     // the 'func' token
+	Name       string          //the name of the function if it was declared with one, empty for anonymous functions
+	Parameters []*Parameter    //typed parameters in order
+	ReturnType TypeNode        //the type after 'returns', nil when the function doesn't return anything
+	Body       *BlockStatement //the code that runs when the function is called
+}
+
+func (fl *FunctionLiteral) expressionNode()      {}
+func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position  { return fl.Body.End() }
+func (fl *FunctionLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range fl.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(fl.TokenLiteral())
+	if fl.Name != "" {
+		out.WriteString(" " + fl.Name)
+	}
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	if fl.ReturnType != nil {
+		out.WriteString("returns " + fl.ReturnType.String() + " ")
+	}
+	out.WriteString("{")
+	out.WriteString(fl.Body.String())
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type CallExpression struct {
+	Token     token.Token  // The '(' token
+	Function  Expression   // Identifier or FunctionLiteral
+	Arguments []Expression //the values passed in for the parameters
+	Rparen    token.Token  // the ')' token
+}
+
+func (ce *CallExpression) expressionNode()      {}
+func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return ce.Function.Pos() }
+func (ce *CallExpression) End() token.Position  { return ce.Rparen.End }
+func (ce *CallExpression) String() string {
+	var out bytes.Buffer
+
+	args := []string{}
+	for _, a := range ce.Arguments {
+		args = append(args, a.String())
+	}
+
+	out.WriteString(ce.Function.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
 type IfExpression struct {
 	Token       token.Token     // The 'if' token
 	Condition   Expression      //holds the condition, which can be any expression
@@ -204,6 +785,13 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	return ie.Consequence.End()
+}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -219,3 +807,73 @@ func (ie *IfExpression) String() string {
 
 	return out.String()
 }
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Type structs (and their methods to fulfill the node and type interfaces)
+
+type NamedType struct { //a type referred to by name (ie int or bool)
+	Token token.Token // the token.IDENT token
+	Name  string      //name of the type
+}
+
+func (nt *NamedType) typeNode()            {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) Pos() token.Position  { return nt.Token.Pos }
+func (nt *NamedType) End() token.Position  { return nt.Token.End }
+func (nt *NamedType) String() string       { return nt.Name }
+
+type ArrayType struct { //the type of an array (ie 'int[]', or 'int[][]' for an array of arrays)
+	Element  TypeNode    //the type of every element
+	Rbracket token.Token // the ']' token
+}
+
+func (at *ArrayType) typeNode()            {}
+func (at *ArrayType) TokenLiteral() string { return at.Element.TokenLiteral() }
+func (at *ArrayType) Pos() token.Position  { return at.Element.Pos() }
+func (at *ArrayType) End() token.Position  { return at.Rbracket.End }
+func (at *ArrayType) String() string       { return at.Element.String() + "[]" }
+
+type MapType struct { //the type of a map (ie 'map[string]int')
+	Token token.Token // the 'map' token
+	Key   TypeNode    //the type of every key
+	Value TypeNode    //the type of every value
+}
+
+func (mt *MapType) typeNode()            {}
+func (mt *MapType) TokenLiteral() string { return mt.Token.Literal }
+func (mt *MapType) Pos() token.Position  { return mt.Token.Pos }
+func (mt *MapType) End() token.Position  { return mt.Value.End() }
+func (mt *MapType) String() string       { return "map[" + mt.Key.String() + "]" + mt.Value.String() }
+
+type FunctionType struct { //the type of a function (ie 'func(int, int) returns int')
+	Token      token.Token // the 'func' token
+	Parameters []TypeNode  //types of the parameters in order
+	ReturnType TypeNode    //nil when the function doesn't return anything
+	Rparen     token.Token // the ')' after the parameter types
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) Pos() token.Position  { return ft.Token.Pos }
+func (ft *FunctionType) End() token.Position {
+	if ft.ReturnType != nil {
+		return ft.ReturnType.End()
+	}
+	return ft.Rparen.End
+}
+func (ft *FunctionType) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range ft.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("func(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	if ft.ReturnType != nil {
+		out.WriteString(" returns " + ft.ReturnType.String())
+	}
+
+	return out.String()
+}
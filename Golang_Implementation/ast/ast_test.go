@@ -11,6 +11,10 @@ func TestString(t *testing.T) {
 		Statements: []Statement{
 			&LetStatement{
 				Token: token.Token{Type: token.LET, Literal: "let"},
+				Type: &NamedType{
+					Token: token.Token{Type: token.IDENT, Literal: "int"},
+					Name:  "int",
+				},
 				Name: &Identifier{
 					Token: token.Token{Type: token.IDENT, Literal: "myVar"},
 					Value: "myVar",
@@ -23,7 +27,7 @@ func TestString(t *testing.T) {
 		},
 	}
 
-	if program.String() != "let myVar = anotherVar;" {
+	if program.String() != "let int myVar := anotherVar;" {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
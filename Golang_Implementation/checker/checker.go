@@ -0,0 +1,987 @@
+//OVERVIEW: The checker walks the AST produced by the parser before it is evaluated. It resolves the declared types of variables and functions,
+//infers the type of every expression and reports every place where the two don't line up, so type errors are caught before any code runs
+package checker
+
+import (
+	"fmt"
+	"strings"
+
+	"../ast"
+	"../diagnostic"
+	"../types"
+)
+
+//This is what is constructed; the main template/structure of the checker
+type Checker struct {
+	scope       *Scope          //where names are currently being declared and looked up
+	diagnostics diagnostic.List //Contains errors we have seen
+
+	returns []types.Type //the return types of the functions we are currently inside of, innermost last
+}
+
+//REQUIRES:
+//MODIFIES:
+//EFFECTS: creates a checker with an empty outermost scope (the builtins live in a scope around it, so a program can shadow them)
+func New() *Checker { //serves as a checker constructor
+	builtins := NewScope(nil)
+	for _, name := range []string{"print", "len", "int", "float", "args"} {
+		builtins.Declare(name, &types.Builtin{Name: name})
+	}
+	return &Checker{scope: NewScope(builtins)}
+}
+
+//REQUIRES: a parsed program
+//MODIFIES: the outermost scope of the checker, but only if program had no type errors (so the REPL doesn't remember declarations that never ran)
+//EFFECTS: type checks every statement in program and returns the problems that were found
+func (c *Checker) Check(program *ast.Program) diagnostic.List {
+	c.diagnostics = diagnostic.List{}
+
+	global := c.scope
+	c.scope = NewScope(global) //declarations go here first, and only make it into the outermost scope if everything checked out
+
+	for _, stmt := range program.Statements {
+		c.checkStatement(stmt)
+	}
+
+	checked := c.scope
+	c.scope = global
+
+	if !c.diagnostics.HasErrors() {
+		for name, t := range checked.names {
+			global.Declare(name, t)
+		}
+		for name, t := range checked.typeNames {
+			global.DeclareType(name, t)
+		}
+	}
+
+	return c.diagnostics
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Statements
+
+func (c *Checker) checkStatement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		declared := c.resolveType(stmt.Type)
+		value := c.checkExpression(stmt.Value)
+		c.expectAssignable(stmt.Value, value, declared, "declaration of "+stmt.Name.Value)
+		c.declare(stmt.Name, declared)
+
+	case *ast.SetStatement:
+		c.checkSetStatement(stmt)
+
+	case *ast.ReturnStatement:
+		c.checkReturnStatement(stmt)
+
+	case *ast.ExpressionStatement:
+		c.checkExpression(stmt.Expression)
+
+	case *ast.KeyStatement:
+		c.checkKeyStatement(stmt)
+
+	case *ast.ForInStatement:
+		iterable := c.checkExpression(stmt.Iterable)
+		variable, value := c.loopVariableTypes(stmt, iterable)
+
+		c.checkBlock(stmt.Body, func() {
+			c.declare(stmt.Variable, variable)
+			if stmt.Value != nil {
+				c.declare(stmt.Value, value)
+			}
+		})
+
+	case *ast.WhileStatement:
+		condition := c.checkExpression(stmt.Condition)
+		c.expectCondition(stmt.Condition, condition, "while")
+		c.checkBlock(stmt.Body, nil)
+
+	case *ast.FunctionDeclaration:
+		fn := c.functionType(stmt.Function)
+		c.declare(stmt.Name, fn) //declared before the body is checked, so the function can call itself
+		c.checkFunctionBody(stmt.Function, fn)
+
+	case *ast.StructDeclaration:
+		c.checkStructDeclaration(stmt)
+
+	case *ast.EnumDeclaration:
+		en := &types.Enum{Name: stmt.Name.Value}
+		for _, v := range stmt.Variants {
+			if en.HasVariant(v.Value) {
+				c.errorAt(diagnostic.DuplicateVariant, diagnostic.NodeSpan(v), "duplicate variant %s in enum %s", v.Value, en.Name)
+				continue
+			}
+			en.Variants = append(en.Variants, v.Value)
+		}
+		c.declareType(stmt.Name, en)
+	}
+}
+
+//declares a variable or function in the current scope. Its name can't be the name of a type as well, since then 'Month.Feb' would be
+//ambiguous
+func (c *Checker) declare(name *ast.Identifier, t types.Type) {
+	if _, ok := c.scope.LookupType(name.Value); ok {
+		c.errorAt(diagnostic.NameClash, diagnostic.NodeSpan(name), "%s is already declared as a type", name.Value)
+	}
+	c.scope.Declare(name.Value, t)
+}
+
+//declares a struct or enum in the current scope, the same way declare does for variables (builtins can still be shadowed by a type though)
+func (c *Checker) declareType(name *ast.Identifier, t types.Type) {
+	if existing, ok := c.scope.Lookup(name.Value); ok {
+		if _, builtin := existing.(*types.Builtin); !builtin {
+			c.errorAt(diagnostic.NameClash, diagnostic.NodeSpan(name), "%s is already declared as a variable", name.Value)
+		}
+	}
+	c.scope.DeclareType(name.Value, t)
+}
+
+func (c *Checker) checkStructDeclaration(sd *ast.StructDeclaration) {
+	st := &types.Struct{Name: sd.Name.Value}
+	c.declareType(sd.Name, st) //declared before the fields are resolved, so a field can hold more of the same struct (ie 'Node[] children')
+
+	for _, f := range sd.Fields {
+		t := c.resolveType(f.Type)
+		if _, ok := st.Field(f.Name.Value); ok {
+			c.errorAt(diagnostic.DuplicateField, diagnostic.NodeSpan(f.Name), "duplicate field %s in struct %s", f.Name.Value, st.Name)
+			continue
+		}
+		st.Fields = append(st.Fields, types.Field{Name: f.Name.Value, Type: t})
+	}
+}
+
+func (c *Checker) checkSetStatement(stmt *ast.SetStatement) {
+	current := c.setTargetType(stmt.Target)
+	value := c.checkExpression(stmt.Value)
+	if current == types.Invalid {
+		return
+	}
+
+	if stmt.Operator == ":=" {
+		c.expectAssignable(stmt.Value, value, current, "assignment to "+stmt.Target.String())
+		return
+	}
+
+	operator := stmt.Operator[1:] //:+ applies +, :- applies - and so on
+	result := c.binaryType(stmt, operator, current, value)
+	c.expectAssignable(stmt.Value, result, current, "assignment to "+stmt.Target.String())
+}
+
+//returns the type of what a set statement updates: a variable, or an element of one
+func (c *Checker) setTargetType(target ast.Expression) types.Type {
+	switch target := target.(type) {
+	case *ast.Identifier:
+		t, ok := c.scope.Lookup(target.Value)
+		if !ok { //mutation has to be explicit, so the variable must have been declared with let first
+			c.diagnostics.Add(diagnostic.Diagnostic{
+				Severity: diagnostic.Error,
+				Code:     diagnostic.Undefined,
+				Message:  "cannot set undeclared variable: " + target.Value,
+				Span:     diagnostic.NodeSpan(target),
+				Notes:    []string{"variables have to be declared with let before they can be set"},
+			})
+			return types.Invalid
+		}
+		return t
+
+	case *ast.IndexExpression:
+		left := c.checkExpression(target.Left)
+		index := c.checkExpression(target.Index)
+		if _, ok := left.(*types.Array); ok && index == types.Range {
+			c.errorAt(diagnostic.InvalidOperation, diagnostic.NodeSpan(target), "cannot set a slice, only single elements")
+			return types.Invalid
+		}
+		return c.indexType(target, left, index)
+
+	case *ast.SelectorExpression:
+		if en := c.enumOf(target.Left); en != nil {
+			c.errorAt(diagnostic.InvalidOperation, diagnostic.NodeSpan(target), "cannot set %s.%s, the variants of an enum can't be changed", en, target.Field.Value)
+			return types.Invalid
+		}
+		return c.selectorType(target, c.checkExpression(target.Left))
+
+	default:
+		return types.Invalid
+	}
+}
+
+func (c *Checker) checkReturnStatement(stmt *ast.ReturnStatement) {
+	var value types.Type = types.Void
+	if stmt.ReturnValue != nil {
+		value = c.checkExpression(stmt.ReturnValue)
+	}
+
+	if len(c.returns) == 0 { //returning from the program itself can hand back anything
+		return
+	}
+	expected := c.returns[len(c.returns)-1]
+
+	switch {
+	case expected == types.Void && stmt.ReturnValue != nil:
+		c.errorAt(diagnostic.InvalidReturn, diagnostic.NodeSpan(stmt), "cannot return a value from a function without a return type")
+	case expected != types.Void && stmt.ReturnValue == nil:
+		c.errorAt(diagnostic.InvalidReturn, diagnostic.NodeSpan(stmt), "missing return value, want %s", expected)
+	default:
+		c.expectAssignable(stmt.ReturnValue, value, expected, "return")
+	}
+}
+
+func (c *Checker) checkKeyStatement(stmt *ast.KeyStatement) {
+	keys := []types.Type{}
+	for _, k := range stmt.Keys {
+		keys = append(keys, c.checkExpression(k))
+	}
+
+	for _, lock := range stmt.Locks {
+		values := []types.Type{}
+		for _, v := range lock.Values {
+			values = append(values, c.checkExpression(v))
+		}
+
+		if len(values) == 1 && len(keys) > 1 { //a single condition tested against all of the keys
+			c.expectCondition(lock.Values[0], values[0], "lock")
+		} else {
+			for i, value := range values {
+				if value == types.Invalid || keys[i] == types.Invalid {
+					continue
+				}
+				if value == types.Bool && keys[i] != types.Bool { //a condition in the position of this key
+					continue
+				}
+				if !types.Identical(value, keys[i]) {
+					c.errorAt(diagnostic.TypeMismatch, diagnostic.NodeSpan(lock.Values[i]), "lock value of type %s does not match key of type %s", value, keys[i])
+				}
+			}
+		}
+
+		c.checkBlock(lock.Body, nil)
+	}
+
+	if stmt.Else != nil {
+		c.checkBlock(stmt.Else, nil)
+	} else {
+		c.checkExhaustive(stmt, keys)
+	}
+}
+
+//warns about every key over an enum value when the statement has no lock else and some variants of the enum are never in the position of
+//that key, since then those values unlock nothing. With several keys a variant counts as covered as soon as one lock has it, whatever
+//the rest of that lock is, so only variants no lock mentions are warned about. A lock on a variant (ie 'lock Month.Feb') covers it and so
+//does a condition comparing the key with it (ie 'lock m = Month.Feb'), any other condition can't be known before the program runs
+func (c *Checker) checkExhaustive(stmt *ast.KeyStatement, keys []types.Type) {
+	for i, key := range keys {
+		en, ok := key.(*types.Enum)
+		if !ok {
+			continue
+		}
+
+		covered := map[string]bool{}
+		for _, lock := range stmt.Locks {
+			value := lock.Values[0] //a single condition tested against all of the keys, unless there is a value for every key
+			if len(lock.Values) == len(keys) {
+				value = lock.Values[i]
+			}
+			if v := c.lockedVariant(value, stmt.Keys[i], en); v != "" {
+				covered[v] = true
+			}
+		}
+
+		missing := []string{}
+		for _, v := range en.Variants {
+			if !covered[v] {
+				missing = append(missing, en.Name+"."+v)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		c.diagnostics.Add(diagnostic.Diagnostic{
+			Severity: diagnostic.Warning,
+			Code:     diagnostic.NonExhaustiveKey,
+			Message:  fmt.Sprintf("key over %s is missing locks for %s", en, strings.Join(missing, ", ")),
+			Span:     diagnostic.NodeSpan(stmt.Keys[i]),
+			Notes:    []string{"when no lock unlocks, nothing runs. Add a lock for each of them, or a lock else"},
+		})
+	}
+}
+
+//returns the variant of en that a lock value unlocks key for: the variant itself (ie Feb for 'Month.Feb'), or the variant a condition
+//compares key with (ie Feb for 'm = Month.Feb' when key is m). Keys are matched by their source, which is good enough for the names and
+//fields they usually are. Returns "" for any other value
+func (c *Checker) lockedVariant(value, key ast.Expression, en *types.Enum) string {
+	if se, ok := value.(*ast.SelectorExpression); ok && c.enumOf(se.Left) == en {
+		return se.Field.Value
+	}
+
+	ie, ok := value.(*ast.InfixExpression)
+	if !ok || ie.Operator != "=" {
+		return ""
+	}
+	for _, sides := range [][2]ast.Expression{{ie.Left, ie.Right}, {ie.Right, ie.Left}} {
+		se, ok := sides[1].(*ast.SelectorExpression)
+		if ok && sides[0].String() == key.String() && c.enumOf(se.Left) == en {
+			return se.Field.Value
+		}
+	}
+	return ""
+}
+
+//REQUIRES: a block and a function that declares anything that should be in scope for the block (nil if there is nothing)
+//MODIFIES:
+//EFFECTS: checks every statement of the block in a new scope and returns the type of the block, which is the type of its last statement
+//when that is an expression (ie the int in 'if (x) { 1 }'), and void otherwise
+func (c *Checker) checkBlock(block *ast.BlockStatement, declare func()) types.Type {
+	outer := c.scope
+	c.scope = NewScope(outer)
+	defer func() { c.scope = outer }()
+
+	if declare != nil {
+		declare() //the block's scope is the current one by now
+	}
+
+	var result types.Type = types.Void
+	for _, stmt := range block.Statements {
+		result = types.Void
+		if es, ok := stmt.(*ast.ExpressionStatement); ok {
+			result = c.checkExpression(es.Expression)
+			continue
+		}
+		c.checkStatement(stmt)
+	}
+
+	return result
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Functions
+
+//resolves the declared parameter and return types of a function literal into a function type
+func (c *Checker) functionType(fl *ast.FunctionLiteral) *types.Function {
+	fn := &types.Function{Return: types.Void}
+
+	for _, p := range fl.Parameters {
+		fn.Parameters = append(fn.Parameters, c.resolveType(p.Type))
+	}
+	if fl.ReturnType != nil {
+		fn.Return = c.resolveType(fl.ReturnType)
+	}
+
+	return fn
+}
+
+func (c *Checker) checkFunctionBody(fl *ast.FunctionLiteral, fn *types.Function) {
+	c.returns = append(c.returns, fn.Return) //return statements in the body are checked against this
+	defer func() { c.returns = c.returns[:len(c.returns)-1] }()
+
+	c.checkBlock(fl.Body, func() {
+		for i, p := range fl.Parameters {
+			c.declare(p.Name, fn.Parameters[i])
+		}
+	})
+
+	if fn.Return != types.Void && !alwaysReturns(fl.Body) { //we can't fall off the end of a function that promised a value
+		name := fl.Name
+		if name == "" {
+			name = "anonymous function"
+		}
+		c.errorAt(diagnostic.MissingReturn, diagnostic.TokenSpan(fl.Body.Rbrace), "missing return at end of %s", name)
+	}
+}
+
+//REQUIRES: a block
+//MODIFIES:
+//EFFECTS: returns whether every way through the block ends in a return statement
+func alwaysReturns(block *ast.BlockStatement) bool {
+	if block == nil || len(block.Statements) == 0 {
+		return false
+	}
+
+	switch last := block.Statements[len(block.Statements)-1].(type) {
+	case *ast.ReturnStatement:
+		return true
+	case *ast.ExpressionStatement: //an if/else where both branches return
+		ie, ok := last.Expression.(*ast.IfExpression)
+		return ok && alwaysReturns(ie.Consequence) && alwaysReturns(ie.Alternative)
+	case *ast.KeyStatement: //a key with a lock else where every lock returns
+		if last.Else == nil || !alwaysReturns(last.Else) {
+			return false
+		}
+		for _, lock := range last.Locks {
+			if !alwaysReturns(lock.Body) {
+				return false
+			}
+		}
+		return true
+	case *ast.WhileStatement: //'while true' only ends through a return, unless something breaks out of it
+		b, ok := last.Condition.(*ast.Boolean)
+		return ok && b.Value && !breaksOut(last.Body)
+	default:
+		return false
+	}
+}
+
+//returns whether a break statement in the block would end the loop the block belongs to (breaks in nested loops end those loops instead)
+func breaksOut(block *ast.BlockStatement) bool {
+	for _, stmt := range block.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.BreakStatement:
+			return true
+		case *ast.ExpressionStatement:
+			if ie, ok := stmt.Expression.(*ast.IfExpression); ok {
+				if breaksOut(ie.Consequence) || (ie.Alternative != nil && breaksOut(ie.Alternative)) {
+					return true
+				}
+			}
+		case *ast.KeyStatement:
+			for _, lock := range stmt.Locks {
+				if breaksOut(lock.Body) {
+					return true
+				}
+			}
+			if stmt.Else != nil && breaksOut(stmt.Else) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Expressions
+
+//REQUIRES: an expression
+//MODIFIES:
+//EFFECTS: returns the type the expression will have when it is evaluated, reporting any type errors found along the way
+func (c *Checker) checkExpression(exp ast.Expression) types.Type {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return types.Int
+
+	case *ast.Boolean:
+		return types.Bool
+
+	case *ast.FloatLiteral:
+		return types.Float
+
+	case *ast.StringLiteral:
+		return types.String
+
+	case *ast.InterpolatedString:
+		for _, part := range exp.Parts {
+			if t := c.checkExpression(part); t == types.Void {
+				c.errorAt(diagnostic.InvalidOperation, diagnostic.NodeSpan(part), "cannot interpolate %s, it has no value", part)
+			}
+		}
+		return types.String
+
+	case *ast.Identifier:
+		t, ok := c.scope.Lookup(exp.Value)
+		if !ok {
+			c.errorAt(diagnostic.Undefined, diagnostic.NodeSpan(exp), "undefined: %s", exp.Value)
+			return types.Invalid
+		}
+		return t
+
+	case *ast.PrefixExpression:
+		return c.unaryType(exp, exp.Operator, c.checkExpression(exp.Right))
+
+	case *ast.InfixExpression:
+		left := c.checkExpression(exp.Left)
+		right := c.checkExpression(exp.Right)
+		return c.binaryType(exp, exp.Operator, left, right)
+
+	case *ast.IfExpression:
+		return c.checkIfExpression(exp)
+
+	case *ast.RangeExpression:
+		start := c.checkExpression(exp.First)
+		end := c.checkExpression(exp.Last)
+		if (start != types.Int && start != types.Invalid) || (end != types.Int && end != types.Invalid) {
+			c.errorAt(diagnostic.TypeMismatch, diagnostic.NodeSpan(exp), "range bounds must be int, got %s..%s", start, end)
+		}
+		return types.Range
+
+	case *ast.FunctionLiteral:
+		fn := c.functionType(exp)
+		c.checkFunctionBody(exp, fn)
+		return fn
+
+	case *ast.CallExpression:
+		return c.checkCallExpression(exp)
+
+	case *ast.ArrayLiteral:
+		return c.checkArrayLiteral(exp)
+
+	case *ast.HashLiteral:
+		return c.checkHashLiteral(exp)
+
+	case *ast.IndexExpression:
+		return c.indexType(exp, c.checkExpression(exp.Left), c.checkExpression(exp.Index))
+
+	case *ast.StructLiteral:
+		return c.checkStructLiteral(exp)
+
+	case *ast.SelectorExpression:
+		if en := c.enumOf(exp.Left); en != nil { //a variant (ie Month.Feb)
+			if !en.HasVariant(exp.Field.Value) {
+				c.errorAt(diagnostic.UnknownVariant, diagnostic.NodeSpan(exp.Field), "unknown variant %s in enum %s", exp.Field.Value, en)
+				return types.Invalid
+			}
+			return en
+		}
+		return c.selectorType(exp, c.checkExpression(exp.Left))
+
+	default: //nil or something the parser couldn't make sense of, which has already been reported
+		return types.Invalid
+	}
+}
+
+func (c *Checker) unaryType(node ast.Node, operator string, right types.Type) types.Type {
+	if right == types.Invalid {
+		return types.Invalid
+	}
+
+	switch {
+	case operator == "-" && types.IsNumeric(right):
+		return right
+	case operator == "!" && right == types.Bool:
+		return types.Bool
+	default:
+		c.errorAt(diagnostic.InvalidOperation, diagnostic.NodeSpan(node), "operator %s not defined on %s", operator, right)
+		return types.Invalid
+	}
+}
+
+//REQUIRES: the node errors should point at, an infix operator and the types of its operands
+//MODIFIES:
+//EFFECTS: returns the type of 'left operator right', reporting an error if the operator can't be used with those operands
+func (c *Checker) binaryType(node ast.Node, operator string, left, right types.Type) types.Type {
+	if left == types.Invalid || right == types.Invalid {
+		return types.Invalid
+	}
+
+	if !types.Identical(left, right) { //no implicit conversions between types
+		c.errorAt(diagnostic.TypeMismatch, diagnostic.NodeSpan(node), "type mismatch: %s %s %s", left, operator, right)
+		return types.Invalid
+	}
+
+	switch operator {
+	case "+":
+		if types.IsNumeric(left) || left == types.String { //+ joins strings
+			return left
+		}
+	case "-", "*", "/":
+		if types.IsNumeric(left) {
+			return left
+		}
+	case "%":
+		if left == types.Int {
+			return types.Int
+		}
+	case "<", ">", "<=", ">=":
+		if types.IsNumeric(left) {
+			return types.Bool
+		}
+	case "and", "or":
+		if left == types.Bool {
+			return types.Bool
+		}
+	case "=", "!=":
+		if _, ok := left.(*types.Enum); ok || types.IsNumeric(left) || left == types.Bool || left == types.String {
+			return types.Bool
+		}
+	}
+
+	c.errorAt(diagnostic.InvalidOperation, diagnostic.NodeSpan(node), "operator %s not defined on %s", operator, left)
+	return types.Invalid
+}
+
+//the type of an if expression is the type its branches share. Without an else, or when the branches disagree, it doesn't produce a value
+func (c *Checker) checkIfExpression(ie *ast.IfExpression) types.Type {
+	condition := c.checkExpression(ie.Condition)
+	c.expectCondition(ie.Condition, condition, "if")
+
+	consequence := c.checkBlock(ie.Consequence, nil)
+	if ie.Alternative == nil {
+		return types.Void
+	}
+	alternative := c.checkBlock(ie.Alternative, nil)
+
+	if types.Identical(consequence, alternative) {
+		return consequence
+	}
+	return types.Void
+}
+
+//REQUIRES: an array literal
+//MODIFIES:
+//EFFECTS: returns the type of the array, which comes from its elements. They all have to be of the same type. An empty array has elements
+//of type Invalid, so it fits any array type without being reported (ie 'let int[] xs := []')
+func (c *Checker) checkArrayLiteral(al *ast.ArrayLiteral) types.Type {
+	var element types.Type = types.Invalid
+	for _, el := range al.Elements {
+		t := c.checkExpression(el)
+		switch {
+		case t == types.Void:
+			c.errorAt(diagnostic.TypeMismatch, diagnostic.NodeSpan(el), "cannot use %s in an array, it has no value", el)
+		case t == types.Invalid || assignable(t, element):
+		case element == types.Invalid || assignable(element, t): //the first element, or one that tells us more (ie the [1] in [[], [1]])
+			element = t
+		default:
+			c.errorAt(diagnostic.TypeMismatch, diagnostic.NodeSpan(el), "type mismatch: array elements must all be %s, got %s", element, t)
+		}
+	}
+	return &types.Array{Element: element}
+}
+
+//REQUIRES: an index expression and the types of what is indexed and of the index
+//MODIFIES:
+//EFFECTS: returns the value type of a map, and for an array the element type for an int index and the array type itself for a range index (a slice)
+func (c *Checker) indexType(ie *ast.IndexExpression, left, index types.Type) types.Type {
+	if left == types.Invalid {
+		return types.Invalid
+	}
+
+	if m, ok := left.(*types.Map); ok {
+		if index != types.Invalid && !types.Identical(index, m.Key) {
+			c.errorAt(diagnostic.TypeMismatch, diagnostic.NodeSpan(ie.Index), "map key must be %s, got %s", m.Key, index)
+		}
+		return m.Value
+	}
+
+	array, ok := left.(*types.Array)
+	if !ok {
+		c.errorAt(diagnostic.NotIndexable, diagnostic.NodeSpan(ie.Left), "cannot index %s (of type %s)", ie.Left, left)
+		return types.Invalid
+	}
+
+	switch index {
+	case types.Int:
+		return array.Element
+	case types.Range:
+		return array
+	case types.Invalid:
+		return types.Invalid
+	default:
+		c.errorAt(diagnostic.TypeMismatch, diagnostic.NodeSpan(ie.Index), "array index must be int or range, got %s", index)
+		return types.Invalid
+	}
+}
+
+//REQUIRES: a hash literal
+//MODIFIES:
+//EFFECTS: returns the type of the map, which comes from its pairs the same way the type of an array comes from its elements (see checkArrayLiteral)
+func (c *Checker) checkHashLiteral(hl *ast.HashLiteral) types.Type {
+	m := &types.Map{Key: types.Invalid, Value: types.Invalid}
+	for _, pair := range hl.Pairs {
+		key := c.checkExpression(pair.Key)
+		value := c.checkExpression(pair.Value)
+
+		switch {
+		case key == types.Invalid:
+		case !types.IsHashable(key):
+			c.errorAt(diagnostic.InvalidMapKey, diagnostic.NodeSpan(pair.Key), "invalid map key type %s", key)
+		case m.Key == types.Invalid:
+			m.Key = key
+		case !types.Identical(key, m.Key):
+			c.errorAt(diagnostic.TypeMismatch, diagnostic.NodeSpan(pair.Key), "type mismatch: map keys must all be %s, got %s", m.Key, key)
+		}
+
+		switch {
+		case value == types.Void:
+			c.errorAt(diagnostic.TypeMismatch, diagnostic.NodeSpan(pair.Value), "cannot use %s in a map, it has no value", pair.Value)
+		case value == types.Invalid || assignable(value, m.Value):
+		case m.Value == types.Invalid || assignable(m.Value, value):
+			m.Value = value
+		default:
+			c.errorAt(diagnostic.TypeMismatch, diagnostic.NodeSpan(pair.Value), "type mismatch: map values must all be %s, got %s", m.Value, value)
+		}
+	}
+	return m
+}
+
+//REQUIRES: a struct literal
+//MODIFIES:
+//EFFECTS: returns the struct type the literal constructs. Every field of the struct has to be given a value of its type, exactly once
+func (c *Checker) checkStructLiteral(sl *ast.StructLiteral) types.Type {
+	values := []types.Type{}
+	for _, f := range sl.Fields {
+		values = append(values, c.checkExpression(f.Value))
+	}
+
+	t, ok := c.scope.LookupType(sl.Name.Value)
+	if !ok {
+		c.errorAt(diagnostic.UnknownType, diagnostic.NodeSpan(sl.Name), "unknown type: %s", sl.Name.Value)
+		return types.Invalid
+	}
+	st, ok := t.(*types.Struct)
+	if !ok {
+		c.errorAt(diagnostic.InvalidOperation, diagnostic.NodeSpan(sl), "cannot construct %s, it isn't a struct", t)
+		return types.Invalid
+	}
+
+	given := map[string]bool{}
+	for i, f := range sl.Fields {
+		field, ok := st.Field(f.Name.Value)
+		switch {
+		case !ok:
+			c.errorAt(diagnostic.UnknownField, diagnostic.NodeSpan(f.Name), "unknown field %s in struct %s", f.Name.Value, st.Name)
+		case given[f.Name.Value]:
+			c.errorAt(diagnostic.DuplicateField, diagnostic.NodeSpan(f.Name), "duplicate field %s in struct literal", f.Name.Value)
+		default:
+			c.expectAssignable(f.Value, values[i], field, "field "+f.Name.Value+" of "+st.Name)
+		}
+		given[f.Name.Value] = true
+	}
+
+	for _, f := range st.Fields { //there is no zero value to fall back on, so leaving a field out is an error
+		if !given[f.Name] {
+			c.errorAt(diagnostic.MissingField, diagnostic.NodeSpan(sl), "missing field %s in %s literal", f.Name, st.Name)
+		}
+	}
+
+	return st
+}
+
+//returns the enum exp names when it is the name of an enum type that isn't shadowed by a variable (ie the Month in Month.Feb), and nil otherwise
+func (c *Checker) enumOf(exp ast.Expression) *types.Enum {
+	ident, ok := exp.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	if _, ok := c.scope.Lookup(ident.Value); ok {
+		return nil
+	}
+	t, _ := c.scope.LookupType(ident.Value)
+	en, _ := t.(*types.Enum)
+	return en
+}
+
+//returns the type of the field a selector expression picks out of left
+func (c *Checker) selectorType(se *ast.SelectorExpression, left types.Type) types.Type {
+	if left == types.Invalid {
+		return types.Invalid
+	}
+
+	st, ok := left.(*types.Struct)
+	if !ok {
+		c.errorAt(diagnostic.InvalidOperation, diagnostic.NodeSpan(se.Left), "cannot select field %s of %s (of type %s)", se.Field.Value, se.Left, left)
+		return types.Invalid
+	}
+
+	t, ok := st.Field(se.Field.Value)
+	if !ok {
+		c.errorAt(diagnostic.UnknownField, diagnostic.NodeSpan(se.Field), "unknown field %s in struct %s", se.Field.Value, st.Name)
+		return types.Invalid
+	}
+	return t
+}
+
+func (c *Checker) checkCallExpression(ce *ast.CallExpression) types.Type {
+	callee := c.checkExpression(ce.Function)
+
+	args := []types.Type{}
+	for _, a := range ce.Arguments {
+		args = append(args, c.checkExpression(a))
+	}
+
+	if callee == types.Invalid {
+		return types.Invalid
+	}
+
+	if builtin, ok := callee.(*types.Builtin); ok {
+		return c.checkBuiltinCall(ce, builtin, args)
+	}
+
+	fn, ok := callee.(*types.Function)
+	if !ok {
+		c.errorAt(diagnostic.NotCallable, diagnostic.NodeSpan(ce.Function), "cannot call %s (of type %s)", ce.Function, callee)
+		return types.Invalid
+	}
+
+	if len(args) != len(fn.Parameters) {
+		c.diagnostics.Add(diagnostic.Diagnostic{
+			Severity: diagnostic.Error,
+			Code:     diagnostic.WrongArgCount,
+			Message:  fmt.Sprintf("wrong number of arguments in call to %s: want=%d, got=%d", ce.Function, len(fn.Parameters), len(args)),
+			Span:     diagnostic.NodeSpan(ce),
+			Notes:    []string{fmt.Sprintf("%s has type %s", ce.Function, fn)},
+		})
+		return fn.Return
+	}
+
+	for i, arg := range args {
+		c.expectAssignable(ce.Arguments[i], arg, fn.Parameters[i], fmt.Sprintf("argument %d to %s", i+1, ce.Function))
+	}
+
+	return fn.Return
+}
+
+//REQUIRES: a call to a builtin and the types of its arguments
+//MODIFIES:
+//EFFECTS: returns the type the call produces, reporting an error if the builtin can't be called with those arguments
+func (c *Checker) checkBuiltinCall(ce *ast.CallExpression, builtin *types.Builtin, args []types.Type) types.Type {
+	switch builtin.Name {
+	case "print": //takes any number of anything that has a value
+		for i, arg := range args {
+			if arg == types.Void {
+				c.errorAt(diagnostic.TypeMismatch, diagnostic.NodeSpan(ce.Arguments[i]), "cannot print %s, it has no value", ce.Arguments[i])
+			}
+		}
+		return types.Void
+
+	case "len":
+		if len(args) != 1 {
+			c.errorAt(diagnostic.WrongArgCount, diagnostic.NodeSpan(ce), "wrong number of arguments in call to len: want=1, got=%d", len(args))
+			return types.Int
+		}
+		switch args[0].(type) {
+		case *types.Array, *types.Map:
+		default:
+			if args[0] != types.String && args[0] != types.Invalid {
+				c.errorAt(diagnostic.TypeMismatch, diagnostic.NodeSpan(ce.Arguments[0]), "argument to len must be string, array or map, got %s", args[0])
+			}
+		}
+		return types.Int
+
+	case "int", "float": //conversions between the two number types
+		result := types.Int
+		if builtin.Name == "float" {
+			result = types.Float
+		}
+		if len(args) != 1 {
+			c.errorAt(diagnostic.WrongArgCount, diagnostic.NodeSpan(ce), "wrong number of arguments in call to %s: want=1, got=%d", builtin.Name, len(args))
+			return result
+		}
+		if !types.IsNumeric(args[0]) && args[0] != types.Invalid {
+			c.errorAt(diagnostic.TypeMismatch, diagnostic.NodeSpan(ce.Arguments[0]), "cannot convert %s to %s", args[0], builtin.Name)
+		}
+		return result
+
+	case "args": //the arguments the script was run with
+		if len(args) != 0 {
+			c.errorAt(diagnostic.WrongArgCount, diagnostic.NodeSpan(ce), "wrong number of arguments in call to args: want=0, got=%d", len(args))
+		}
+		return &types.Array{Element: types.String}
+
+	default:
+		return types.Invalid
+	}
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Types
+
+//REQUIRES: a type annotation from the AST
+//MODIFIES:
+//EFFECTS: returns the type the annotation refers to (types.Invalid, with an error reported, if there is no such type)
+func (c *Checker) resolveType(node ast.TypeNode) types.Type {
+	switch node := node.(type) {
+	case *ast.NamedType:
+		t, ok := types.LookupBasic(node.Name)
+		if !ok {
+			t, ok = c.scope.LookupType(node.Name)
+		}
+		if !ok {
+			c.errorAt(diagnostic.UnknownType, diagnostic.NodeSpan(node), "unknown type: %s", node.Name)
+			return types.Invalid
+		}
+		return t
+
+	case *ast.ArrayType:
+		return &types.Array{Element: c.resolveType(node.Element)}
+
+	case *ast.MapType:
+		key := c.resolveType(node.Key)
+		value := c.resolveType(node.Value)
+		if key != types.Invalid && !types.IsHashable(key) {
+			c.errorAt(diagnostic.InvalidMapKey, diagnostic.NodeSpan(node.Key), "invalid map key type %s", key)
+			return types.Invalid
+		}
+		return &types.Map{Key: key, Value: value}
+
+	case *ast.FunctionType:
+		fn := &types.Function{Return: types.Void}
+		for _, p := range node.Parameters {
+			fn.Parameters = append(fn.Parameters, c.resolveType(p))
+		}
+		if node.ReturnType != nil {
+			fn.Return = c.resolveType(node.ReturnType)
+		}
+		return fn
+
+	default:
+		return types.Invalid
+	}
+}
+
+//returns the types of the loop variables of fs when looping over something of type t. With two variables the first one is the index of
+//an array or the key of a map, with one it is the element of an array or the key of a map
+func (c *Checker) loopVariableTypes(fs *ast.ForInStatement, t types.Type) (types.Type, types.Type) {
+	switch t := t.(type) {
+	case *types.Array:
+		if fs.Value != nil {
+			return types.Int, t.Element
+		}
+		return t.Element, types.Invalid
+	case *types.Map:
+		return t.Key, t.Value
+	}
+
+	element := c.elementType(fs.Iterable, t)
+	if fs.Value != nil && element != types.Invalid { //only arrays and maps have keys to go with the elements
+		c.errorAt(diagnostic.NotIterable, diagnostic.NodeSpan(fs.Iterable), "cannot iterate over %s with two variables", t)
+		return types.Invalid, types.Invalid
+	}
+	return element, types.Invalid
+}
+
+//returns the type of the loop variable when looping over iterable, whose type is t (anything but an array or a map)
+func (c *Checker) elementType(iterable ast.Expression, t types.Type) types.Type {
+	switch t {
+	case types.Range:
+		return types.Int
+	case types.String: //one character at a time
+		return types.String
+	case types.Invalid:
+		return types.Invalid
+	default:
+		c.errorAt(diagnostic.NotIterable, diagnostic.NodeSpan(iterable), "cannot iterate over %s", t)
+		return types.Invalid
+	}
+}
+
+//reports an error at exp if its type (value) can't be used where a target is expected (context says where, ie 'declaration of x')
+func (c *Checker) expectAssignable(exp ast.Expression, value, target types.Type, context string) {
+	if value == types.Invalid || target == types.Invalid { //already reported
+		return
+	}
+	if !assignable(value, target) {
+		c.errorAt(diagnostic.TypeMismatch, diagnostic.NodeSpan(exp), "type mismatch: cannot use %s as %s in %s", value, target, context)
+	}
+}
+
+//returns whether a value of type value can be used where target is expected. That is when they are identical, except that the elements of an
+//array or the keys and values of a map can be Invalid, so that the type of [] fits any array type and the type of {} fits any map type
+func assignable(value, target types.Type) bool {
+	switch v := value.(type) {
+	case *types.Array:
+		t, ok := target.(*types.Array)
+		return ok && (v.Element == types.Invalid || assignable(v.Element, t.Element))
+	case *types.Map:
+		t, ok := target.(*types.Map)
+		return ok && (v.Key == types.Invalid || types.Identical(v.Key, t.Key)) && (v.Value == types.Invalid || assignable(v.Value, t.Value))
+	default:
+		return types.Identical(value, target)
+	}
+}
+
+//reports an error if the condition of an if, while or lock (whose type is condition) isn't a bool
+func (c *Checker) expectCondition(exp ast.Expression, condition types.Type, context string) {
+	if condition != types.Bool && condition != types.Invalid {
+		c.errorAt(diagnostic.TypeMismatch, diagnostic.NodeSpan(exp), "%s condition must be bool, got %s", context, condition)
+	}
+}
+
+//adds an error with the formatted message to the diagnostics found so far
+func (c *Checker) errorAt(code diagnostic.Code, span diagnostic.Span, format string, a ...interface{}) {
+	c.diagnostics.Add(diagnostic.Diagnostic{Severity: diagnostic.Error, Code: code, Message: fmt.Sprintf(format, a...), Span: span})
+}
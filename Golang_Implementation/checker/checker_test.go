@@ -0,0 +1,280 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"../ast"
+	"../diagnostic"
+	"../lexer"
+	"../parser"
+)
+
+func TestWellTypedPrograms(t *testing.T) {
+	tests := []string{
+		"let int x := 5; let bool b := x < 10;",
+		"let int x := -5 * (2 + 3); set x :+ 1;",
+		"let bool b := !(1 = 2) != false;",
+		"let int x := if (true) { 1 } else { 2 };",
+		"let int total := 0; for i in 1..10 { set total :+ i; }",
+		"let int i := 0; while (i < 10) { set i :+ 1; }",
+		"let int x := 3; key (x) { lock 1 { x } lock x > 1 { 0 } lock else { -1 } }",
+		"let int x := 3; let int y := 4; key (x, y) { lock x < y { 1 } lock 3, 4 { 2 } }",
+		"func add(int x, int y) returns int { return x + y; } let int z := add(1, 2);",
+		"func fib(int n) returns int { if (n < 2) { return n; } return fib(n - 1) + fib(n - 2); }",
+		"func sign(int n) returns int { if (n < 0) { return -1; } else { return 1; } }",
+		"func loop() returns int { while (true) { return 1; } }",
+		"func hello() { return; } hello();",
+		"let func(int) returns int double := func(int x) returns int { return x * 2; }; double(4);",
+		"func apply(func(int) returns int f, int x) returns int { return f(x); }",
+		`let string s := "squid" + "script"; let bool b := s != "";`,
+		`let int n := 3; let string s := "${n} squids, ${n > 2}"; print(s, n, true);`,
+		`let int n := len("squid"); for c in "squid" { print(c + "!"); }`,
+		`let string s := "b"; key (s) { lock "a" { 1 } lock else { 2 } }`,
+		`let string[] a := args(); for arg in args() { print(arg); } let int n := len(args());`,
+		`func len(int x) returns int { return x; } len(3);`, //builtins can be shadowed
+		"let float f := 1.5 * -2.0; let bool b := f < 0.5;",
+		"let int n := 3; let float half := float(n) / 2.0; let int back := int(half);",
+		"let int x := 7 % 3; let bool b := x <= 1 and x >= 0 or 2.5 >= 1.5;",
+		"let int[] xs := [1, 2, 3]; let int x := xs[0]; let int[] ys := xs[1..2]; set xs := [];",
+		"let int[][] grid := [[1], []]; for row in grid { for x in row { print(x + 1); } } let int n := len(grid[0]);",
+		"func sum(int[] xs) returns int { let int total := 0; for x in xs { set total :+ x; } return total; } sum([1, 2]);",
+		`let map[string]int m := {"a": 1}; set m["b"] := 2; set m["a"] :+ m["b"]; let int n := len(m);`,
+		`let map[string]int[] m := {"a": [], "b": [1]}; set m["a"] := [2]; set m["a"][0] :+ 1;`,
+		`let map[int]bool seen := {}; for k, v in seen { let int n := k; let bool value := v; } for k in seen { let int n := k; }`,
+		`let string[] names := ["a"]; for i, name in names { let int index := i; let string s := name; } set names[0] := "b";`,
+		"struct Point { int x; int y } let Point p := Point{y: 2, x: 1}; set p.x :+ p.y; let int[] xs := [p.x];",
+		"struct Point { int x; int y } func origin() returns Point { return Point{x: 0, y: 0}; } let int x := origin().x;",
+		"struct Node { int value; Node[] children } let Node n := Node{value: 1, children: []}; set n.children := [n]; n.children[0].value;",
+		"enum Month { Jan, Feb } let Month m := Month.Feb; key (m) { lock Month.Jan { 31 } lock Month.Feb { 28 } }",
+		"enum Month { Jan, Feb } func next(Month m) returns Month { if (m = Month.Jan) { return Month.Feb; } return Month.Jan; }",
+		"enum Color { Red } let map[Color]string names := {Color.Red: \"red\"}; struct Pixel { Color c } Pixel{c: Color.Red}.c != Color.Red;",
+		"struct Point { int x; int y } let map[string]Point ps := {}; set ps[\"a\"] := Point{x: 1, y: 1}; set ps[\"a\"].x := 2;",
+	}
+
+	for _, input := range tests {
+		errors := testCheck(t, input)
+		if len(errors) != 0 {
+			t.Errorf("unexpected type errors for %q: %v", input, errors)
+		}
+	}
+}
+
+func TestTypeErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"let int x := true;", "1:14: type mismatch: cannot use bool as int in declaration of x"},
+		{"let bool b := 1 + 2;", "1:15: type mismatch: cannot use int as bool in declaration of b"},
+		{"let char c := 1;", "1:5: unknown type: char"},
+		{"let string s := 1;", "1:17: type mismatch: cannot use int as string in declaration of s"},
+		{"let int x := y;", "1:14: undefined: y"},
+		{"5 + true;", "1:1: type mismatch: int + bool"},
+		{"true + false;", "1:1: operator + not defined on bool"},
+		{"-true;", "1:1: operator - not defined on bool"},
+		{"!5;", "1:1: operator ! not defined on int"},
+		{"if (1) { 2 }", "1:5: if condition must be bool, got int"},
+		{"while (1) { 2 }", "1:8: while condition must be bool, got int"},
+		{"let int x := if (true) { 1 };", "1:14: type mismatch: cannot use void as int in declaration of x"},
+		{"let int x := if (true) { 1 } else { false };", "1:14: type mismatch: cannot use void as int in declaration of x"},
+		{"let int x := 1; set x := false;", "1:26: type mismatch: cannot use bool as int in assignment to x"},
+		{"let bool b := true; set b :+ 1;", "1:21: type mismatch: bool + int"},
+		{"set x := 1;", "1:5: cannot set undeclared variable: x"},
+		{"for i in 5 { i }", "1:10: cannot iterate over int"},
+		{"1..true;", "1:1: range bounds must be int, got int..bool"},
+		{"let int x := 1; let int y := 2; key (x, y) { lock 1 { x } }", "1:51: lock condition must be bool, got int"},
+		{"let int x := 1; let bool y := true; key (x, y) { lock 1, 2 { x } }", "1:58: lock value of type int does not match key of type bool"},
+		{"func f(int x) returns int { return true; }", "1:36: type mismatch: cannot use bool as int in return"},
+		{"func f(int x) { return x; }", "1:17: cannot return a value from a function without a return type"},
+		{"func f(int x) returns int { return; }", "1:29: missing return value, want int"},
+		{"func f(int x) returns int { x; }", "1:32: missing return at end of f"},
+		{"func f(int x) returns int { if (x > 1) { return 1; } }", "1:54: missing return at end of f"},
+		{"func f() returns int { while (true) { break; } }", "1:48: missing return at end of f"},
+		{"func f(int x) returns int { return x; } f(true);", "1:43: type mismatch: cannot use bool as int in argument 1 to f"},
+		{"func f(int x) returns int { return x; } f(1, 2);", "1:41: wrong number of arguments in call to f: want=1, got=2"},
+		{"let int x := 1; x(2);", "1:17: cannot call x (of type int)"},
+		{"func f(int x) returns int { return x; } let int y := f;", "1:54: type mismatch: cannot use func(int) returns int as int in declaration of y"},
+		{`let int x := "5";`, "1:14: type mismatch: cannot use string as int in declaration of x"},
+		{`"a" + 1;`, "1:1: type mismatch: string + int"},
+		{"let float f := 1;", "1:16: type mismatch: cannot use int as float in declaration of f"},
+		{"1.5 * 2;", "1:1: type mismatch: float * int"},
+		{"!1.5;", "1:1: operator ! not defined on float"},
+		{"1.5 % 2.0;", "1:1: operator % not defined on float"},
+		{"1 and true;", "1:1: type mismatch: int and bool"},
+		{"1 or 2;", "1:1: operator or not defined on int"},
+		{`"a" <= "b";`, "1:1: operator <= not defined on string"},
+		{`int("1");`, `1:5: cannot convert string to int`},
+		{"float();", "1:1: wrong number of arguments in call to float: want=1, got=0"},
+		{"for x in 1.0..2.0 { x }", "1:10: range bounds must be int, got float..float"},
+		{`"a" - "b";`, "1:1: operator - not defined on string"},
+		{`func f() {} let string s := "${f()}";`, "1:32: cannot interpolate f(), it has no value"},
+		{`func f() {} print(f());`, "1:19: cannot print f(), it has no value"},
+		{`len("a", "b");`, "1:1: wrong number of arguments in call to len: want=1, got=2"},
+		{`len(5);`, "1:5: argument to len must be string, array or map, got int"},
+		{`args(1);`, "1:1: wrong number of arguments in call to args: want=0, got=1"},
+		{`let int[] a := args();`, "1:16: type mismatch: cannot use string[] as int[] in declaration of a"},
+		{`for c in "ab" { let int x := c; }`, "1:30: type mismatch: cannot use string as int in declaration of x"},
+		{"let int[] xs := [1, true];", "1:21: type mismatch: array elements must all be int, got bool"},
+		{"let int[] xs := [1.5];", "1:17: type mismatch: cannot use float[] as int[] in declaration of xs"},
+		{"let int[] xs := 1;", "1:17: type mismatch: cannot use int as int[] in declaration of xs"},
+		{"let int x := [1][0..0];", "1:14: type mismatch: cannot use int[] as int in declaration of x"},
+		{"let int x := 5; x[0];", "1:17: cannot index x (of type int)"},
+		{"let int[] xs := [1]; xs[true];", "1:25: array index must be int or range, got bool"},
+		{"let float[] xs := []; let bool b := xs[0];", "1:37: type mismatch: cannot use float as bool in declaration of b"},
+		{"let int[] xs := [1]; for x in xs { let bool b := x; }", "1:50: type mismatch: cannot use int as bool in declaration of b"},
+		{"func f() {} [f()];", "1:14: cannot use f() in an array, it has no value"},
+		{"let char[] cs := [];", "1:5: unknown type: char"},
+		{`let map[string]int m := {"a": true};`, "1:25: type mismatch: cannot use map[string]bool as map[string]int in declaration of m"},
+		{`let map[string]int m := {"a": 1, 2: 2};`, "1:34: type mismatch: map keys must all be string, got int"},
+		{`let map[string]int m := {"a": 1, "b": true};`, "1:39: type mismatch: map values must all be int, got bool"},
+		{`{1.5: 1};`, "1:2: invalid map key type float"},
+		{`let map[float]int m := {};`, "1:9: invalid map key type float"},
+		{`let map[string]int m := {}; m[1];`, "1:31: map key must be string, got int"},
+		{`let map[string]int m := {}; let bool b := m["a"];`, "1:43: type mismatch: cannot use int as bool in declaration of b"},
+		{`let map[string]int m := {}; set m["a"] := "b";`, `1:43: type mismatch: cannot use string as int in assignment to (m["a"])`},
+		{"let int[] xs := [1]; set xs[0..0] := [2];", "1:26: cannot set a slice, only single elements"},
+		{"set xs[0] := 1;", "1:5: undefined: xs"},
+		{"for i, x in 1..3 { x }", "1:13: cannot iterate over range with two variables"},
+		{`let map[string]int m := {}; for k, v in m { let int x := k; }`, "1:58: type mismatch: cannot use string as int in declaration of x"},
+		{"struct Point { int x; int x }", "1:27: duplicate field x in struct Point"},
+		{"struct Point { char c }", "1:16: unknown type: char"},
+		{"Point{x: 1};", "1:1: unknown type: Point"},
+		{"struct Point { int x; int y } Point{x: 1};", "1:31: missing field y in Point literal"},
+		{"struct Point { int x } Point{x: 1, z: 2};", "1:36: unknown field z in struct Point"},
+		{"struct Point { int x } Point{x: 1, x: 2};", "1:36: duplicate field x in struct literal"},
+		{"struct Point { int x } Point{x: true};", "1:33: type mismatch: cannot use bool as int in field x of Point"},
+		{"struct Point { int x } let Point p := Point{x: 1}; p.y;", "1:54: unknown field y in struct Point"},
+		{"let int n := 1; n.x;", "1:17: cannot select field x of n (of type int)"},
+		{"struct Point { int x } let Point p := Point{x: 1}; set p.x := 1.5;", "1:63: type mismatch: cannot use float as int in assignment to (p.x)"},
+		{"struct A { int x } struct B { int x } let A a := B{x: 1};", "1:50: type mismatch: cannot use B as A in declaration of a"}, //structs are only the same type as themselves
+		{"struct Point { int x } let Point p := Point{x: 1}; p = p;", "1:52: operator = not defined on Point"},
+		{"enum Month { Jan, Jan }", "1:19: duplicate variant Jan in enum Month"},
+		{"enum Month { Jan } Month.Feb;", "1:26: unknown variant Feb in enum Month"},
+		{"enum Month { Jan } let Month m := 1;", "1:35: type mismatch: cannot use int as Month in declaration of m"},
+		{"enum A { X } enum B { X } A.X = B.X;", "1:27: type mismatch: A = B"},
+		{"enum Month { Jan } Month.Jan < Month.Jan;", "1:20: operator < not defined on Month"},
+		{"enum Month { Jan } set Month.Jan := Month.Jan;", "1:24: cannot set Month.Jan, the variants of an enum can't be changed"},
+		{"enum Month { Jan } Month{};", "1:20: cannot construct Month, it isn't a struct"},
+		{"enum Month { Jan } key (Month.Jan) { lock 1 { 1 } }", "1:43: lock value of type int does not match key of type Month"},
+		{"enum M { A } let int M := 3;", "1:22: M is already declared as a type"}, //otherwise M.A would be ambiguous
+		{"struct P { int x } func P() {}", "1:25: P is already declared as a type"},
+		{"enum M { A } func f(int M) {}", "1:25: M is already declared as a type"},
+		{"enum M { A } for M in 1..2 {}", "1:18: M is already declared as a type"},
+		{"let int M := 3; enum M { A }", "1:22: M is already declared as a variable"},
+	}
+
+	for _, tt := range tests {
+		errors := testCheck(t, tt.input)
+
+		if len(errors) == 0 {
+			t.Errorf("no type errors for %q, expected %q", tt.input, tt.expectedMessage)
+			continue
+		}
+
+		if errors[0] != tt.expectedMessage {
+			t.Errorf("wrong error message for %q. expected=%q, got=%q", tt.input, tt.expectedMessage, errors[0])
+		}
+	}
+}
+
+func TestNonExhaustiveKey(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string //the warnings separated by "; ", or "" if there shouldn't be any
+	}{
+		{"enum M { A, B, C } let M m := M.A; key (m) { lock M.A { 1 } }", "1:41: key over M is missing locks for M.B, M.C"},
+		{"enum M { A, B, C } let M m := M.A; key (m) { lock M.A { 1 } lock M.B = m { 2 } lock m != M.C { 3 } }", "1:41: key over M is missing locks for M.C"}, //comparisons with a variant count, other conditions don't
+		{"enum M { A, B } let M m := M.A; let M n := M.B; key (m) { lock n = M.B { 1 } lock m = M.A { 2 } }", "1:54: key over M is missing locks for M.B"},
+		{"enum M { A, B } let M m := M.A; key (m) { lock M.A { 1 } lock M.B { 2 } }", ""},
+		{"enum M { A, B } let M m := M.A; key (m) { lock M.A { 1 } lock else { 2 } }", ""},
+		{"enum M { A, B } let M m := M.A; key (m, 1) { lock M.A, 1 { 1 } }", "1:38: key over M is missing locks for M.B"},
+		{"enum M { A, B } let M m := M.A; let M n := M.B; key (m, n) { lock M.A, M.A { 1 } }", "1:54: key over M is missing locks for M.B; 1:57: key over M is missing locks for M.B"}, //every key is checked
+		{"enum M { A, B } let M m := M.A; let M n := M.B; key (m, n) { lock M.A, M.B { 1 } lock n = M.A { 2 } }", "1:54: key over M is missing locks for M.B"},
+		{"enum M { A, B } let M m := M.A; key (m, 1) { lock M.A, 1 { 1 } lock M.B, 2 { 2 } }", ""},
+		{"let int n := 1; key (n) { lock 1 { 1 } }", ""},
+	}
+
+	for _, tt := range tests {
+		diagnostics := New().Check(parse(t, tt.input))
+		if diagnostics.HasErrors() {
+			t.Fatalf("unexpected type errors for %q: %v", tt.input, diagnostics.Errors())
+		}
+
+		warnings := []string{}
+		for _, d := range diagnostics {
+			if d.Severity == diagnostic.Warning && d.Code == diagnostic.NonExhaustiveKey {
+				warnings = append(warnings, d.String())
+			}
+		}
+
+		if got := strings.Join(warnings, "; "); got != tt.expected {
+			t.Errorf("wrong warnings for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestInvalidTypesDontCascade(t *testing.T) {
+	errors := testCheck(t, "let int x := y + 1 * 2 - 3;")
+
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 type error, got %d: %v", len(errors), errors)
+	}
+}
+
+//every error points at where it is, not only the first one, and on whatever line it is on
+func TestErrorPositions(t *testing.T) {
+	input := `let int x := true;
+func f() returns int {
+  return "a";
+}
+struct P { int x }
+let P p := P{x: 1, y: 2};
+print(y, p.z);`
+
+	expected := []string{
+		"1:14: type mismatch: cannot use bool as int in declaration of x",
+		"3:10: type mismatch: cannot use string as int in return",
+		"6:20: unknown field y in struct P",
+		"7:7: undefined: y",
+		"7:12: unknown field z in struct P",
+	}
+
+	errors := testCheck(t, input)
+	if strings.Join(errors, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong errors.\nexpected=%q\ngot     =%q", expected, errors)
+	}
+}
+
+func TestCheckerRemembersCheckedPrograms(t *testing.T) {
+	c := New()
+
+	if errors := c.Check(parse(t, "let int x := 5;")).Errors(); len(errors) != 0 {
+		t.Fatalf("unexpected type errors: %v", errors)
+	}
+	if errors := c.Check(parse(t, "let bool y := true; let int z := y;")).Errors(); len(errors) == 0 {
+		t.Fatalf("expected a type error")
+	}
+
+	//x was declared by a program that checked out, y by one that didn't
+	if errors := c.Check(parse(t, "x;")).Errors(); len(errors) != 0 {
+		t.Errorf("x should still be declared, got %v", errors)
+	}
+	if errors := c.Check(parse(t, "y;")).Errors(); len(errors) != 1 || errors[0] != "1:1: undefined: y" {
+		t.Errorf("y should not have been declared, got %v", errors)
+	}
+}
+
+func testCheck(t *testing.T, input string) []string {
+	return New().Check(parse(t, input)).Errors()
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
@@ -0,0 +1,53 @@
+package checker
+
+import "../types"
+
+//A scope keeps track of the types of the names declared in it, the same way object.Environment keeps track of their values
+type Scope struct {
+	names     map[string]types.Type //names and the types they were declared with
+	typeNames map[string]types.Type //the types declared in it (ie structs), which have their own names apart from variables
+	outer     *Scope                //the enclosing scope (nil for the outermost scope)
+}
+
+//REQUIRES: the scope that will enclose the new one (nil for an outermost scope)
+//MODIFIES:
+//EFFECTS: creates an empty scope whose lookups fall back to outer when a name isn't found
+func NewScope(outer *Scope) *Scope {
+	return &Scope{names: make(map[string]types.Type), typeNames: make(map[string]types.Type), outer: outer}
+}
+
+//REQUIRES: a name to look up
+//MODIFIES:
+//EFFECTS: returns the type name was declared with, checking enclosing scopes if it isn't declared here, and whether it was found at all
+func (s *Scope) Lookup(name string) (types.Type, bool) {
+	t, ok := s.names[name]
+	if !ok && s.outer != nil { //not declared here, so we try the enclosing scope
+		t, ok = s.outer.Lookup(name)
+	}
+	return t, ok
+}
+
+//REQUIRES: a name and the type it is declared with
+//MODIFIES: the names of this scope
+//EFFECTS: declares name with type t in this scope
+func (s *Scope) Declare(name string, t types.Type) {
+	s.names[name] = t
+}
+
+//REQUIRES: the name of a type to look up
+//MODIFIES:
+//EFFECTS: returns the type declared with that name, checking enclosing scopes if it isn't declared here, and whether it was found at all
+func (s *Scope) LookupType(name string) (types.Type, bool) {
+	t, ok := s.typeNames[name]
+	if !ok && s.outer != nil {
+		t, ok = s.outer.LookupType(name)
+	}
+	return t, ok
+}
+
+//REQUIRES: a name and the type it names
+//MODIFIES: the type names of this scope
+//EFFECTS: declares t under name in this scope
+func (s *Scope) DeclareType(name string, t types.Type) {
+	s.typeNames[name] = t
+}
@@ -0,0 +1,423 @@
+//OVERVIEW: The cli is the squid command. It runs scripts (as source or as compiled .sqdc files), starts the REPL, and gives access to each
+//stage of the pipeline on its own: the tokens, the AST, the type check, the formatter and the compiler
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/user"
+	"strings"
+
+	"../ast"
+	"../checker"
+	"../compiler"
+	"../diagnostic"
+	"../evaluator"
+	"../format"
+	"../lexer"
+	"../object"
+	"../parser"
+	"../repl"
+	"../sqdc"
+	"../token"
+	"../vm"
+)
+
+const ( //the exit codes of every command
+	ExitOK      = 0 //everything worked
+	ExitError   = 1 //the input couldn't be read, or the program has parse or type errors
+	ExitUsage   = 2 //the command line was wrong
+	ExitRuntime = 3 //the program started running and failed
+)
+
+const usage = `usage: squid [command] [arguments]
+
+commands:
+  run [-engine vm|eval] file [-- args...]  run a .sqd script or a compiled .sqdc file (squid file.sqd for short)
+  repl                                     start the interactive prompt (what squid does without a command)
+  parse file                               print the AST of a script, one statement per line
+  tokens file                              print the tokens of a script
+  check file                               type check a script without running it
+  fmt [-w] file                            print a script formatted the canonical way (or write it back with -w)
+  compile [-o file.sqdc] [-strip] file     compile a script to a .sqdc file
+
+file can be - to read the script from standard input
+`
+
+//This is what is constructed; where a command reads from and writes to
+type command struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+//REQUIRES: the command line arguments after the name of the program, and where to read and write
+//MODIFIES: stdout, stderr, the file system (for fmt -w and compile) and object.Output and object.Args
+//EFFECTS: runs the command the arguments ask for and returns the exit code
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	cmd := &command{stdin: stdin, stdout: stdout, stderr: stderr}
+
+	if len(args) == 0 {
+		return cmd.repl(nil)
+	}
+
+	switch args[0] {
+	case "run":
+		return cmd.run(args[1:])
+	case "repl":
+		return cmd.repl(args[1:])
+	case "parse":
+		return cmd.parse(args[1:])
+	case "tokens":
+		return cmd.tokens(args[1:])
+	case "check":
+		return cmd.check(args[1:])
+	case "fmt":
+		return cmd.reformat(args[1:])
+	case "compile":
+		return cmd.compile(args[1:])
+	case "help", "-h", "-help", "--help":
+		io.WriteString(stdout, usage)
+		return ExitOK
+	}
+
+	if strings.HasPrefix(args[0], "-") && args[0] != "-" {
+		fmt.Fprintf(stderr, "squid: unknown flag %s\n%s", args[0], usage)
+		return ExitUsage
+	}
+	if !strings.HasSuffix(args[0], ".sqd") && !strings.HasSuffix(args[0], ".sqdc") && args[0] != "-" { //most likely a misspelled command
+		fmt.Fprintf(stderr, "squid: unknown command %s\n%s", args[0], usage)
+		return ExitUsage
+	}
+	return cmd.run(args) //squid file.sqd is squid run file.sqd
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Commands
+
+func (cmd *command) repl(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintf(cmd.stderr, "usage: squid repl\n")
+		return ExitUsage
+	}
+
+	name := "there"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	fmt.Fprintf(cmd.stdout, "Hello %s! This is the SquidScript programming language!\n", name)
+	fmt.Fprintf(cmd.stdout, "Feel free to type in commands (%s on a %sline throws away the statement you are in the middle of)\n", repl.ABORT, repl.CONTINUATION_PROMPT)
+	repl.Start(cmd.stdin, cmd.stdout)
+	return ExitOK
+}
+
+//REQUIRES: the arguments after 'run' (ie '-engine eval hello.sqd -- a b')
+//MODIFIES: stdout, stderr, object.Output and object.Args
+//EFFECTS: runs the script. Everything after -- is handed to the script, which gets it from args()
+func (cmd *command) run(args []string) int {
+	var scriptArgs []string
+	for i, arg := range args {
+		if arg == "--" {
+			args, scriptArgs = args[:i], args[i+1:]
+			break
+		}
+	}
+
+	flags := cmd.flagSet("run", "usage: squid run [-engine vm|eval] file [-- args...]")
+	engine := flags.String("engine", "vm", "what runs the script: the bytecode vm, or the tree-walking evaluator (eval)")
+	if !cmd.parseFlags(flags, args) {
+		return ExitUsage
+	}
+	if *engine != "vm" && *engine != "eval" {
+		fmt.Fprintf(cmd.stderr, "squid: unknown engine %s, it is either vm or eval\n", *engine)
+		return ExitUsage
+	}
+
+	filename, src, err := cmd.read(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(cmd.stderr, "squid: %s\n", err)
+		return ExitError
+	}
+
+	object.Output = cmd.stdout
+	object.Args = scriptArgs
+
+	if strings.HasSuffix(filename, ".sqdc") || strings.HasPrefix(src, sqdc.Magic) { //already compiled, so all that is left is to run it
+		if *engine != "vm" {
+			fmt.Fprintf(cmd.stderr, "squid: %s is compiled, only the vm can run it\n", filename)
+			return ExitUsage
+		}
+		bytecode, err := sqdc.Unmarshal([]byte(src))
+		if err != nil {
+			fmt.Fprintf(cmd.stderr, "squid: %s: %s\n", filename, err)
+			return ExitError
+		}
+		if bytecode.Source != "" {
+			filename = bytecode.Source
+		}
+		return cmd.runBytecode(filename, bytecode)
+	}
+
+	program, ok := cmd.parseAndCheck(filename, src)
+	if !ok {
+		return ExitError
+	}
+
+	if *engine == "eval" {
+		if err, ok := evaluator.Eval(program, object.NewEnvironment()).(*object.Error); ok {
+			if err.Line > 0 { //the same way runBytecode does it
+				filename = fmt.Sprintf("%s:%d", filename, err.Line)
+			}
+			fmt.Fprintf(cmd.stderr, "squid: %s: runtime error: %s\n", filename, err.Message)
+			return ExitRuntime
+		}
+		return ExitOK
+	}
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		fmt.Fprintf(cmd.stderr, "squid: %s: %s\n", filename, err)
+		return ExitError
+	}
+	return cmd.runBytecode(filename, c.Bytecode())
+}
+
+func (cmd *command) runBytecode(filename string, bytecode *compiler.Bytecode) int {
+	machine := vm.New(bytecode)
+	if err := machine.Run(); err != nil {
+		if line := machine.Line(); line > 0 {
+			filename = fmt.Sprintf("%s:%d", filename, line)
+		}
+		fmt.Fprintf(cmd.stderr, "squid: %s: runtime error: %s\n", filename, err)
+		return ExitRuntime
+	}
+	return ExitOK
+}
+
+//REQUIRES: the arguments after 'parse'
+//MODIFIES: stdout and stderr
+//EFFECTS: prints the AST of the script, one top level statement per line
+func (cmd *command) parse(args []string) int {
+	flags := cmd.flagSet("parse", "usage: squid parse file")
+	if !cmd.parseFlags(flags, args) {
+		return ExitUsage
+	}
+	filename, src, err := cmd.read(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(cmd.stderr, "squid: %s\n", err)
+		return ExitError
+	}
+
+	p := parser.New(lexer.NewFile(filename, src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		diagnostic.RenderAll(cmd.stderr, src, p.Diagnostics())
+		return ExitError
+	}
+
+	for _, stmt := range program.Statements {
+		fmt.Fprintln(cmd.stdout, stmt.String())
+	}
+	return ExitOK
+}
+
+//REQUIRES: the arguments after 'tokens'
+//MODIFIES: stdout and stderr
+//EFFECTS: prints every token of the script with where it starts, its type and its literal
+func (cmd *command) tokens(args []string) int {
+	flags := cmd.flagSet("tokens", "usage: squid tokens file")
+	if !cmd.parseFlags(flags, args) {
+		return ExitUsage
+	}
+	filename, src, err := cmd.read(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(cmd.stderr, "squid: %s\n", err)
+		return ExitError
+	}
+
+	l := lexer.NewFile(filename, src)
+	for {
+		tok := l.NextToken()
+		fmt.Fprintf(cmd.stdout, "%-8s %-10s %s\n", fmt.Sprintf("%d:%d", tok.Pos.Line, tok.Pos.Column), tok.Type, tok.Literal)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	if diagnostics := l.Diagnostics(); len(diagnostics) != 0 {
+		diagnostic.RenderAll(cmd.stderr, src, diagnostics)
+		return ExitError
+	}
+	return ExitOK
+}
+
+//REQUIRES: the arguments after 'check'
+//MODIFIES: stderr
+//EFFECTS: parses and type checks the script without running it, printing what was found
+func (cmd *command) check(args []string) int {
+	flags := cmd.flagSet("check", "usage: squid check file")
+	if !cmd.parseFlags(flags, args) {
+		return ExitUsage
+	}
+	filename, src, err := cmd.read(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(cmd.stderr, "squid: %s\n", err)
+		return ExitError
+	}
+
+	if _, ok := cmd.parseAndCheck(filename, src); !ok {
+		return ExitError
+	}
+	return ExitOK
+}
+
+//REQUIRES: the arguments after 'fmt'
+//MODIFIES: stdout, stderr and (with -w) the file
+//EFFECTS: prints the script formatted the canonical way, or with -w writes it back to the file if that changes it
+func (cmd *command) reformat(args []string) int {
+	flags := cmd.flagSet("fmt", "usage: squid fmt [-w] file")
+	write := flags.Bool("w", false, "write the result back to the file instead of printing it")
+	if !cmd.parseFlags(flags, args) {
+		return ExitUsage
+	}
+	if *write && flags.Arg(0) == "-" {
+		fmt.Fprintf(cmd.stderr, "squid: fmt -w needs a file to write to, not standard input\n")
+		return ExitUsage
+	}
+	filename, src, err := cmd.read(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(cmd.stderr, "squid: %s\n", err)
+		return ExitError
+	}
+
+	p := parser.New(lexer.NewFile(filename, src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 { //there is no telling what a broken program was meant to look like
+		diagnostic.RenderAll(cmd.stderr, src, p.Diagnostics())
+		return ExitError
+	}
+
+	formatted, err := format.Source(program, src)
+	if err != nil {
+		fmt.Fprintf(cmd.stderr, "squid: %s:%s\n", filename, err)
+		return ExitError
+	}
+
+	if !*write {
+		io.WriteString(cmd.stdout, formatted)
+		return ExitOK
+	}
+	if formatted == src {
+		return ExitOK
+	}
+	if err := ioutil.WriteFile(filename, []byte(formatted), 0644); err != nil {
+		fmt.Fprintf(cmd.stderr, "squid: %s\n", err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+//REQUIRES: the arguments after 'compile' (ie '-o out.sqdc hello.sqd')
+//MODIFIES: stderr and the file system
+//EFFECTS: compiles a .sqd file to a .sqdc file next to it (or to the -o file)
+func (cmd *command) compile(args []string) int {
+	flags := cmd.flagSet("compile", "usage: squid compile [-o file.sqdc] [-strip] file.sqd")
+	output := flags.String("o", "", "where to write the .sqdc file (the name of the source with a .sqdc extension by default)")
+	strip := flags.Bool("strip", false, "leave out the debug section (the line tables and the name of the source)")
+	if !cmd.parseFlags(flags, args) {
+		return ExitUsage
+	}
+	if *output == "" && flags.Arg(0) == "-" {
+		fmt.Fprintf(cmd.stderr, "squid: compiling standard input needs -o to say where the .sqdc file goes\n")
+		return ExitUsage
+	}
+	filename, src, err := cmd.read(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(cmd.stderr, "squid: %s\n", err)
+		return ExitError
+	}
+
+	program, ok := cmd.parseAndCheck(filename, src)
+	if !ok {
+		return ExitError
+	}
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		fmt.Fprintf(cmd.stderr, "squid: %s: %s\n", filename, err)
+		return ExitError
+	}
+	data, err := sqdc.Marshal(c.Bytecode(), !*strip)
+	if err != nil {
+		fmt.Fprintf(cmd.stderr, "squid: %s: %s\n", filename, err)
+		return ExitError
+	}
+
+	if *output == "" {
+		*output = strings.TrimSuffix(filename, ".sqd") + ".sqdc"
+	}
+	if err := ioutil.WriteFile(*output, data, 0644); err != nil {
+		fmt.Fprintf(cmd.stderr, "squid: %s\n", err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Helpers
+
+//REQUIRES: the name of a command and its usage line
+//MODIFIES:
+//EFFECTS: returns a flag set for the command that reports its problems on stderr
+func (cmd *command) flagSet(name string, usageLine string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(cmd.stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(cmd.stderr, usageLine)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+//REQUIRES: a flag set and the arguments of its command
+//MODIFIES: stderr
+//EFFECTS: parses the flags, and returns whether that worked and exactly one file was left over
+func (cmd *command) parseFlags(flags *flag.FlagSet, args []string) bool {
+	if err := flags.Parse(args); err != nil {
+		return false
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return false
+	}
+	return true
+}
+
+//REQUIRES: the name of a file, or - for standard input
+//MODIFIES: stdin
+//EFFECTS: returns the name to show in messages and the contents of the file
+func (cmd *command) read(name string) (string, string, error) {
+	if name == "-" {
+		data, err := ioutil.ReadAll(cmd.stdin)
+		return "<stdin>", string(data), err
+	}
+	data, err := ioutil.ReadFile(name)
+	return name, string(data), err
+}
+
+//REQUIRES: the name of a script and its source
+//MODIFIES: stderr
+//EFFECTS: parses and type checks the script, printing the problems that were found. Returns the program and whether it is fine to run
+//(warnings are printed but don't stop it)
+func (cmd *command) parseAndCheck(filename, src string) (*ast.Program, bool) {
+	p := parser.New(lexer.NewFile(filename, src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		diagnostic.RenderAll(cmd.stderr, src, p.Diagnostics())
+		return nil, false
+	}
+
+	diagnostics := checker.New().Check(program)
+	diagnostic.RenderAll(cmd.stderr, src, diagnostics)
+	return program, !diagnostics.HasErrors()
+}
@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"../object"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	script := write(t, dir, "hello.sqd", `for arg in args() { print("hello", arg); }`)
+
+	tests := []struct {
+		args     []string
+		stdout   string
+		exitCode int
+	}{
+		{[]string{"run", script, "--", "squid", "world"}, "hello squid\nhello world\n", ExitOK},
+		{[]string{"run", "-engine", "eval", script, "--", "squid"}, "hello squid\n", ExitOK},
+		{[]string{script, "--", "--", "-x"}, "hello --\nhello -x\n", ExitOK}, //only the first -- is ours
+		{[]string{"run", script}, "", ExitOK},
+		{[]string{"run", "-engine", "jit", script}, "", ExitUsage},
+		{[]string{"run"}, "", ExitUsage},
+		{[]string{"run", script, "extra"}, "", ExitUsage},
+		{[]string{"run", filepath.Join(dir, "missing.sqd")}, "", ExitError},
+		{[]string{"bogus"}, "", ExitUsage},
+	}
+
+	for _, tt := range tests {
+		stdout, _, exitCode := run(t, "", tt.args...)
+		if exitCode != tt.exitCode {
+			t.Errorf("%v: wrong exit code. want=%d, got=%d", tt.args, tt.exitCode, exitCode)
+		}
+		if stdout != tt.stdout {
+			t.Errorf("%v: wrong output. want=%q, got=%q", tt.args, tt.stdout, stdout)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	dir := t.TempDir()
+	broken := write(t, dir, "broken.sqd", "let x = 5;")
+	mistyped := write(t, dir, "mistyped.sqd", `let int x := "five";`)
+	failing := write(t, dir, "failing.sqd", "print(1);\nlet int[] xs := [];\nxs[3];")
+	nested := write(t, dir, "nested.sqd", "func f(int n) returns int {\n  return 1 / n;\n}\nf(0);")
+
+	tests := []struct {
+		args     []string
+		stderr   string
+		exitCode int
+	}{
+		{[]string{"run", broken}, "error[unexpected-token]", ExitError},
+		{[]string{"run", mistyped}, "error[type-mismatch]", ExitError},
+		{[]string{"run", failing}, "squid: " + failing + ":3: runtime error: index out of range [3] with length 0\n", ExitRuntime},
+		{[]string{"run", "-engine", "eval", failing}, "squid: " + failing + ":3: runtime error: index out of range [3] with length 0\n", ExitRuntime},
+		{[]string{"run", nested}, "squid: " + nested + ":2: runtime error: division by zero\n", ExitRuntime},
+		{[]string{"run", "-engine", "eval", nested}, "squid: " + nested + ":2: runtime error: division by zero\n", ExitRuntime}, //both engines point at the same line
+	}
+
+	for _, tt := range tests {
+		_, stderr, exitCode := run(t, "", tt.args...)
+		if exitCode != tt.exitCode {
+			t.Errorf("%v: wrong exit code. want=%d, got=%d", tt.args, tt.exitCode, exitCode)
+		}
+		if !strings.HasPrefix(stderr, tt.stderr) {
+			t.Errorf("%v: wrong error. want=%q, got=%q", tt.args, tt.stderr, stderr)
+		}
+	}
+}
+
+func TestStdin(t *testing.T) {
+	stdout, _, exitCode := run(t, `print(len(args()));`, "run", "-", "--", "a", "b")
+	if exitCode != ExitOK || stdout != "2\n" {
+		t.Errorf("wrong result. exit code=%d, output=%q", exitCode, stdout)
+	}
+
+	_, stderr, exitCode := run(t, "let int x := true;", "check", "-")
+	if exitCode != ExitError || !strings.Contains(stderr, "--> <stdin>:1:14") {
+		t.Errorf("wrong result. exit code=%d, error=%q", exitCode, stderr)
+	}
+}
+
+func TestCompileAndRun(t *testing.T) {
+	dir := t.TempDir()
+	script := write(t, dir, "fail.sqd", "print(args()[0]);\nlet int n := 1 / 0;")
+
+	if _, stderr, exitCode := run(t, "", "compile", script); exitCode != ExitOK {
+		t.Fatalf("compile failed with %d: %s", exitCode, stderr)
+	}
+
+	compiled := filepath.Join(dir, "fail.sqdc")
+	stdout, stderr, exitCode := run(t, "", compiled, "--", "squid")
+	if stdout != "squid\n" || exitCode != ExitRuntime {
+		t.Errorf("wrong result. exit code=%d, output=%q", exitCode, stdout)
+	}
+	if stderr != "squid: "+script+":2: runtime error: division by zero\n" { //the debug section remembers where the source was
+		t.Errorf("wrong error. got=%q", stderr)
+	}
+
+	if _, stderr, exitCode := run(t, "", "run", "-engine", "eval", compiled); exitCode != ExitUsage {
+		t.Errorf("the evaluator can't run bytecode. exit code=%d, error=%q", exitCode, stderr)
+	}
+}
+
+func TestParseTokensCheck(t *testing.T) {
+	tests := []struct {
+		args     []string
+		stdin    string
+		stdout   string
+		exitCode int
+	}{
+		{[]string{"parse", "-"}, "let int x := 1 + 2 * 3; print(x)", "let int x := (1 + (2 * 3));\nprint(x)\n", ExitOK},
+		{[]string{"parse", "-"}, "let x", "", ExitError},
+		{[]string{"tokens", "-"}, "set x :+ 1;", "1:1      SET        set\n1:5      IDENT      x\n1:7      :+         :+\n1:10     INT        1\n1:11     ;          ;\n1:12     EOF        \n", ExitOK},
+		{[]string{"tokens", "-"}, `"open`, "", ExitError},
+		{[]string{"check", "-"}, "let int x := 1;", "", ExitOK},
+		{[]string{"check", "-"}, "print(y);", "", ExitError},
+	}
+
+	for _, tt := range tests {
+		stdout, _, exitCode := run(t, tt.stdin, tt.args...)
+		if exitCode != tt.exitCode {
+			t.Errorf("%v on %q: wrong exit code. want=%d, got=%d", tt.args, tt.stdin, tt.exitCode, exitCode)
+		}
+		if tt.exitCode == ExitOK && stdout != tt.stdout {
+			t.Errorf("%v on %q: wrong output. want=%q, got=%q", tt.args, tt.stdin, tt.stdout, stdout)
+		}
+	}
+}
+
+func TestFmt(t *testing.T) {
+	dir := t.TempDir()
+	script := write(t, dir, "messy.sqd", "let int x:=1 ;//one\nif(x>0){print(x)}")
+	expected := "let int x := 1; //one\nif (x > 0) {\n    print(x);\n}\n"
+
+	stdout, _, exitCode := run(t, "", "fmt", script)
+	if exitCode != ExitOK || stdout != expected {
+		t.Errorf("wrong result. exit code=%d, output=%q", exitCode, stdout)
+	}
+
+	if _, _, exitCode := run(t, "", "fmt", "-w", script); exitCode != ExitOK {
+		t.Fatalf("fmt -w failed with %d", exitCode)
+	}
+	if data, _ := ioutil.ReadFile(script); string(data) != expected {
+		t.Errorf("fmt -w wrote the wrong thing. got=%q", data)
+	}
+
+	_, stderr, exitCode := run(t, "1 + /* two */ 2;", "fmt", "-")
+	if exitCode != ExitError || stderr != "squid: <stdin>:1:5: the comment /* two */ can't be kept where it is, move it in front of a statement\n" {
+		t.Errorf("wrong result. exit code=%d, error=%q", exitCode, stderr)
+	}
+}
+
+//runs the squid command with args, returning what it wrote and its exit code
+func run(t *testing.T, stdin string, args ...string) (string, string, int) {
+	var stdout, stderr bytes.Buffer
+	defer func() { object.Output = os.Stdout }()
+
+	exitCode := Run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), stderr.String(), exitCode
+}
+
+func write(t *testing.T, dir, name, src string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatalf("couldn't write %s: %s", path, err)
+	}
+	return path
+}
@@ -0,0 +1,285 @@
+//OVERVIEW: Code defines the instruction set of the vm. The compiler turns a program into Instructions (bytecode), which are opcodes each followed
+//by their operands, and the vm runs them. Operands are big endian and as wide as the Definition of the opcode says
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type Instructions []byte
+
+type Opcode byte
+
+const ( //these are our opcodes. The operands of each one are written next to it
+	OpConstant Opcode = iota // constant index. Pushes a constant from the constant pool
+
+	OpPop   //throws away the value on top of the stack (ie what an expression statement produced)
+	OpNull  //pushes null
+	OpTrue  //pushes true
+	OpFalse //pushes false
+
+	OpAdd //pops two values and pushes the result of the operator on them
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpEqual
+	OpNotEqual
+	OpLessThan
+	OpGreaterThan
+	OpLessEqual
+	OpGreaterEqual
+
+	OpMinus //pops a value and pushes the result of the prefix operator on it
+	OpBang
+
+	OpJump        // position. Continues at position
+	OpJumpIfFalse // position. Pops a condition, which has to be a boolean, and continues at position if it is false
+
+	OpGetGlobal     // global index
+	OpSetGlobal     // global index. Pops the new value of the global
+	OpGetLocal      // local index (counted from the start of the frame)
+	OpSetLocal      // local index. Pops the new value of the local
+	OpGetUpvalue    // upvalue index. Pushes a variable the running closure captured from a function it is nested in
+	OpSetUpvalue    // upvalue index. Pops the new value of the captured variable
+	OpCloseUpvalues // local index. Locals from this one up are going out of scope, so closures that captured them get their own copy
+	OpGetBuiltin    // builtin index (where it is in object.Builtins)
+
+	OpClosure     // constant index of a compiled function. Pushes a closure over it, capturing the variables the function needs
+	OpCall        // number of arguments. The function is below the arguments on the stack
+	OpReturnValue //returns the value on top of the stack from the running function (or ends the program with it)
+	OpReturn      //returns from the running function without a value
+
+	OpArray       // number of elements. Pops the elements and pushes an array of them
+	OpHash        // number of pairs. Pops a key and a value for every pair and pushes a map of them
+	OpIndex       //pops an index and what it indexes, and pushes the element it points at
+	OpSetIndex    // update operator. Pops a container, an index and a value and stores the value (see UpdateOperator)
+	OpRange       //pops the last and the first integer of a range and pushes the range
+	OpInterpolate // number of parts. Pops the parts of an interpolated string and pushes them joined together
+
+	OpStruct   // constant index of the name of the struct, number of fields. Pops the struct type and a name and a value for every field
+	OpGetField // constant index of the name of the field. Pops a struct (or an enum type) and pushes the field (or variant)
+	OpSetField // constant index of the name of the field, update operator. Pops a struct and a value and stores the value in the field
+
+	OpMatch // number of keys, number of values. Pops the keys of a key statement and the values of one of its locks, pushes whether it unlocks
+
+	OpIter     // number of loop variables. Pops what a for in loop goes over and pushes an iterator over it
+	OpIterNext // position. Pops an iterator and pushes the loop variables for its next element, or continues at position if there is none
+)
+
+//Definition says what an opcode is called (for disassembling) and how many bytes each of its operands takes up
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+
+	OpPop:   {"OpPop", []int{}},
+	OpNull:  {"OpNull", []int{}},
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpMod:          {"OpMod", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpJump:        {"OpJump", []int{2}},
+	OpJumpIfFalse: {"OpJumpIfFalse", []int{2}},
+
+	OpGetGlobal:     {"OpGetGlobal", []int{2}},
+	OpSetGlobal:     {"OpSetGlobal", []int{2}},
+	OpGetLocal:      {"OpGetLocal", []int{1}},
+	OpSetLocal:      {"OpSetLocal", []int{1}},
+	OpGetUpvalue:    {"OpGetUpvalue", []int{1}},
+	OpSetUpvalue:    {"OpSetUpvalue", []int{1}},
+	OpCloseUpvalues: {"OpCloseUpvalues", []int{1}},
+	OpGetBuiltin:    {"OpGetBuiltin", []int{1}},
+
+	OpClosure:     {"OpClosure", []int{2}},
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+
+	OpArray:       {"OpArray", []int{2}},
+	OpHash:        {"OpHash", []int{2}},
+	OpIndex:       {"OpIndex", []int{}},
+	OpSetIndex:    {"OpSetIndex", []int{1}},
+	OpRange:       {"OpRange", []int{}},
+	OpInterpolate: {"OpInterpolate", []int{2}},
+
+	OpStruct:   {"OpStruct", []int{2, 1}},
+	OpGetField: {"OpGetField", []int{2}},
+	OpSetField: {"OpSetField", []int{2, 1}},
+
+	OpMatch: {"OpMatch", []int{1, 1}},
+
+	OpIter:     {"OpIter", []int{1}},
+	OpIterNext: {"OpIterNext", []int{2}},
+}
+
+//REQUIRES: an opcode
+//MODIFIES:
+//EFFECTS: returns the definition of op, or an error if there is no such opcode
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+//REQUIRES: an opcode and as many operands as its definition has
+//MODIFIES:
+//EFFECTS: returns the instruction with the operands encoded after the opcode (an empty instruction if op isn't defined)
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		switch def.OperandWidths[i] {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += def.OperandWidths[i]
+	}
+
+	return instruction
+}
+
+//REQUIRES: the definition of an opcode and the bytes right after it
+//MODIFIES:
+//EFFECTS: returns the decoded operands and how many bytes they took up (the opposite of Make)
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 { return binary.BigEndian.Uint16(ins) }
+
+func ReadUint8(ins Instructions) uint8 { return uint8(ins[0]) }
+
+//REQUIRES:
+//MODIFIES:
+//EFFECTS: returns the instructions disassembled, one per line, each starting with the position of its opcode (ie '0003 OpConstant 1')
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			break
+		}
+
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if i+1+width > len(ins) { //the last instruction was cut short
+			fmt.Fprintf(&out, "ERROR: %s at %d is missing operands\n", def.Name, i)
+			break
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), len(def.OperandWidths))
+	}
+
+	out := def.Name
+	for _, o := range operands {
+		out += fmt.Sprintf(" %d", o)
+	}
+	return out
+}
+
+//REQUIRES: the operator of a set statement (ie ':+')
+//MODIFIES:
+//EFFECTS: returns the operand OpSetIndex and OpSetField take for it: the opcode of the infix operator an update applies (ie OpAdd for :+),
+//or 0 for := (0 is OpConstant, which is never an infix operator)
+func UpdateOperator(operator string) int {
+	switch operator {
+	case ":+":
+		return int(OpAdd)
+	case ":-":
+		return int(OpSub)
+	case ":*":
+		return int(OpMul)
+	case ":/":
+		return int(OpDiv)
+	default:
+		return 0
+	}
+}
+
+//LineTable says which line of the source each instruction was compiled from, so errors and tools can point back at the source.
+//Every entry covers the instructions from its Offset up to the Offset of the next entry
+type LineTable []LineEntry
+
+type LineEntry struct {
+	Offset int //the position of the first instruction compiled from the line
+	Line   int
+}
+
+//REQUIRES: the position of an instruction
+//MODIFIES:
+//EFFECTS: returns the line the instruction was compiled from, or 0 if the table doesn't cover it
+func (lt LineTable) Line(offset int) int {
+	line := 0
+	for _, entry := range lt { //the entries are in the order the instructions were emitted
+		if entry.Offset > offset {
+			break
+		}
+		line = entry.Line
+	}
+	return line
+}
@@ -0,0 +1,816 @@
+//OVERVIEW: The compiler walks the AST produced by the parser (after the checker is happy with it) and lowers it to bytecode for the vm.
+//Literals go into a constant pool, names are resolved ahead of time with symbol tables, and control flow becomes jumps
+package compiler
+
+import (
+	"fmt"
+
+	"../ast"
+	"../code"
+	"../object"
+	"../token"
+)
+
+//what compiling a program produces, which is everything the vm needs to run it
+type Bytecode struct {
+	Instructions code.Instructions
+	NumLocals    int //the locals of blocks at the top of the program, which live in the frame the program runs in
+	Constants    []object.Object
+
+	Lines  code.LineTable //which lines of the source the instructions were compiled from (functions have their own)
+	Source string         //the file the program was compiled from, empty when it didn't come from a file
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+//CompilationScope holds the instructions of the function being compiled. Every function literal gets its own
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	lines               code.LineTable
+
+	loops []*loop //the loops we are currently inside of, innermost last
+}
+
+//the jumps of break and continue statements in a loop, which can only be pointed at the right place once the whole loop is compiled
+type loop struct {
+	bodyStart int //the first local of the body, which is closed before breaking out
+	breaks    []int
+	continues []int
+}
+
+//This is what is constructed; the main template/structure of the compiler
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+	source      string
+
+	scopes     []CompilationScope
+	scopeIndex int
+}
+
+//REQUIRES:
+//MODIFIES:
+//EFFECTS: creates a compiler with an empty constant pool and the builtins defined
+func New() *Compiler {
+	symbolTable := NewSymbolTable()
+	for i, b := range object.Builtins {
+		symbolTable.DefineBuiltin(i, b.Name)
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{{}},
+	}
+}
+
+//REQUIRES:
+//MODIFIES:
+//EFFECTS: returns what has been compiled so far
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		NumLocals:    c.symbolTable.NumLocals,
+		Constants:    c.constants,
+		Lines:        c.scopes[c.scopeIndex].lines,
+		Source:       c.source,
+	}
+}
+
+//REQUIRES: a node of a program that type checked
+//MODIFIES: the instructions and constants of the compiler
+//EFFECTS: compiles the node, returning an error for the few things that can't be compiled (ie a name that was never declared)
+func (c *Compiler) Compile(node ast.Node) error {
+	if stmt, ok := node.(ast.Statement); ok {
+		c.markLine(stmt.Pos())
+	}
+
+	switch node := node.(type) {
+
+	// Statements
+	case *ast.Program:
+		return c.compileProgram(node)
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		return c.compileBlock(node, false)
+
+	case *ast.LetStatement:
+		if err := c.Compile(node.Value); err != nil { //compiled before the name is declared, so 'let int x := x + 1' uses the x from further out
+			return err
+		}
+		c.setSymbol(c.symbolTable.Define(node.Name.Value))
+
+	case *ast.SetStatement:
+		return c.compileSetStatement(node)
+
+	case *ast.ReturnStatement:
+		if node.ReturnValue == nil { //a bare return
+			c.emit(code.OpNull)
+		} else if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.KeyStatement:
+		return c.compileKeyStatement(node, false)
+
+	case *ast.ForInStatement:
+		return c.compileForInStatement(node)
+
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+
+	case *ast.BreakStatement:
+		l := c.currentLoop()
+		c.emit(code.OpCloseUpvalues, l.bodyStart)
+		l.breaks = append(l.breaks, c.emit(code.OpJump, 9999)) //pointed at the end of the loop once we know where that is
+
+	case *ast.ContinueStatement:
+		l := c.currentLoop()
+		l.continues = append(l.continues, c.emit(code.OpJump, 9999))
+
+	case *ast.FunctionDeclaration:
+		symbol := c.symbolTable.Define(node.Name.Value) //declared before the body is compiled, so the function can call itself
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		c.setSymbol(symbol)
+
+	case *ast.StructDeclaration:
+		c.emit(code.OpConstant, c.addConstant(object.NewStructType(node.Name.Value, node.Fields)))
+		c.setSymbol(c.symbolTable.Define(node.Name.Value))
+
+	case *ast.EnumDeclaration:
+		variants := []string{}
+		for _, v := range node.Variants {
+			variants = append(variants, v.Value)
+		}
+		c.emit(code.OpConstant, c.addConstant(object.NewEnumType(node.Name.Value, variants)))
+		c.setSymbol(c.symbolTable.Define(node.Name.Value))
+
+	// Expressions
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			if err := c.Compile(part); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpInterpolate, len(node.Parts))
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		return c.compileInfixExpression(node)
+
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+
+	case *ast.RangeExpression:
+		if err := c.Compile(node.First); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Last); err != nil {
+			return err
+		}
+		c.emit(code.OpRange)
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs { //in the order they were written, so a key written twice keeps the last value given for it
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs))
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.StructLiteral:
+		if err := c.Compile(node.Name); err != nil { //the struct type is bound to the name like any other value
+			return err
+		}
+		for _, f := range node.Fields {
+			c.emit(code.OpConstant, c.addConstant(&object.String{Value: f.Name.Value}))
+			if err := c.Compile(f.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpStruct, c.addConstant(&object.String{Value: node.Name.Value}), len(node.Fields))
+
+	case *ast.SelectorExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		c.emit(code.OpGetField, c.addConstant(&object.String{Value: node.Field.Value}))
+
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return fmt.Errorf("undefined variable %s", node.Value)
+		}
+		c.loadSymbol(symbol)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+
+	case *ast.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+
+	// Placeholders the parser leaves where code was broken
+	case *ast.BadStatement, *ast.BadExpression:
+		return fmt.Errorf("cannot compile code that failed to parse: %s", node.Pos())
+	}
+
+	return nil
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Statement compilation
+
+//compiles every statement of the program. Like evalProgram, the program ends with the value of its last statement if that has one
+func (c *Compiler) compileProgram(program *ast.Program) error {
+	c.source = program.Pos().Filename
+
+	for i, stmt := range program.Statements {
+		if i == len(program.Statements)-1 && hasValue(stmt) {
+			if err := c.compileValue(stmt); err != nil {
+				return err
+			}
+			c.emit(code.OpReturnValue)
+			return nil
+		}
+		if err := c.Compile(stmt); err != nil {
+			return err
+		}
+	}
+
+	c.emit(code.OpReturn)
+	return nil
+}
+
+//returns whether the statement leaves a value behind when it is the last one in a block (ie 'x + 1' or a key whose lock ends with one)
+func hasValue(stmt ast.Statement) bool {
+	switch stmt.(type) {
+	case *ast.ExpressionStatement, *ast.KeyStatement:
+		return true
+	default:
+		return false
+	}
+}
+
+//compiles a statement so that it leaves its value on the stack, or null when it doesn't have one
+func (c *Compiler) compileValue(stmt ast.Statement) error {
+	c.markLine(stmt.Pos())
+
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		return c.Compile(stmt.Expression)
+	case *ast.KeyStatement:
+		return c.compileKeyStatement(stmt, true)
+	default:
+		if err := c.Compile(stmt); err != nil {
+			return err
+		}
+		c.emit(code.OpNull)
+		return nil
+	}
+}
+
+//REQUIRES: a block, and whether its value is needed (ie for the branches of an if)
+//MODIFIES: the instructions of the compiler
+//EFFECTS: compiles the statements of the block in a new block of the symbol table. When the value is needed it is left on the stack:
+//the value of the last statement, or null if it doesn't have one
+func (c *Compiler) compileBlock(block *ast.BlockStatement, value bool) error {
+	c.symbolTable.EnterBlock()
+
+	for i, stmt := range block.Statements {
+		var err error
+		if value && i == len(block.Statements)-1 {
+			err = c.compileValue(stmt)
+		} else {
+			err = c.Compile(stmt)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if value && len(block.Statements) == 0 {
+		c.emit(code.OpNull)
+	}
+
+	c.leaveBlock()
+	return nil
+}
+
+//ends the innermost block of the symbol table, closing its locals if a function captured any of them
+func (c *Compiler) leaveBlock() {
+	if first, captured := c.symbolTable.LeaveBlock(); captured {
+		c.emit(code.OpCloseUpvalues, first)
+	}
+}
+
+//set statements push what is being set, then the value, and then store the value. For a variable an update operator is applied with
+//the usual infix opcode, for an element or a field OpSetIndex and OpSetField apply it themselves (see code.UpdateOperator)
+func (c *Compiler) compileSetStatement(ss *ast.SetStatement) error {
+	switch target := ss.Target.(type) {
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if !ok || symbol.Scope == BuiltinScope {
+			return fmt.Errorf("cannot set undeclared variable: %s", target.Value)
+		}
+
+		operator := code.UpdateOperator(ss.Operator)
+		if operator != 0 { //set x :+ 3 is set x := x + 3
+			c.loadSymbol(symbol)
+		}
+		if err := c.Compile(ss.Value); err != nil {
+			return err
+		}
+		if operator != 0 {
+			c.emit(code.Opcode(operator))
+		}
+		c.setSymbol(symbol)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+		if err := c.Compile(ss.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpSetIndex, code.UpdateOperator(ss.Operator))
+
+	case *ast.SelectorExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(ss.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpSetField, c.addConstant(&object.String{Value: target.Field.Value}), code.UpdateOperator(ss.Operator))
+
+	default:
+		return fmt.Errorf("cannot set %s", ss.Target)
+	}
+
+	return nil
+}
+
+//REQUIRES: a key statement, and whether its value is needed (see compileValue)
+//MODIFIES: the instructions of the compiler
+//EFFECTS: compiles the keys into locals of their own, then every lock as a test of those keys against its values (OpMatch) that jumps
+//past the body of the lock when it doesn't unlock. Only the first lock that unlocks runs, then we jump to the end
+func (c *Compiler) compileKeyStatement(ks *ast.KeyStatement, value bool) error {
+	c.symbolTable.EnterBlock()
+
+	keys := []Symbol{}
+	for i, k := range ks.Keys {
+		if err := c.Compile(k); err != nil {
+			return err
+		}
+		key := c.symbolTable.Define(fmt.Sprintf("%%key%d", i)) //the % keeps it from clashing with a name in the program
+		c.setSymbol(key)
+		keys = append(keys, key)
+	}
+
+	ends := []int{}
+	for _, lock := range ks.Locks {
+		for _, key := range keys {
+			c.loadSymbol(key)
+		}
+		for _, v := range lock.Values {
+			if err := c.Compile(v); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpMatch, len(keys), len(lock.Values))
+		next := c.emit(code.OpJumpIfFalse, 9999)
+
+		if err := c.compileBlock(lock.Body, value); err != nil {
+			return err
+		}
+		ends = append(ends, c.emit(code.OpJump, 9999))
+
+		c.changeOperand(next, len(c.currentInstructions()))
+	}
+
+	if ks.Else != nil { //nothing unlocked, so the lock else runs
+		if err := c.compileBlock(ks.Else, value); err != nil {
+			return err
+		}
+	} else if value {
+		c.emit(code.OpNull)
+	}
+
+	for _, end := range ends {
+		c.changeOperand(end, len(c.currentInstructions()))
+	}
+
+	c.leaveBlock()
+	return nil
+}
+
+//REQUIRES: a for in statement
+//MODIFIES: the instructions of the compiler
+//EFFECTS: compiles the loop as an iterator kept in a local, and a body that starts by storing the next element in the loop variables.
+//Like in the evaluator every iteration has its own loop variables, closures that captured them are closed before the next one
+func (c *Compiler) compileForInStatement(fs *ast.ForInStatement) error {
+	if err := c.Compile(fs.Iterable); err != nil {
+		return err
+	}
+
+	variables := 1
+	if fs.Value != nil {
+		variables = 2
+	}
+	c.emit(code.OpIter, variables)
+
+	c.symbolTable.EnterBlock()
+	iterator := c.symbolTable.Define("%iterator")
+	c.setSymbol(iterator)
+
+	start := len(c.currentInstructions())
+	c.loadSymbol(iterator)
+	exit := c.emit(code.OpIterNext, 9999)
+
+	l := &loop{bodyStart: c.symbolTable.EnterBlock()}
+	c.pushLoop(l)
+
+	variable := c.symbolTable.Define(fs.Variable.Value)
+	if fs.Value != nil { //the element is pushed after the key, so it is stored first
+		c.setSymbol(c.symbolTable.Define(fs.Value.Value))
+	}
+	c.setSymbol(variable)
+
+	for _, stmt := range fs.Body.Statements {
+		if err := c.Compile(stmt); err != nil {
+			return err
+		}
+	}
+
+	c.endLoop(l, start)
+	c.changeOperand(exit, len(c.currentInstructions()))
+	c.patchBreaks(l)
+
+	c.leaveBlock()
+	return nil
+}
+
+//compiles the loop as a condition that jumps past the body once it is false, and a body that jumps back to the condition
+func (c *Compiler) compileWhileStatement(ws *ast.WhileStatement) error {
+	start := len(c.currentInstructions())
+	if err := c.Compile(ws.Condition); err != nil {
+		return err
+	}
+	exit := c.emit(code.OpJumpIfFalse, 9999)
+
+	l := &loop{bodyStart: c.symbolTable.EnterBlock()}
+	c.pushLoop(l)
+	for _, stmt := range ws.Body.Statements {
+		if err := c.Compile(stmt); err != nil {
+			return err
+		}
+	}
+
+	c.endLoop(l, start)
+	c.changeOperand(exit, len(c.currentInstructions()))
+	c.patchBreaks(l)
+	return nil
+}
+
+func (c *Compiler) pushLoop(l *loop) {
+	c.scopes[c.scopeIndex].loops = append(c.scopes[c.scopeIndex].loops, l)
+}
+
+//ends the body of the innermost loop: continue statements jump to here, the locals of the iteration are closed and we jump back to start
+func (c *Compiler) endLoop(l *loop, start int) {
+	for _, pos := range l.continues {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	c.leaveBlock()
+	c.emit(code.OpJump, start)
+
+	loops := c.scopes[c.scopeIndex].loops
+	c.scopes[c.scopeIndex].loops = loops[:len(loops)-1]
+}
+
+//points the break statements of the loop at the instruction after it
+func (c *Compiler) patchBreaks(l *loop) {
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+}
+
+//the parser makes sure break and continue are only ever inside of a loop
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	return loops[len(loops)-1]
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Expression compilation
+
+//the opcode of every infix operator besides and/or, which need jumps
+var infixOperators = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"=":  code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<":  code.OpLessThan,
+	">":  code.OpGreaterThan,
+	"<=": code.OpLessEqual,
+	">=": code.OpGreaterEqual,
+}
+
+func (c *Compiler) compileInfixExpression(ie *ast.InfixExpression) error {
+	if ie.Operator == "and" || ie.Operator == "or" {
+		return c.compileLogicalExpression(ie)
+	}
+
+	if err := c.Compile(ie.Left); err != nil {
+		return err
+	}
+	if err := c.Compile(ie.Right); err != nil {
+		return err
+	}
+
+	op, ok := infixOperators[ie.Operator]
+	if !ok {
+		return fmt.Errorf("unknown operator %s", ie.Operator)
+	}
+	c.emit(op)
+	return nil
+}
+
+//the right side of and/or is only run when the left side doesn't already decide the result (false for and, true for or)
+func (c *Compiler) compileLogicalExpression(ie *ast.InfixExpression) error {
+	if err := c.Compile(ie.Left); err != nil {
+		return err
+	}
+	toRight := c.emit(code.OpJumpIfFalse, 9999)
+
+	if ie.Operator == "and" { //the left side was true, so the right side decides
+		if err := c.Compile(ie.Right); err != nil {
+			return err
+		}
+		end := c.emit(code.OpJump, 9999)
+		c.changeOperand(toRight, len(c.currentInstructions()))
+		c.emit(code.OpFalse)
+		c.changeOperand(end, len(c.currentInstructions()))
+		return nil
+	}
+
+	c.emit(code.OpTrue) //or, and the left side was true
+	end := c.emit(code.OpJump, 9999)
+	c.changeOperand(toRight, len(c.currentInstructions()))
+	if err := c.Compile(ie.Right); err != nil {
+		return err
+	}
+	c.changeOperand(end, len(c.currentInstructions()))
+	return nil
+}
+
+//an if always leaves a value: the value of the branch that ran, or null when the condition was false and there is no else
+func (c *Compiler) compileIfExpression(ie *ast.IfExpression) error {
+	if err := c.Compile(ie.Condition); err != nil {
+		return err
+	}
+	toElse := c.emit(code.OpJumpIfFalse, 9999)
+
+	if err := c.compileBlock(ie.Consequence, true); err != nil {
+		return err
+	}
+	end := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(toElse, len(c.currentInstructions()))
+	if ie.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBlock(ie.Alternative, true); err != nil {
+		return err
+	}
+
+	c.changeOperand(end, len(c.currentInstructions()))
+	return nil
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Functions
+
+//REQUIRES: a function literal
+//MODIFIES: the constants and instructions of the compiler
+//EFFECTS: compiles the body into a compiled function in the constant pool, with its parameters as its first locals, and emits the
+//OpClosure that creates the function value when the program runs
+func (c *Compiler) compileFunctionLiteral(fl *ast.FunctionLiteral) error {
+	c.enterScope()
+
+	for _, p := range fl.Parameters {
+		c.symbolTable.Define(p.Name.Value)
+	}
+	for _, stmt := range fl.Body.Statements {
+		if err := c.Compile(stmt); err != nil {
+			c.leaveScope()
+			return err
+		}
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) { //we only hand values back through return
+		c.emit(code.OpReturn)
+	}
+
+	upvalues := c.symbolTable.Upvalues
+	numLocals := c.symbolTable.NumLocals
+	lines := c.scopes[c.scopeIndex].lines
+	instructions := c.leaveScope()
+
+	if numLocals > 256 { //OpGetLocal and OpSetLocal only have a byte for the index
+		return fmt.Errorf("too many local variables in %s", fl.Name)
+	}
+
+	fn := &object.CompiledFunction{
+		Name:          fl.Name,
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(fl.Parameters),
+		Upvalues:      upvalues,
+		Lines:         lines,
+	}
+	c.emit(code.OpClosure, c.addConstant(fn))
+	return nil
+}
+
+//starts compiling a new function, with its own instructions and symbol table
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+//finishes compiling the innermost function and returns its instructions
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% COMPILER HELPER METHODS
+
+//pushes the value of the symbol
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case UpvalueScope:
+		c.emit(code.OpGetUpvalue, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	}
+}
+
+//pops the value on top of the stack into the symbol (builtins can't be set, see compileSetStatement)
+func (c *Compiler) setSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	case UpvalueScope:
+		c.emit(code.OpSetUpvalue, s.Index)
+	}
+}
+
+//adds obj to the constant pool and returns its index
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+//adds an instruction to the function being compiled and returns its position
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = EmittedInstruction{Opcode: op, Position: pos}
+
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	pos := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	return pos
+}
+
+//records that the instructions emitted from here on are compiled from the line pos is on
+func (c *Compiler) markLine(pos token.Position) {
+	if !pos.IsValid() {
+		return
+	}
+
+	scope := &c.scopes[c.scopeIndex]
+	offset := len(scope.instructions)
+	if n := len(scope.lines); n > 0 {
+		last := &scope.lines[n-1]
+		if last.Line == pos.Line {
+			return
+		}
+		if last.Offset == offset { //nothing was compiled from the last line, so this one takes its place
+			last.Line = pos.Line
+			return
+		}
+	}
+	scope.lines = append(scope.lines, code.LineEntry{Offset: offset, Line: pos.Line})
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+//replaces the (first) operand of the instruction at pos, which is how jumps are pointed at code that wasn't compiled yet when they were emitted
+func (c *Compiler) changeOperand(pos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[pos])
+	ins := code.Make(op, operand)
+
+	for i := 0; i < len(ins); i++ {
+		c.scopes[c.scopeIndex].instructions[pos+i] = ins[i]
+	}
+}
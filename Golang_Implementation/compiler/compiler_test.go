@@ -0,0 +1,349 @@
+package compiler
+
+import (
+	"fmt"
+	"testing"
+
+	"../ast"
+	"../code"
+	"../lexer"
+	"../object"
+	"../parser"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue), //the program ends with the value of its last expression
+			},
+		},
+		{
+			input:             "1 % 2; -3",
+			expectedConstants: []interface{}{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMod),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpMinus),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "if (true) { 10 }; 3333",
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),            // 0000
+				code.Make(code.OpJumpIfFalse, 10), // 0001
+				code.Make(code.OpConstant, 0),     // 0004
+				code.Make(code.OpJump, 11),        // 0007
+				code.Make(code.OpNull),            // 0010
+				code.Make(code.OpPop),             // 0011
+				code.Make(code.OpConstant, 1),     // 0012
+				code.Make(code.OpReturnValue),     // 0015
+			},
+		},
+		{
+			input:             "true and false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),           // 0000
+				code.Make(code.OpJumpIfFalse, 8), // 0001
+				code.Make(code.OpFalse),          // 0004
+				code.Make(code.OpJump, 9),        // 0005
+				code.Make(code.OpFalse),          // 0008
+				code.Make(code.OpReturnValue),    // 0009
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestVariables(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let int x := 1; set x :+ 2; x",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "if (true) { let int y := 1; y }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),            // 0000
+				code.Make(code.OpJumpIfFalse, 14), // 0001
+				code.Make(code.OpConstant, 0),     // 0004, a let in a block is a local of the frame the program runs in
+				code.Make(code.OpSetLocal, 0),     // 0007
+				code.Make(code.OpGetLocal, 0),     // 0009
+				code.Make(code.OpJump, 15),        // 0011
+				code.Make(code.OpNull),            // 0014
+				code.Make(code.OpReturnValue),     // 0015
+			},
+		},
+		{
+			input:             "print(1)",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "func add(int a, int b) returns int { return a + b; }; add(1, 2)",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+				2,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "func nothing() { }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpReturn),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+func adder(int x) returns func(int) returns int {
+  return func(int y) returns int { return x + y; };
+}`
+
+	tests := []compilerTestCase{
+		{
+			input: input,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetUpvalue, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpClosure, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpReturn),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+
+	program := parse(input)
+	compiler := New()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	inner := compiler.Bytecode().Constants[0].(*object.CompiledFunction)
+	if len(inner.Upvalues) != 1 || inner.Upvalues[0] != (object.UpvalueRef{IsLocal: true, Index: 0}) {
+		t.Errorf("wrong upvalues. want=[{true 0}], got=%v", inner.Upvalues)
+	}
+}
+
+func TestSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineBuiltin(0, "print")
+
+	a := global.Define("a")
+	if a != (Symbol{Name: "a", Scope: GlobalScope, Index: 0}) {
+		t.Errorf("wrong symbol for a. got=%+v", a)
+	}
+
+	first := global.EnterBlock()
+	b := global.Define("b")
+	if first != 0 || b != (Symbol{Name: "b", Scope: LocalScope, Index: 0}) { //names in a block are locals even at the top of the program
+		t.Errorf("wrong symbol for b. got=%+v (block starts at %d)", b, first)
+	}
+
+	fn := NewEnclosedSymbolTable(global)
+	c := fn.Define("c")
+	if c != (Symbol{Name: "c", Scope: LocalScope, Index: 0}) {
+		t.Errorf("wrong symbol for c. got=%+v", c)
+	}
+
+	tests := []struct {
+		name     string
+		expected Symbol
+	}{
+		{"a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{"b", Symbol{Name: "b", Scope: UpvalueScope, Index: 0}},
+		{"c", Symbol{Name: "c", Scope: LocalScope, Index: 0}},
+		{"print", Symbol{Name: "print", Scope: BuiltinScope, Index: 0}},
+		{"b", Symbol{Name: "b", Scope: UpvalueScope, Index: 0}}, //using it again doesn't add another upvalue
+	}
+	for _, tt := range tests {
+		symbol, ok := fn.Resolve(tt.name)
+		if !ok {
+			t.Errorf("name %s not resolvable", tt.name)
+			continue
+		}
+		if symbol != tt.expected {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", tt.name, tt.expected, symbol)
+		}
+	}
+	if len(fn.Upvalues) != 1 {
+		t.Errorf("wrong number of upvalues. want=1, got=%d", len(fn.Upvalues))
+	}
+
+	if _, ok := fn.Resolve("nope"); ok {
+		t.Errorf("name nope resolved, but was never declared")
+	}
+
+	start, captured := global.LeaveBlock()
+	if start != 0 || !captured {
+		t.Errorf("wrong block end. want=(0, true), got=(%d, %t)", start, captured)
+	}
+	global.EnterBlock()
+	if d := global.Define("d"); d.Index != 0 { //the block before it ended, so its local is used again
+		t.Errorf("expected d to reuse local 0, got=%d", d.Index)
+	}
+	if global.NumLocals != 1 {
+		t.Errorf("wrong NumLocals. want=1, got=%d", global.NumLocals)
+	}
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := compiler.Bytecode()
+
+		if err := testInstructions(tt.expectedInstructions, bytecode.Instructions); err != nil {
+			t.Fatalf("testInstructions failed for %q: %s", tt.input, err)
+		}
+		if err := testConstants(tt.expectedConstants, bytecode.Constants); err != nil {
+			t.Fatalf("testConstants failed for %q: %s", tt.input, err)
+		}
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func testInstructions(expected []code.Instructions, actual code.Instructions) error {
+	concatted := concatInstructions(expected)
+
+	if len(actual) != len(concatted) {
+		return fmt.Errorf("wrong instructions length.\nwant=%q\ngot =%q", concatted, actual)
+	}
+	for i, ins := range concatted {
+		if actual[i] != ins {
+			return fmt.Errorf("wrong instruction at %d.\nwant=%q\ngot =%q", i, concatted, actual)
+		}
+	}
+
+	return nil
+}
+
+func concatInstructions(s []code.Instructions) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func testConstants(expected []interface{}, actual []object.Object) error {
+	if len(expected) != len(actual) {
+		return fmt.Errorf("wrong number of constants. got=%d, want=%d", len(actual), len(expected))
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok || integer.Value != int64(constant) {
+				return fmt.Errorf("constant %d - wrong integer. want=%d, got=%s", i, constant, actual[i].Inspect())
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				return fmt.Errorf("constant %d - not a function: %T", i, actual[i])
+			}
+			if err := testInstructions(constant, fn.Instructions); err != nil {
+				return fmt.Errorf("constant %d - testInstructions failed: %s", i, err)
+			}
+		}
+	}
+
+	return nil
+}
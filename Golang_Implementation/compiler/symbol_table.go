@@ -0,0 +1,160 @@
+package compiler
+
+import "../object"
+
+type SymbolScope string
+
+const ( //where the value of a name is kept while the program runs
+	GlobalScope  SymbolScope = "GLOBAL"  //names declared at the top of the program
+	LocalScope   SymbolScope = "LOCAL"   //parameters, and names declared in a function or in a block
+	UpvalueScope SymbolScope = "UPVALUE" //locals of an enclosing function that a closure captured
+	BuiltinScope SymbolScope = "BUILTIN" //the builtins, found when nothing in the program has the name
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int //which global, local, upvalue or builtin it is
+}
+
+//A SymbolTable keeps track of the names of one function (or of the program itself, which is the outermost table), the same way
+//checker.Scope keeps track of their types. Blocks inside the function get their own names, but share the locals of the function
+type SymbolTable struct {
+	Outer *SymbolTable //the table of the enclosing function (nil for the outermost table)
+
+	blocks     []map[string]Symbol //the names declared in each block we are inside of, innermost last
+	blockStart []int               //the first local of each of those blocks
+	builtins   map[string]Symbol   //only used by the outermost table
+
+	numGlobals int
+	nextLocal  int          //the local the next name will be stored in (locals of blocks that ended are used again)
+	NumLocals  int          //the most locals that are ever in use at once, which is how much room a call needs
+	captured   map[int]bool //the locals an inner function captured
+
+	Upvalues []object.UpvalueRef //the variables of enclosing functions this function uses, in the order of their upvalue indexes
+}
+
+//REQUIRES:
+//MODIFIES:
+//EFFECTS: creates the outermost symbol table, where names declared outside of any block are globals
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		blocks:     []map[string]Symbol{make(map[string]Symbol)},
+		blockStart: []int{0},
+		builtins:   make(map[string]Symbol),
+		captured:   make(map[int]bool),
+	}
+}
+
+//REQUIRES: the table of the enclosing function
+//MODIFIES:
+//EFFECTS: creates the table of a function, whose names are all locals
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+//REQUIRES: a name being declared
+//MODIFIES: the innermost block of the table
+//EFFECTS: declares name and returns the symbol it got. Declaring a name again gives it a new global or local
+func (s *SymbolTable) Define(name string) Symbol {
+	symbol := Symbol{Name: name, Scope: LocalScope, Index: s.nextLocal}
+	if s.Outer == nil && len(s.blocks) == 1 {
+		symbol.Scope = GlobalScope
+		symbol.Index = s.numGlobals
+		s.numGlobals++
+	} else {
+		s.nextLocal++
+		if s.nextLocal > s.NumLocals {
+			s.NumLocals = s.nextLocal
+		}
+	}
+
+	s.blocks[len(s.blocks)-1][name] = symbol
+	return symbol
+}
+
+//REQUIRES: the index of a builtin in object.Builtins and its name
+//MODIFIES: the builtins of the table
+//EFFECTS: makes name refer to the builtin when nothing else in the program is called that
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Scope: BuiltinScope, Index: index}
+	s.builtins[name] = symbol
+	return symbol
+}
+
+//REQUIRES: a name to look up
+//MODIFIES: the upvalues of the table, when the name belongs to a local of an enclosing function
+//EFFECTS: returns the symbol name refers to, checking the blocks from the innermost out and then the enclosing functions, and whether it was found
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	for i := len(s.blocks) - 1; i >= 0; i-- {
+		if symbol, ok := s.blocks[i][name]; ok {
+			return symbol, true
+		}
+	}
+
+	if s.Outer == nil {
+		symbol, ok := s.builtins[name]
+		return symbol, ok
+	}
+
+	symbol, ok := s.Outer.Resolve(name)
+	if !ok || symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope { //these are the same from everywhere, nothing to capture
+		return symbol, ok
+	}
+
+	if symbol.Scope == LocalScope {
+		s.Outer.captured[symbol.Index] = true
+	}
+	return s.defineUpvalue(symbol), true
+}
+
+//returns the upvalue of this function for the symbol of the enclosing function, adding it if this is the first time it is used
+func (s *SymbolTable) defineUpvalue(original Symbol) Symbol {
+	ref := object.UpvalueRef{IsLocal: original.Scope == LocalScope, Index: original.Index}
+
+	index := -1
+	for i, u := range s.Upvalues {
+		if u == ref {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		s.Upvalues = append(s.Upvalues, ref)
+		index = len(s.Upvalues) - 1
+	}
+
+	return Symbol{Name: original.Name, Scope: UpvalueScope, Index: index}
+}
+
+//REQUIRES:
+//MODIFIES: the blocks of the table
+//EFFECTS: starts a new block, whose names go out of scope again at LeaveBlock. Returns the first local the block will use
+func (s *SymbolTable) EnterBlock() int {
+	s.blocks = append(s.blocks, make(map[string]Symbol))
+	s.blockStart = append(s.blockStart, s.nextLocal)
+	return s.nextLocal
+}
+
+//REQUIRES: a block started by EnterBlock
+//MODIFIES: the blocks of the table
+//EFFECTS: ends the innermost block so its locals can be used again. Returns the first local it used and whether an inner function captured
+//any of its locals, in which case they have to be closed (see code.OpCloseUpvalues)
+func (s *SymbolTable) LeaveBlock() (int, bool) {
+	first := s.blockStart[len(s.blockStart)-1]
+	s.blocks = s.blocks[:len(s.blocks)-1]
+	s.blockStart = s.blockStart[:len(s.blockStart)-1]
+
+	captured := false
+	for i := first; i < s.nextLocal; i++ {
+		if s.captured[i] {
+			captured = true
+			delete(s.captured, i)
+		}
+	}
+	s.nextLocal = first
+
+	return first, captured
+}
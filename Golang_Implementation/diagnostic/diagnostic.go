@@ -0,0 +1,159 @@
+//OVERVIEW: Diagnostics are the problems the parser and checker find in a program. Instead of bare strings, every diagnostic knows how bad it is,
+//what kind of problem it is, and exactly which part of the source it is about, so tools can sort them, filter them and point at the code
+package diagnostic
+
+import (
+	"sort"
+
+	"../token"
+)
+
+type Severity int
+
+const ( //how bad a diagnostic is
+	Error   Severity = iota //the program can't run
+	Warning                 //the program can run, but probably doesn't do what was intended
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	default:
+		return "unknown"
+	}
+}
+
+type Code string //which kind of problem a diagnostic is about, so tools don't have to pick apart the message
+
+const ( //these are the codes the lexer and parser use
+	InvalidUTF8         Code = "invalid-utf8"           //source that isn't valid UTF-8
+	UnterminatedComment Code = "unterminated-comment"   //a /* without a matching */
+	UnterminatedString  Code = "unterminated-string"    //a " without a matching " on the same line (or a ${ without a matching })
+	InvalidEscape       Code = "invalid-escape"         //a \ in a string that isn't followed by one of the escapes we know (ie "\q")
+	DoubleEquals        Code = "double-equals"          //== where = was meant (ie 'x == 1')
+	UnexpectedToken     Code = "unexpected-token"       //the next token wasn't one we could use there (ie 'let int x = 5')
+	ExpectedExpression  Code = "expected-expression"    //a token that can't start an expression was found where one was needed
+	InvalidInteger      Code = "invalid-integer"        //an integer literal that doesn't fit in an int, or has digits that don't belong to its base (ie 0b102)
+	InvalidFloat        Code = "invalid-float"          //a float literal that is malformed (ie 1e) or too big for a float
+	InvalidKey          Code = "invalid-key-statement"  //a key statement whose locks don't line up with its keys
+	MisplacedLoopExit   Code = "misplaced-loop-control" //break or continue outside of a loop
+	TooManyErrors       Code = "too-many-errors"        //the parser gave up reporting errors
+)
+
+const ( //these are the codes the checker uses
+	TypeMismatch     Code = "type-mismatch"        //a value of one type where another was expected
+	UnknownType      Code = "unknown-type"         //a type name that doesn't exist
+	Undefined        Code = "undefined"            //a name that was never declared
+	InvalidOperation Code = "invalid-operation"    //an operator used on a type it doesn't work on (ie -true)
+	InvalidReturn    Code = "invalid-return"       //a return that doesn't match the function it is in
+	MissingReturn    Code = "missing-return"       //a function with a return type that can end without returning
+	WrongArgCount    Code = "wrong-argument-count" //a call with more or less arguments than the function has parameters
+	NotCallable      Code = "not-callable"         //a call on something that isn't a function
+	NotIterable      Code = "not-iterable"         //a for-in loop over something that can't be looped over
+	NotIndexable     Code = "not-indexable"        //an index on something that isn't an array or a map (ie 5[0])
+	InvalidMapKey    Code = "invalid-map-key"      //a map key of a type that can't be one (ie map[float]int)
+	UnknownField     Code = "unknown-field"        //a field that the struct doesn't have (ie p.z when p is a Point)
+	DuplicateField   Code = "duplicate-field"      //a field declared or given a value twice
+	MissingField     Code = "missing-field"        //a struct literal that doesn't give every field a value
+	UnknownVariant   Code = "unknown-variant"      //a variant that the enum doesn't have (ie Month.Smarch)
+	DuplicateVariant Code = "duplicate-variant"    //a variant declared twice
+	NonExhaustiveKey Code = "non-exhaustive-key"   //a key over an enum without a lock else that doesn't have a lock for every variant (a warning)
+	NameClash        Code = "name-clash"           //a variable with the name of a type, or the other way around (ie 'let int Month := 1' after 'enum Month')
+)
+
+//Span is the part of the source a diagnostic is about, from the first character of Start up to (but not including) End
+type Span struct {
+	Start token.Position
+	End   token.Position
+}
+
+//REQUIRES: a token
+//MODIFIES:
+//EFFECTS: returns the span covering tok
+func TokenSpan(tok token.Token) Span {
+	return Span{Start: tok.Pos, End: tok.End}
+}
+
+//REQUIRES: anything that knows where it starts and ends (ie an AST node)
+//MODIFIES:
+//EFFECTS: returns the span covering n
+func NodeSpan(n interface {
+	Pos() token.Position
+	End() token.Position
+}) Span {
+	return Span{Start: n.Pos(), End: n.End()}
+}
+
+//Suggestion is a way the problem could be fixed (ie replacing '==' with '=')
+type Suggestion struct {
+	Message     string //what the suggestion does, shown to the user
+	Span        Span   //the source that would be replaced
+	Replacement string //what it would be replaced with
+}
+
+type Diagnostic struct {
+	Severity    Severity
+	Code        Code
+	Message     string
+	Span        Span
+	Notes       []string     //extra explanation shown under the source
+	Suggestions []Suggestion //possible fixes
+}
+
+//REQUIRES:
+//MODIFIES:
+//EFFECTS: returns the diagnostic as 'line:column: message' (ie '1:11: expected next token to be :=, got = instead'), which is what Errors() returns
+func (d Diagnostic) String() string {
+	return d.Span.Start.String() + ": " + d.Message
+}
+
+//List is every diagnostic found in a program, in the order they were reported
+type List []Diagnostic
+
+//REQUIRES: a diagnostic
+//MODIFIES: the list
+//EFFECTS: adds d to the end of the list
+func (l *List) Add(d Diagnostic) {
+	*l = append(*l, d)
+}
+
+//REQUIRES:
+//MODIFIES: the order of the list
+//EFFECTS: sorts the list by where in the source each diagnostic starts (by file, then by offset). Diagnostics at the same place keep their order
+func (l List) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i].Span.Start, l[j].Span.Start
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+}
+
+//REQUIRES:
+//MODIFIES:
+//EFFECTS: returns whether any diagnostic in the list is an error (warnings alone don't stop a program from running)
+func (l List) HasErrors() bool {
+	for _, d := range l {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+//REQUIRES:
+//MODIFIES:
+//EFFECTS: returns the errors in the list as 'line:column: message' strings
+func (l List) Errors() []string {
+	errors := []string{}
+	for _, d := range l {
+		if d.Severity == Error {
+			errors = append(errors, d.String())
+		}
+	}
+	return errors
+}
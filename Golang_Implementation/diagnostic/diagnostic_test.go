@@ -0,0 +1,100 @@
+package diagnostic
+
+import (
+	"bytes"
+	"testing"
+
+	"../token"
+)
+
+func pos(line, column, offset int) token.Position {
+	return token.Position{Line: line, Column: column, Offset: offset}
+}
+
+func TestRender(t *testing.T) {
+	src := "let int x := 5;\nlet int y = x;"
+
+	d := Diagnostic{
+		Severity:    Error,
+		Code:        UnexpectedToken,
+		Message:     "expected next token to be :=, got = instead",
+		Span:        Span{Start: pos(2, 11, 26), End: pos(2, 12, 27)},
+		Notes:       []string{"variables are declared with :="},
+		Suggestions: []Suggestion{{Message: "replace = with :=", Replacement: ":="}},
+	}
+
+	expected := `error[unexpected-token]: expected next token to be :=, got = instead
+ --> 2:11
+  |
+2 | let int y = x;
+  |           ^
+  = note: variables are declared with :=
+  = help: replace = with :=
+`
+
+	var out bytes.Buffer
+	Render(&out, src, d)
+
+	if out.String() != expected {
+		t.Errorf("rendered diagnostic wrong.\nwant=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestRenderUnderline(t *testing.T) {
+	tests := []struct {
+		src      string
+		span     Span
+		expected string
+	}{
+		{"let int x := true;", Span{Start: pos(1, 14, 13), End: pos(1, 18, 17)}, "  |              ^^^^\n"},
+		{"\tx + y", Span{Start: pos(1, 2, 1), End: pos(1, 7, 6)}, "  | \t^^^^^\n"},                         //tabs are kept so the carets line up
+		{"if (x) {\n1 }", Span{Start: pos(1, 1, 0), End: pos(2, 4, 12)}, "  | ^^^^^^^^\n"},                 //spans over several lines are underlined to the end of the first
+		{"let int x := ", Span{Start: pos(1, 14, 13), End: pos(1, 14, 13)}, "  |              ^\n"},        //the EOF still gets a caret
+		{"let int größe := 1", Span{Start: pos(1, 15, 16), End: pos(1, 17, 18)}, "  |               ^^\n"}, //columns count characters, not bytes
+		{"\"größe\" + {\n}", Span{Start: pos(1, 11, 13), End: pos(2, 2, 15)}, "  |           ^\n"},         //and so does the end of the line
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Render(&out, tt.src, Diagnostic{Span: tt.span})
+
+		if !bytes.HasSuffix(out.Bytes(), []byte(tt.expected)) {
+			t.Errorf("underline wrong for %q. want=%q, got=%q", tt.src, tt.expected, out.String())
+		}
+	}
+}
+
+func TestRenderWithoutPosition(t *testing.T) {
+	var out bytes.Buffer
+	Render(&out, "5", Diagnostic{Severity: Warning, Code: TypeMismatch, Message: "oops"})
+
+	if out.String() != "warning[type-mismatch]: oops\n" {
+		t.Errorf("rendered diagnostic wrong. got=%q", out.String())
+	}
+}
+
+func TestListSortAndErrors(t *testing.T) {
+	l := List{}
+	l.Add(Diagnostic{Severity: Error, Message: "third", Span: Span{Start: pos(2, 1, 20)}})
+	l.Add(Diagnostic{Severity: Warning, Message: "second", Span: Span{Start: pos(1, 5, 4)}})
+	l.Add(Diagnostic{Severity: Error, Message: "first", Span: Span{Start: pos(1, 1, 0)}})
+
+	l.Sort()
+
+	for i, expected := range []string{"first", "second", "third"} {
+		if l[i].Message != expected {
+			t.Errorf("l[%d] wrong after sorting. want=%q, got=%q", i, expected, l[i].Message)
+		}
+	}
+
+	errors := l.Errors() //warnings aren't errors
+	if len(errors) != 2 || errors[0] != "1:1: first" || errors[1] != "2:1: third" {
+		t.Errorf("l.Errors() wrong. got=%q", errors)
+	}
+	if !l.HasErrors() {
+		t.Errorf("l.HasErrors() should be true")
+	}
+	if (List{{Severity: Warning}}).HasErrors() {
+		t.Errorf("a list of warnings shouldn't have errors")
+	}
+}
@@ -0,0 +1,94 @@
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+//REQUIRES: where to write, the source the diagnostic was found in, and the diagnostic
+//MODIFIES: out
+//EFFECTS: writes the diagnostic along with the line of source it is about, underlining the offending code with carets. Ex:
+//
+//	error[unexpected-token]: expected next token to be :=, got = instead
+//	 --> 1:11
+//	  |
+//	1 | let int x = 5;
+//	  |           ^
+func Render(out io.Writer, src string, d Diagnostic) {
+	fmt.Fprintf(out, "%s[%s]: %s\n", d.Severity, d.Code, d.Message)
+
+	start := d.Span.Start
+	lines := strings.Split(src, "\n")
+	if !start.IsValid() || start.Line > len(lines) { //we don't know where the problem is, so all we can show is the message
+		renderFooter(out, "", d)
+		return
+	}
+
+	line := strings.TrimRight(lines[start.Line-1], "\r")
+	gutter := strings.Repeat(" ", len(fmt.Sprint(start.Line))) //lines the | up no matter how many digits the line number has
+
+	fmt.Fprintf(out, "%s--> %s\n", gutter, start)
+	fmt.Fprintf(out, "%s |\n", gutter)
+	fmt.Fprintf(out, "%d | %s\n", start.Line, line)
+	fmt.Fprintf(out, "%s | %s%s\n", gutter, padding(line, start.Column), strings.Repeat("^", underlineWidth(line, d.Span)))
+
+	renderFooter(out, gutter, d)
+}
+
+//REQUIRES: where to write, the source the diagnostics were found in, and the diagnostics
+//MODIFIES: out
+//EFFECTS: renders every diagnostic in the list, with a blank line between each of them
+func RenderAll(out io.Writer, src string, l List) {
+	for i, d := range l {
+		if i > 0 {
+			io.WriteString(out, "\n")
+		}
+		Render(out, src, d)
+	}
+}
+
+//writes the notes and suggestions of d under the source
+func renderFooter(out io.Writer, gutter string, d Diagnostic) {
+	for _, note := range d.Notes {
+		fmt.Fprintf(out, "%s = note: %s\n", gutter, note)
+	}
+	for _, s := range d.Suggestions {
+		fmt.Fprintf(out, "%s = help: %s\n", gutter, s.Message)
+	}
+}
+
+//returns the whitespace that lines a caret up under column of line (columns count characters, not bytes). Tabs are kept as tabs so the caret
+//lines up however wide they are displayed
+func padding(line string, column int) string {
+	var pad strings.Builder
+	i := 1
+	for _, r := range line {
+		if i >= column {
+			break
+		}
+		if r == '\t' {
+			pad.WriteByte('\t')
+		} else {
+			pad.WriteByte(' ')
+		}
+		i++
+	}
+	return pad.String()
+}
+
+//returns how many carets it takes to underline span on line. Spans that go past the end of the line are underlined to the end of it
+func underlineWidth(line string, span Span) int {
+	width := 1
+	if span.End.Line == span.Start.Line {
+		width = span.End.Column - span.Start.Column
+	} else if span.End.Line > span.Start.Line {
+		width = utf8.RuneCountInString(line) - span.Start.Column + 1
+	}
+
+	if width < 1 { //even an empty span (ie the EOF) gets a caret
+		width = 1
+	}
+	return width
+}
@@ -0,0 +1,1023 @@
+//OVERVIEW: The evaluator walks the AST produced by the parser and executes it, turning every node into an object.Object
+package evaluator
+
+import (
+	"bytes"
+	"fmt"
+
+	"../ast"
+	"../object"
+)
+
+var ( //there is only ever one true, one false and one null, so we reference these instead of allocating new objects every time
+	NULL  = &object.Null{}
+	TRUE  = &object.Boolean{Value: true}
+	FALSE = &object.Boolean{Value: false}
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
+)
+
+var callDepth int //how many calls are running right now, so recursion that never ends stops at object.MaxCallDepth
+
+//REQUIRES: an AST node and the environment it should be evaluated in
+//MODIFIES: env (let statements bind names in it)
+//EFFECTS: returns the object the node evaluates to (an *object.Error if something went wrong)
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+
+	// Statements
+	case *ast.Program:
+		return evalProgram(node, env)
+
+	case *ast.ExpressionStatement:
+		return Eval(node.Expression, env)
+
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env)
+
+	case *ast.ReturnStatement:
+		if node.ReturnValue == nil { //a bare return
+			return &object.ReturnValue{Value: NULL}
+		}
+		val := Eval(node.ReturnValue, env)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val} //wrapped so evalBlockStatement and evalProgram know to stop
+
+	case *ast.LetStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		env.Set(node.Name.Value, val)
+
+	case *ast.SetStatement:
+		return evalSetStatement(node, env)
+
+	case *ast.KeyStatement:
+		return evalKeyStatement(node, env)
+
+	case *ast.ForInStatement:
+		return evalForInStatement(node, env)
+
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+
+	case *ast.FunctionDeclaration:
+		env.Set(node.Name.Value, newFunction(node.Function, env)) //the function is bound in the same environment it closes over, so it can call itself
+
+	case *ast.StructDeclaration:
+		env.Set(node.Name.Value, object.NewStructType(node.Name.Value, node.Fields))
+
+	case *ast.EnumDeclaration:
+		variants := []string{}
+		for _, v := range node.Variants {
+			variants = append(variants, v.Value)
+		}
+		env.Set(node.Name.Value, object.NewEnumType(node.Name.Value, variants))
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
+
+	// Expressions
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}
+
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
+
+	case *ast.PrefixExpression:
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalPrefixExpression(node.Operator, right)
+
+	case *ast.InfixExpression:
+		if node.Operator == "and" || node.Operator == "or" { //the right side is only evaluated when it can change the result
+			return evalLogicalExpression(node, env)
+		}
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalInfixExpression(node.Operator, left, right)
+
+	case *ast.IfExpression:
+		return evalIfExpression(node, env)
+
+	case *ast.RangeExpression:
+		return evalRangeExpression(node, env)
+
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+
+	case *ast.StructLiteral:
+		return evalStructLiteral(node, env)
+
+	case *ast.SelectorExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		return evalSelectorExpression(left, node.Field.Value)
+
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+
+	case *ast.FunctionLiteral:
+		return newFunction(node, env)
+
+	case *ast.CallExpression:
+		function := Eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		return applyFunction(function, args)
+
+	// Placeholders the parser leaves where code was broken
+	case *ast.BadStatement, *ast.BadExpression:
+		return newError("cannot run code that failed to parse: %s", node.Pos())
+	}
+
+	return nil
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Statement evaluation
+
+//evaluates every statement of the program, stopping early on a return or an error
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range program.Statements {
+		result = Eval(statement, env)
+		locate(result, statement)
+
+		switch result := result.(type) {
+		case *object.ReturnValue: //the program returned, so we unwrap the value and stop
+			return result.Value
+		case *object.Error: //something went wrong, no point in going further
+			return result
+		}
+	}
+
+	return result
+}
+
+//evaluates every statement in {...}. Unlike evalProgram the return value is NOT unwrapped, so that it keeps bubbling up through nested blocks
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range block.Statements {
+		result = Eval(statement, env)
+		locate(result, statement)
+
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+//remembers the line of the statement an error happened in. The innermost statement sees the error first, so an error in a function
+//points into the function the same way the vm's errors do
+func locate(result object.Object, statement ast.Statement) {
+	if err, ok := result.(*object.Error); ok && err.Line == 0 {
+		err.Line = statement.Pos().Line
+	}
+}
+
+//the infix operator each update operator applies between the old and the new value (:= just replaces the value)
+var updateOperators = map[string]string{
+	":+": "+",
+	":-": "-",
+	":*": "*",
+	":/": "/",
+}
+
+//updates an already declared variable, or an element of one. Unlike let, set never creates a new binding
+func evalSetStatement(ss *ast.SetStatement, env *object.Environment) object.Object {
+	switch target := ss.Target.(type) {
+	case *ast.IndexExpression:
+		return evalSetIndex(ss, target, env)
+	case *ast.SelectorExpression:
+		return evalSetField(ss, target, env)
+	}
+
+	name := ss.Target.(*ast.Identifier).Value
+	current, ok := env.Get(name)
+	if !ok { //mutation has to be explicit, so the variable must have been declared with let first
+		return newError("cannot set undeclared variable: %s", name)
+	}
+
+	val := Eval(ss.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if operator, ok := updateOperators[ss.Operator]; ok { //set x :+ 3 is set x := x + 3
+		val = evalInfixExpression(operator, current, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	if val.Type() != current.Type() { //a variable keeps the type it was declared with
+		return newError("type mismatch: cannot set %s variable %s to %s",
+			current.Type(), name, val.Type())
+	}
+
+	env.Assign(name, val)
+	return nil
+}
+
+//REQUIRES: a set statement whose target is an index expression (ie 'set m["k"] :+ 1')
+//MODIFIES: the array or map being indexed
+//EFFECTS: stores the new value in the element the index points at. Setting a key that isn't in a map yet adds it, but an update operator
+//needs the key to already be there
+func evalSetIndex(ss *ast.SetStatement, ie *ast.IndexExpression, env *object.Environment) object.Object {
+	container := Eval(ie.Left, env)
+	if isError(container) {
+		return container
+	}
+	index := Eval(ie.Index, env)
+	if isError(index) {
+		return index
+	}
+	val := Eval(ss.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if operator, ok := updateOperators[ss.Operator]; ok { //set xs[0] :+ 3 is set xs[0] := xs[0] + 3
+		current := evalIndexExpression(container, index)
+		if isError(current) {
+			return current
+		}
+		val = evalInfixExpression(operator, current, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	switch container := container.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok { //this includes ranges, a slice can't be set
+			return newError("cannot set element %s of an array, the index must be INTEGER", index.Inspect())
+		}
+		if i.Value < 0 || i.Value >= int64(len(container.Elements)) {
+			return newError("index out of range [%d] with length %d", i.Value, len(container.Elements))
+		}
+		if current := container.Elements[i.Value]; current.Type() != val.Type() { //elements keep the type of the array
+			return newError("type mismatch: cannot set %s element to %s", current.Type(), val.Type())
+		}
+		container.Elements[i.Value] = val
+	case *object.Map:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as map key: %s", index.Type())
+		}
+		if current, ok := container.Get(key); ok && current.Type() != val.Type() {
+			return newError("type mismatch: cannot set %s value to %s", current.Type(), val.Type())
+		}
+		container.Set(key, val)
+	default:
+		return newError("index operator not supported: %s", container.Type())
+	}
+
+	return nil
+}
+
+//tests the keys against each lock in order and runs the body of the first one that unlocks (or the lock else if none do)
+func evalKeyStatement(ks *ast.KeyStatement, env *object.Environment) object.Object {
+	keys := evalExpressions(ks.Keys, env)
+	if len(keys) == 1 && isError(keys[0]) {
+		return keys[0]
+	}
+
+	for _, lock := range ks.Locks {
+		unlocked, err := evalLockClause(lock, keys, env)
+		if err != nil {
+			return err
+		}
+		if unlocked { //only the first lock that unlocks runs
+			return Eval(lock.Body, object.NewEnclosedEnvironment(env)) //names declared in the body don't outlive it, like in the checker
+		}
+	}
+
+	if ks.Else != nil { //nothing unlocked, so the lock else runs
+		return Eval(ks.Else, object.NewEnclosedEnvironment(env))
+	}
+
+	return nil
+}
+
+//REQUIRES: a lock clause and the already evaluated keys of its key statement
+//MODIFIES:
+//EFFECTS: returns whether the lock unlocks. A lock unlocks when every value fits the key at the same position:
+//a BOOLEAN value fits a non BOOLEAN key when it is true (ie 'lock num > 3' for 'key (num)'), any other value fits when it equals the key (ie 'lock 2').
+//A lock with a single value over several keys is a condition and has to be a BOOLEAN (ie 'lock x = y' for 'key (x, y)')
+func evalLockClause(lock *ast.LockClause, keys []object.Object, env *object.Environment) (bool, *object.Error) {
+	values := evalExpressions(lock.Values, env)
+	if len(values) == 1 && isError(values[0]) {
+		return false, values[0].(*object.Error)
+	}
+
+	if len(values) == 1 && len(keys) > 1 { //a single condition tested against all of the keys
+		if values[0].Type() != object.BOOLEAN_OBJ {
+			return false, newError("lock condition must be BOOLEAN, got %s", values[0].Type())
+		}
+		return values[0] == TRUE, nil
+	}
+
+	for i, value := range values {
+		if value.Type() == object.BOOLEAN_OBJ && keys[i].Type() != object.BOOLEAN_OBJ { //a condition in the position of this key
+			if value != TRUE {
+				return false, nil
+			}
+			continue
+		}
+		if value.Type() != keys[i].Type() {
+			return false, newError("type mismatch: lock value %s does not match key %s", value.Type(), keys[i].Type())
+		}
+		if !objectsEqual(value, keys[i]) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+//runs the body once for every element of the iterable, each time with a fresh binding of the loop variable
+func evalForInStatement(fs *ast.ForInStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	if fs.Value != nil && iterable.Type() != object.ARRAY_OBJ && iterable.Type() != object.MAP_OBJ { //only these have keys to go with the elements
+		return newError("cannot iterate over %s with two variables", iterable.Type())
+	}
+
+	result := forEachElement(iterable, func(key, element object.Object) object.Object {
+		loopEnv := object.NewEnclosedEnvironment(env) //a new environment every iteration, so nothing bound in the body leaks into the next one
+		switch {
+		case fs.Value != nil: //for i, x in xs or for k, v in m
+			loopEnv.Set(fs.Variable.Value, key)
+			loopEnv.Set(fs.Value.Value, element)
+		case iterable.Type() == object.MAP_OBJ: //a single variable goes over the keys of a map
+			loopEnv.Set(fs.Variable.Value, key)
+		default:
+			loopEnv.Set(fs.Variable.Value, element)
+		}
+
+		return evalLoopBody(fs.Body, loopEnv)
+	})
+
+	if result == BREAK { //the break has done its job, it shouldn't end any loops further out
+		return nil
+	}
+	return result
+}
+
+//runs the body for as long as the condition is true
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if condition.Type() != object.BOOLEAN_OBJ { //no truthiness, the condition has to actually be a bool
+			return newError("while condition must be BOOLEAN, got %s", condition.Type())
+		}
+		if condition == FALSE {
+			return nil
+		}
+
+		result := evalLoopBody(ws.Body, object.NewEnclosedEnvironment(env)) //a new environment every iteration, like for in
+		if result == BREAK {
+			return nil
+		}
+		if result != nil {
+			return result
+		}
+	}
+}
+
+//REQUIRES: the body of a loop and the environment of this iteration
+//MODIFIES: env
+//EFFECTS: runs one iteration of the body. Returns nil when the loop should go on to the next iteration (including after a continue),
+//BREAK when the loop should end, or a return value/error that should keep bubbling up
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) object.Object {
+	result := Eval(body, env)
+	if result == nil {
+		return nil
+	}
+
+	switch result.Type() {
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ:
+		return result
+	default: //continue, or just the value of the last statement in the body
+		return nil
+	}
+}
+
+//REQUIRES: the evaluated iterable of a for in loop and what to do with each of its elements
+//MODIFIES:
+//EFFECTS: calls fn with every element of obj in order, along with its key (the index in an array, the key in a map and nil for ranges and
+//strings). Maps go in the order their keys were added. Stops early and returns whatever fn returned as soon as it isn't nil,
+//returns an error if obj can't be looped over
+func forEachElement(obj object.Object, fn func(key, element object.Object) object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.Range:
+		for i := obj.Start; i <= obj.End; i++ { //ranges include their end. If start is past end there is nothing to loop over
+			if result := fn(nil, &object.Integer{Value: i}); result != nil {
+				return result
+			}
+			if i == obj.End { //stops i from overflowing when the range ends at the largest int
+				break
+			}
+		}
+		return nil
+	case *object.Array:
+		for i, element := range obj.Elements {
+			if result := fn(&object.Integer{Value: int64(i)}, element); result != nil {
+				return result
+			}
+		}
+		return nil
+	case *object.Map:
+		for _, k := range obj.Keys { //keys added by the body aren't visited, the loop only goes over the ones that were there when it started
+			pair := obj.Pairs[k]
+			if result := fn(pair.Key, pair.Value); result != nil {
+				return result
+			}
+		}
+		return nil
+	case *object.String:
+		for _, r := range obj.Value { //one character at a time (not one byte at a time)
+			if result := fn(nil, &object.String{Value: string(r)}); result != nil {
+				return result
+			}
+		}
+		return nil
+	default:
+		return newError("cannot iterate over %s", obj.Type())
+	}
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Expression evaluation
+
+//evaluates expressions left to right. If one of them is an error, that error is the only thing returned
+func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+
+	for _, e := range exps {
+		evaluated := Eval(e, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+
+	return result
+}
+
+func evalPrefixExpression(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return evalBangOperatorExpression(right)
+	case "-":
+		return evalMinusPrefixOperatorExpression(right)
+	default:
+		return newError("unknown operator: %s%s", operator, right.Type())
+	}
+}
+
+func evalBangOperatorExpression(right object.Object) object.Object {
+	if right.Type() != object.BOOLEAN_OBJ { //we are explicitly typed, so ! only makes sense on booleans
+		return newError("unknown operator: !%s", right.Type())
+	}
+	return nativeBoolToBooleanObject(!right.(*object.Boolean).Value)
+}
+
+func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError("unknown operator: -%s", right.Type())
+	}
+}
+
+func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(operator, left, right)
+	case left.Type() == object.FLOAT_OBJ && right.Type() == object.FLOAT_OBJ:
+		return evalFloatInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
+	case left.Type() != right.Type(): //no implicit conversions between types
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	case operator == "=":
+		return nativeBoolToBooleanObject(left == right) //booleans are singletons, so pointer comparison is enough
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+//REQUIRES: an 'and' or an 'or' expression
+//MODIFIES:
+//EFFECTS: evaluates the left side, and only evaluates the right side if the left one doesn't already decide the result (false for and, true
+//for or). So 'i < len(s) and ...' never runs the right side once i is too big. Both sides have to be BOOLEANs
+func evalLogicalExpression(ie *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(ie.Left, env)
+	if isError(left) {
+		return left
+	}
+	if left.Type() != object.BOOLEAN_OBJ { //no truthiness here either
+		return newError("operands of %s must be BOOLEAN, got %s", ie.Operator, left.Type())
+	}
+	if (ie.Operator == "and" && left == FALSE) || (ie.Operator == "or" && left == TRUE) {
+		return left
+	}
+
+	right := Eval(ie.Right, env)
+	if isError(right) {
+		return right
+	}
+	if right.Type() != object.BOOLEAN_OBJ {
+		return newError("operands of %s must be BOOLEAN, got %s", ie.Operator, right.Type())
+	}
+	return right
+}
+
+func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.Integer).Value
+	rightVal := right.(*object.Integer).Value
+
+	switch operator {
+	case "+":
+		return &object.Integer{Value: leftVal + rightVal}
+	case "-":
+		return &object.Integer{Value: leftVal - rightVal}
+	case "*":
+		return &object.Integer{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: leftVal % rightVal} //takes the sign of the left side, so -7 % 2 is -1
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "=":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.Float).Value
+	rightVal := right.(*object.Float).Value
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 { //the same as for ints, instead of quietly producing an infinity
+			return newError("division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "=":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "=":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+//evaluates the parts of the string in order and joins them. Interpolated values are written the way print writes them
+func evalInterpolatedString(is *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out bytes.Buffer
+
+	for _, part := range is.Parts {
+		val := Eval(part, env)
+		if isError(val) {
+			return val
+		}
+		out.WriteString(val.Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := Eval(ie.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+	if condition.Type() != object.BOOLEAN_OBJ { //no truthiness, the condition has to actually be a bool
+		return newError("if condition must be BOOLEAN, got %s", condition.Type())
+	}
+
+	if condition == TRUE { //each branch gets its own environment, so names declared in it don't outlive it
+		return Eval(ie.Consequence, object.NewEnclosedEnvironment(env))
+	} else if ie.Alternative != nil {
+		return Eval(ie.Alternative, object.NewEnclosedEnvironment(env))
+	} else { //condition was false and there is no else
+		return NULL
+	}
+}
+
+func evalRangeExpression(re *ast.RangeExpression, env *object.Environment) object.Object {
+	start := Eval(re.First, env)
+	if isError(start) {
+		return start
+	}
+	end := Eval(re.Last, env)
+	if isError(end) {
+		return end
+	}
+
+	if start.Type() != object.INTEGER_OBJ || end.Type() != object.INTEGER_OBJ { //ranges are only made up of integers
+		return newError("range bounds must be INTEGER, got %s..%s", start.Type(), end.Type())
+	}
+
+	return &object.Range{Start: start.(*object.Integer).Value, End: end.(*object.Integer).Value}
+}
+
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+	if builtin := object.GetBuiltinByName(node.Value); builtin != nil { //names in the program come first, so a builtin can be shadowed
+		return builtin
+	}
+	return newError("identifier not found: %s", node.Value)
+}
+
+//evaluates every pair of a hash literal in order. A key written twice keeps the last value given for it
+func evalHashLiteral(hl *ast.HashLiteral, env *object.Environment) object.Object {
+	m := object.NewMap()
+
+	for _, pair := range hl.Pairs {
+		key := Eval(pair.Key, env)
+		if isError(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as map key: %s", key.Type())
+		}
+
+		value := Eval(pair.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		m.Set(hashKey, value)
+	}
+
+	return m
+}
+
+//REQUIRES: an evaluated value and the evaluated index into it
+//MODIFIES:
+//EFFECTS: returns the element of an array or the value of a map the index points at (see evalArrayIndexExpression and evalMapIndexExpression)
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		return evalArrayIndexExpression(left, index)
+	case *object.Map:
+		return evalMapIndexExpression(left, index)
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+//REQUIRES: an array and the evaluated index into it
+//MODIFIES:
+//EFFECTS: returns the element at an INTEGER index, or a new array with the elements a RANGE covers (ie xs[1..2] is the second and third
+//elements, since ranges include their end). Indexes start at 0 and anything outside of the array is an error
+func evalArrayIndexExpression(array *object.Array, index object.Object) object.Object {
+	length := int64(len(array.Elements))
+
+	switch index := index.(type) {
+	case *object.Integer:
+		if index.Value < 0 || index.Value >= length {
+			return newError("index out of range [%d] with length %d", index.Value, length)
+		}
+		return array.Elements[index.Value]
+	case *object.Range:
+		if index.Start < 0 || index.Start > length || index.End < index.Start-1 || index.End >= length { //xs[i..i-1] is an empty slice
+			return newError("slice bounds out of range [%d..%d] with length %d", index.Start, index.End, length)
+		}
+		elements := make([]object.Object, index.End-index.Start+1)
+		copy(elements, array.Elements[index.Start:index.End+1]) //a copy, so the slice and the array don't share elements
+		return &object.Array{Elements: elements}
+	default:
+		return newError("array index must be INTEGER or RANGE, got %s", index.Type())
+	}
+}
+
+//returns the value stored under index in the map. Looking up a key that isn't there is an error
+func evalMapIndexExpression(m *object.Map, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as map key: %s", index.Type())
+	}
+
+	value, ok := m.Get(key)
+	if !ok {
+		return newError("key not found: %s", key.Inspect())
+	}
+	return value
+}
+
+//REQUIRES: a struct literal (ie Point{x: 1, y: 2})
+//MODIFIES:
+//EFFECTS: returns a new struct of the type the literal names, with its fields evaluated in the order they were written.
+//Every field of the struct has to be given a value exactly once
+func evalStructLiteral(sl *ast.StructLiteral, env *object.Environment) object.Object {
+	val, ok := env.Get(sl.Name.Value)
+	if !ok {
+		return newError("unknown type: %s", sl.Name.Value)
+	}
+	st, ok := val.(*object.StructType)
+	if !ok {
+		return newError("not a struct type: %s", sl.Name.Value)
+	}
+
+	s := &object.Struct{StructType: st, Fields: make(map[string]object.Object)}
+	for _, f := range sl.Fields {
+		if !st.HasField(f.Name.Value) {
+			return newError("unknown field %s in struct %s", f.Name.Value, st.Name)
+		}
+		if _, ok := s.Fields[f.Name.Value]; ok {
+			return newError("duplicate field %s in struct literal", f.Name.Value)
+		}
+
+		value := Eval(f.Value, env)
+		if isError(value) {
+			return value
+		}
+		s.Fields[f.Name.Value] = value
+	}
+
+	for _, f := range st.Fields {
+		if _, ok := s.Fields[f.Name]; !ok {
+			return newError("missing field %s in %s literal", f.Name, st.Name)
+		}
+	}
+
+	return s
+}
+
+//returns the value of the field of a struct with the given name, or the variant of an enum type (ie Month.Feb)
+func evalSelectorExpression(left object.Object, field string) object.Object {
+	switch left := left.(type) {
+	case *object.Struct:
+		value, ok := left.Fields[field]
+		if !ok {
+			return newError("unknown field %s in struct %s", field, left.StructType.Name)
+		}
+		return value
+	case *object.EnumType:
+		variant, ok := left.Variant(field)
+		if !ok {
+			return newError("unknown variant %s in enum %s", field, left.Name)
+		}
+		return variant
+	default:
+		return newError("field access not supported: %s", left.Type())
+	}
+}
+
+//REQUIRES: a set statement whose target is a selector expression (ie 'set p.x :+ 1')
+//MODIFIES: the struct whose field is being set
+//EFFECTS: stores the new value in the field, which keeps the type it had
+func evalSetField(ss *ast.SetStatement, se *ast.SelectorExpression, env *object.Environment) object.Object {
+	left := Eval(se.Left, env)
+	if isError(left) {
+		return left
+	}
+	s, ok := left.(*object.Struct)
+	if !ok { //this includes enum types, their variants can't be changed
+		return newError("cannot set field %s of %s", se.Field.Value, left.Type())
+	}
+	current := evalSelectorExpression(s, se.Field.Value)
+	if isError(current) {
+		return current
+	}
+	val := Eval(ss.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if operator, ok := updateOperators[ss.Operator]; ok { //set p.x :+ 3 is set p.x := p.x + 3
+		val = evalInfixExpression(operator, current, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	if val.Type() != current.Type() {
+		return newError("type mismatch: cannot set %s field %s to %s", current.Type(), se.Field.Value, val.Type())
+	}
+
+	s.Fields[se.Field.Value] = val
+	return nil
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Functions
+
+func newFunction(fl *ast.FunctionLiteral, env *object.Environment) *object.Function {
+	return &object.Function{
+		Name:       fl.Name,
+		Parameters: fl.Parameters,
+		ReturnType: fl.ReturnType,
+		Body:       fl.Body,
+		Env:        env,
+	}
+}
+
+//REQUIRES: the evaluated function being called and its evaluated arguments
+//MODIFIES:
+//EFFECTS: runs the body of fn with its parameters bound to args and returns what the body returned (NULL if it never returned)
+func applyFunction(fn object.Object, args []object.Object) object.Object {
+	if builtin, ok := fn.(*object.Builtin); ok {
+		if result := builtin.Fn(args...); result != nil {
+			return result
+		}
+		return NULL //the builtin didn't have anything to hand back (ie print)
+	}
+
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return newError("not a function: %s", fn.Type())
+	}
+
+	if len(args) != len(function.Parameters) {
+		return newError("wrong number of arguments to %s: want=%d, got=%d",
+			functionName(function), len(function.Parameters), len(args))
+	}
+
+	if callDepth >= object.MaxCallDepth {
+		return newError("stack overflow")
+	}
+	callDepth++
+	defer func() { callDepth-- }()
+
+	extendedEnv := object.NewEnclosedEnvironment(function.Env) //the parameters live in a new environment enclosed by the one the function was defined in
+	for i, param := range function.Parameters {
+		extendedEnv.Set(param.Name.Value, args[i])
+	}
+
+	evaluated := Eval(function.Body, extendedEnv)
+	if isError(evaluated) {
+		return evaluated
+	}
+	if returnValue, ok := evaluated.(*object.ReturnValue); ok { //unwrap it, otherwise the return would keep going up past the call
+		return returnValue.Value
+	}
+
+	return NULL //we only hand values back through return
+}
+
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "anonymous function"
+	}
+	return fn.Name
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% EVALUATOR HELPER METHODS
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
+
+//two objects are equal when they are of the same type and hold the same value
+func objectsEqual(left, right object.Object) bool {
+	if left.Type() != right.Type() {
+		return false
+	}
+
+	switch left := left.(type) {
+	case *object.Integer:
+		return left.Value == right.(*object.Integer).Value
+	case *object.Float:
+		return left.Value == right.(*object.Float).Value
+	case *object.String:
+		return left.Value == right.(*object.String).Value
+	default: //booleans and null are singletons, so pointer comparison is enough
+		return left == right
+	}
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func isError(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR_OBJ
+	}
+	return false
+}
@@ -0,0 +1,1219 @@
+package evaluator
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"../lexer"
+	"../object"
+	"../parser"
+)
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 + 10 % 4 * 3", 8},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 = 1", true},
+		{"1 != 1", false},
+		{"1 = 2", false},
+		{"1 != 2", true},
+		{"true = true", true},
+		{"false = false", true},
+		{"true = false", false},
+		{"true != false", true},
+		{"(1 < 2) = true", true},
+		{"(1 > 2) = true", false},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"1.5 <= 1.5", true},
+		{"1.5 >= 2.5", false},
+		{"true and true", true},
+		{"true and false", false},
+		{"false or true", true},
+		{"false or false", false},
+		{"1 < 2 and 2 < 3", true},
+		{"false and true or true", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestShortCircuit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"false and 1 / 0 = 1", false}, //the right side would be an error if it ran
+		{"true or 1 / 0 = 1", true},
+		{"let int calls := 0; func f() returns bool { set calls :+ 1; return true; } false and f(); calls = 0", true},
+		{"let int calls := 0; func f() returns bool { set calls :+ 1; return true; } true and f(); calls = 1", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBangOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!!true", true},
+		{"!!false", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"let int x := 1; if (true) { let int x := 2; set x :+ 1; }; x", 1}, //the x in the block is a different variable
+		{"let int x := 1; key (x) { lock 1 { let int x := 5; } }; x", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{`
+if (10 > 1) {
+  if (10 > 1) {
+    return 10;
+  }
+
+  return 1;
+}
+`, 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{
+			"5 + true;",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"5 + true; 5;",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"let int x := 1; let int y = 2; x",
+			"cannot run code that failed to parse: 1:17",
+		},
+		{
+			"1 + )",
+			"cannot run code that failed to parse: 1:5",
+		},
+		{
+			"-true",
+			"unknown operator: -BOOLEAN",
+		},
+		{
+			"!5",
+			"unknown operator: !INTEGER",
+		},
+		{
+			"true + false;",
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			"5; true + false; 5",
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			"if (10 > 1) { true + false; }",
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			`
+if (10 > 1) {
+  if (10 > 1) {
+    return true + false;
+  }
+
+  return 1;
+}
+`,
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			"if (1) { 10 }",
+			"if condition must be BOOLEAN, got INTEGER",
+		},
+		{
+			"10 / 0",
+			"division by zero",
+		},
+		{
+			"10 % 0",
+			"division by zero",
+		},
+		{
+			"1 and true",
+			"operands of and must be BOOLEAN, got INTEGER",
+		},
+		{
+			"false or 1",
+			"operands of or must be BOOLEAN, got INTEGER",
+		},
+		{
+			"1.5 % 2.0",
+			"unknown operator: FLOAT % FLOAT",
+		},
+		{
+			"foobar",
+			"identifier not found: foobar",
+		},
+		{
+			`"Squid" - "Script"`,
+			"unknown operator: STRING - STRING",
+		},
+		{
+			`"Squid" + 1`,
+			"type mismatch: STRING + INTEGER",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let int a := 5; a;", 5},
+		{"let int a := 5 * 5; a;", 25},
+		{"let int a := 5; let int b := a; b;", 5},
+		{"let int a := 5; let int b := a; let int c := a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestSetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let int a := 5; set a := 10; a;", 10},
+		{"let int a := 5; set a :+ 3; a;", 8},
+		{"let int a := 5; set a :- 3; a;", 2},
+		{"let int a := 5; set a :* 3; a;", 15},
+		{"let int a := 15; set a :/ 3; a;", 5},
+		{"let int a := 1; let int b := 2; set a :+ b * 2; a;", 5},
+		{"let int a := 1; if (true) { set a := 7; }; a;", 7},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestSetStatementErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"set a := 5;", "cannot set undeclared variable: a"},
+		{"let int a := 5; set b :+ 1;", "cannot set undeclared variable: b"},
+		{"let int a := 5; set a := true;", "type mismatch: cannot set INTEGER variable a to BOOLEAN"},
+		{"let bool a := true; set a :+ 1;", "type mismatch: BOOLEAN + INTEGER"},
+		{"let int a := 5; set a :/ 0;", "division by zero"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestKeyStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"key (2) { lock 1 { 10 } lock 2 { 20 } lock 3 { 30 } }", 20},
+		{"key (5) { lock 1 { 10 } lock else { 99 } }", 99},
+		{"key (5) { lock 1 { 10 } }", nil},
+		{"key (2) { lock 2 { 10 } lock 2 { 20 } }", 10},
+		{"key (1 + 1) { lock 4 / 2 { 10 } }", 10},
+		{"key (true) { lock false { 1 } lock true { 2 } }", 2},
+		{"let int n := 7; key (n) { lock n < 5 { 1 } lock n > 5 { 2 } }", 2},
+		{"let int x := 3; let int y := 3; key (x, y) { lock x = y { 1 } lock else { 2 } }", 1},
+		{"let int x := 4; let int y := 3; key (x, y) { lock x = y { 1 } lock x > y { 2 } lock else { 3 } }", 2},
+		{"key (1, 2) { lock 1, 3 { 10 } lock 1, 2 { 20 } }", 20},
+		{"key (1, 2) { lock 1, 2 > 1 { 10 } }", 10},
+		{"let int x := 0; key (1) { lock 1 { set x := 5; } }; x", 5},
+		{`
+if (true) {
+  key (1) {
+    lock 1 { return 10; }
+  }
+  return 20;
+}
+`, 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else if evaluated != nil {
+			t.Errorf("expected no value for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestKeyStatementErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"key (1, 2) { lock 3 { 10 } }", "lock condition must be BOOLEAN, got INTEGER"},
+		{"key (true) { lock 1 { 10 } }", "type mismatch: lock value INTEGER does not match key BOOLEAN"},
+		{"key (foo) { lock 1 { 10 } }", "identifier not found: foo"},
+		{"key (1) { lock bar { 10 } }", "identifier not found: bar"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestRangeExpressions(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedStart int64
+		expectedEnd   int64
+	}{
+		{"1..10", 1, 10},
+		{"let int n := 3; n - 1..n * 2", 2, 6},
+		{"5..1", 5, 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		rng, ok := evaluated.(*object.Range)
+		if !ok {
+			t.Errorf("object is not Range. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if rng.Start != tt.expectedStart || rng.End != tt.expectedEnd {
+			t.Errorf("range has wrong bounds. want=%d..%d, got=%s",
+				tt.expectedStart, tt.expectedEnd, rng.Inspect())
+		}
+	}
+}
+
+func TestForInStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let int sum := 0; for i in 1..10 { set sum :+ i; }; sum", 55},
+		{"let int sum := 0; for i in 3..3 { set sum :+ i; }; sum", 3},
+		{"let int sum := 0; for i in 5..1 { set sum :+ i; }; sum", 0},
+		{"let int sum := 0; for i in 1..3 { for j in 1..3 { set sum :+ i * j; } }; sum", 36},
+		{"let int i := 100; for i in 1..3 { }; i", 100},
+		{"let int count := 0; for i in 1..3 { let int x := i; set count :+ x; }; count", 6},
+		{`
+if (true) {
+  for i in 1..10 {
+    if (i > 4) { return i; }
+  }
+  return 0;
+}
+`, 5},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestForInErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"for i in 5 { }", "cannot iterate over INTEGER"},
+		{"for i in 1..true { }", "range bounds must be INTEGER, got INTEGER..BOOLEAN"},
+		{"for i in 1..3 { i + true; }", "type mismatch: INTEGER + BOOLEAN"},
+		{"for i in 1..3 { }; i", "identifier not found: i"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestWhileStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let int i := 0; while i < 10 { set i :+ 1; }; i", 10},
+		{"let int i := 20; while i < 10 { set i :+ 1; }; i", 20},
+		{"let int i := 0; while true { set i :+ 1; if (i = 7) { break; } }; i", 7},
+		{"let int i := 0; let int sum := 0; while i < 10 { set i :+ 1; if (i > 3) { continue; } set sum :+ i; }; sum", 6},
+		{"let int sum := 0; for i in 1..10 { if (i > 4) { break; } set sum :+ i; }; sum", 10},
+		{"let int sum := 0; for i in 1..10 { key (i) { lock 2 { continue; } lock 5 { break; } } set sum :+ i; }; sum", 8},
+		{`
+let int count := 0;
+for i in 1..3 {
+  let int j := 0;
+  while true {
+    set j :+ 1;
+    if (j > i) { break; }
+    set count :+ 1;
+  }
+}
+count`, 6},
+		{`
+if (true) {
+  let int i := 0;
+  while true {
+    set i :+ 1;
+    if (i = 3) { return i * 10; }
+  }
+}
+`, 30},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestWhileConditionError(t *testing.T) {
+	evaluated := testEval("while 1 { }")
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "while condition must be BOOLEAN, got INTEGER" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "func(int x) returns int { return x + 2; };"
+
+	evaluated := testEval(input)
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("function has wrong parameters. Parameters=%+v",
+			fn.Parameters)
+	}
+
+	if fn.Parameters[0].String() != "int x" {
+		t.Fatalf("parameter is not 'int x'. got=%q", fn.Parameters[0])
+	}
+
+	expectedBody := "return (x + 2);"
+
+	if fn.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.String())
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"func identity(int x) returns int { return x; }; identity(5);", 5},
+		{"func double(int x) returns int { return x * 2; }; double(5);", 10},
+		{"func add(int x, int y) returns int { return x + y; }; add(5, 5);", 10},
+		{"func add(int x, int y) returns int { return x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"let func(int) returns int f := func(int x) returns int { return x; }; f(5)", 5},
+		{"func(int x) returns int { return x; }(5)", 5},
+		{"func early(int x) returns int { if (x > 1) { return 1; } return 2; }; early(5)", 1},
+		{"func loop() returns int { for i in 1..10 { if (i = 4) { return i; } } return 0; }; loop()", 4},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`
+func newAdder(int x) returns func(int) returns int {
+  return func(int y) returns int { return x + y; };
+}
+let func(int) returns int addTwo := newAdder(2);
+addTwo(3);`, 5},
+		{`
+func counter() returns func() returns int {
+  let int count := 0;
+  return func() returns int {
+    set count :+ 1;
+    return count;
+  };
+}
+let func() returns int next := counter();
+next();
+next();
+next();`, 3},
+		{`
+func apply(func(int) returns int f, int x) returns int { return f(x); }
+func square(int x) returns int { return x * x; }
+apply(square, 7);`, 49},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	input := `
+func fib(int n) returns int {
+  if (n < 2) { return n; }
+  return fib(n - 1) + fib(n - 2);
+}
+fib(15);`
+
+	testIntegerObject(t, testEval(input), 610)
+}
+
+func TestRecursionDepth(t *testing.T) {
+	input := `
+func count(int n) returns int {
+  if (n = 0) { return 0; }
+  return 1 + count(n - 1);
+}
+count(9000);`
+
+	testIntegerObject(t, testEval(input), 9000)
+
+	evaluated := testEval("func f(int n) returns int { return f(n + 1); }; f(0)") //never stops, so it has to hit the limit
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "stack overflow" {
+		t.Errorf("wrong error message. expected=%q, got=%q", "stack overflow", errObj.Message)
+	}
+
+	testIntegerObject(t, testEval(input), 9000) //the depth went back down after the error
+}
+
+func TestFunctionWithoutReturn(t *testing.T) {
+	evaluated := testEval("let int x := 1; func bump() { set x :+ 1; }; bump();")
+	testNullObject(t, evaluated)
+
+	testIntegerObject(t, testEval("let int x := 1; func bump() { set x :+ 1; }; bump(); bump(); x"), 3)
+	testIntegerObject(t, testEval("let int x := 1; func bump() { return; set x :+ 1; }; bump(); x"), 1)
+}
+
+func TestFunctionErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"func f(int x) returns int { return x; }; f(1, 2)", "wrong number of arguments to f: want=1, got=2"},
+		{"func(int x) returns int { return x; }()", "wrong number of arguments to anonymous function: want=1, got=0"},
+		{"let int x := 5; x(1)", "not a function: INTEGER"},
+		{"func f() returns int { return y; }; f()", "identifier not found: y"},
+		{"func f() returns int { return 1; }; f(z)", "identifier not found: z"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestErrorLine(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedLine int
+	}{
+		{"let int x := 1;\nlet int y := x / 0;", 2},
+		{"let int x := 1;\n\nif (x = 1) {\n  print(y);\n}", 4},
+		{"func f(int n) returns int {\n  return 1 / n;\n}\nf(0);", 2}, //the line inside the function, not the call
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Line != tt.expectedLine {
+			t.Errorf("wrong error line for %q. expected=%d, got=%d", tt.input, tt.expectedLine, errObj.Line)
+		}
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.5", 3.5},
+		{"-2.5", -2.5},
+		{"1.5 + 2.25", 3.75},
+		{"10.0 / 4.0", 2.5},
+		{"2.0 * (1.5 - 0.5)", 2},
+		{"float(7) / 2.0", 3.5},
+		{"float(1.5)", 1.5},
+		{"let float x := 0.5; set x :* 3.0; x", 1.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		result, ok := evaluated.(*object.Float)
+		if !ok {
+			t.Errorf("object is not Float. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if result.Value != tt.expected {
+			t.Errorf("object has wrong value for %s. got=%g, want=%g", tt.input, result.Value, tt.expected)
+		}
+	}
+}
+
+func TestFloatComparisonAndConversion(t *testing.T) {
+	testBooleanObject(t, testEval("1.5 < 2.5"), true)
+	testBooleanObject(t, testEval("0.1 + 0.2 = 0.3"), false)
+	testBooleanObject(t, testEval("1.0 != 1.0"), false)
+	testIntegerObject(t, testEval("int(2.9)"), 2)
+	testIntegerObject(t, testEval("int(-2.9)"), -2)
+	testIntegerObject(t, testEval("int(0xFF)"), 255)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.0 + 1", "type mismatch: FLOAT + INTEGER"},
+		{"1.0 / 0.0", "division by zero"},
+		{"int(true)", "argument to int not supported, got BOOLEAN"},
+		{"int(1e300)", "cannot convert 1e+300 to int, it is out of range"},
+		{"float(1, 2)", "wrong number of arguments to float: want=1, got=2"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %s", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{3, "3.0"},
+		{-0.5, "-0.5"},
+		{1e21, "1e+21"},
+	}
+
+	for _, tt := range tests {
+		if got := (&object.Float{Value: tt.value}).Inspect(); got != tt.expected {
+			t.Errorf("wrong Inspect for %g. expected=%q, got=%q", tt.value, tt.expected, got)
+		}
+	}
+}
+
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"Hello World!"`, "Hello World!"},
+		{`"tab\tquote\" \u{1F991}"`, "tab\tquote\" \U0001F991"},
+		{`"Squid" + " " + "Script"`, "Squid Script"},
+		{`let int n := 3; "${n} squids"`, "3 squids"},
+		{`let string name := "Sydney"; "hi ${name}, ${1 + 1 > 1}!"`, "hi Sydney, true!"},
+		{`"${"${"nested"}"}"`, "nested"},
+		{`"\${not interpolated}"`, "${not interpolated}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value for %s. expected=%q, got=%q", tt.input, tt.expected, str.Value)
+		}
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" = "a"`, true},
+		{`"a" = "b"`, false},
+		{`"a" != "b"`, true},
+		{`let string s := "b"; key (s) { lock "a" { false } lock "b" { true } }`, true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestForInString(t *testing.T) {
+	input := `let string out := ""; for c in "héllo" { set out :+ c + "."; } out`
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok || str.Value != "h.é.l.l.o." {
+		t.Errorf("wrong result. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	evaluated := testEval("[1, 2 * 2, 3 + 3]")
+
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+
+	if result.Inspect() != "[1, 4, 6]" {
+		t.Errorf("Inspect() wrong. got=%q", result.Inspect())
+	}
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][2]", 3},
+		{"let int i := 0; [1][i]", 1},
+		{"[1, 2, 3][1 + 1]", 3},
+		{"let int[] xs := [1, 2, 3]; xs[2]", 3},
+		{"let int[] xs := [1, 2, 3]; xs[0] + xs[1] + xs[2]", 6},
+		{"let int[] xs := [1, 2, 3]; let int i := xs[0]; xs[i]", 2},
+		{"[[1, 2], [3]][1][0]", 3},
+		{"[1, 2, 3][3]", "index out of range [3] with length 3"},
+		{"[1, 2, 3][-1]", "index out of range [-1] with length 3"},
+		{"[][0]", "index out of range [0] with length 0"},
+		{"5[0]", "index operator not supported: INTEGER"},
+		{"[1][true]", "array index must be INTEGER or RANGE, got BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestArraySlices(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4][1..2]", "[2, 3]"},
+		{"[1, 2, 3, 4][0..3]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][2..1]", "[]"}, //a range that ends just before it starts is empty
+		{"[1, 2, 3, 4][4..3]", "[]"},
+		{"let int[] xs := [1, 2, 3]; xs[1..len(xs) - 1]", "[2, 3]"},
+		{"[1, 2, 3][1..3]", "ERROR: slice bounds out of range [1..3] with length 3"},
+		{"[1, 2, 3][-1..0]", "ERROR: slice bounds out of range [-1..0] with length 3"},
+		{"[1, 2, 3][2..0]", "ERROR: slice bounds out of range [2..0] with length 3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestForInArray(t *testing.T) {
+	input := "let int total := 0; for x in [1, 2, 3, 4][1..3] { set total :+ x; } total"
+	testIntegerObject(t, testEval(input), 9)
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let string two := "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Map)
+	if !ok {
+		t.Fatalf("Eval didn't return Map. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Map has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+
+	if result.Inspect() != "{one: 1, two: 2, three: 3, 4: 4, true: 5, false: 6}" { //in the order the keys were written
+		t.Errorf("Inspect() wrong. got=%q", result.Inspect())
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`let string name := "foo"; {"foo": 5}[name]`, 5},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+		{`{"foo": 5}["bar"]`, "key not found: bar"},
+		{`{}["foo"]`, "key not found: foo"},
+		{`{"foo": 5}[[1]]`, "unusable as map key: ARRAY"},
+		{`{1.5: 5}`, "unusable as map key: FLOAT"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestSetIndex(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let map[string]int m := {}; set m["a"] := 1; set m["b"] := 2; set m["a"] :+ 10; m`, "{a: 11, b: 2}"},
+		{"let int[] xs := [1, 2, 3]; set xs[1] :* 5; xs", "[1, 10, 3]"},
+		{"let int[][] grid := [[0, 0], [0, 0]]; set grid[1][0] := 7; grid", "[[0, 0], [7, 0]]"},
+		{"let int[] xs := [1, 2]; let int[] ys := xs[0..1]; set ys[0] := 9; xs", "[1, 2]"}, //a slice is a copy
+		{"let int[] xs := [1]; set xs[1] := 2;", "ERROR: index out of range [1] with length 1"},
+		{"let int[] xs := [1]; set xs[0..0] := [2];", "ERROR: cannot set element 0..0 of an array, the index must be INTEGER"},
+		{"let int[] xs := [1]; set xs[0] := true;", "ERROR: type mismatch: cannot set INTEGER element to BOOLEAN"},
+		{`let map[string]int m := {}; set m["a"] :+ 1;`, "ERROR: key not found: a"},
+		{`let int x := 1; set x[0] := 1;`, "ERROR: index operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestForInWithTwoVariables(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let string out := ""; for k, v in {"a": 1, "b": 2} { set out :+ "${k}=${v} "; } out`, "a=1 b=2 "},
+		{`let string out := ""; for k in {"a": 1, "b": 2} { set out :+ k; } out`, "ab"},
+		{`let string out := ""; for i, x in ["a", "b"] { set out :+ "${i}${x}"; } out`, "0a1b"},
+		{`let map[string]int m := {"a": 1}; for k in m { set m[k + k] := 2; } len(m)`, "2"}, //keys added while looping aren't visited
+		{"for i, x in 1..3 { x }", "ERROR: cannot iterate over RANGE with two variables"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestStructs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { int x; int y } Point{y: 2, x: 1}", "Point{x: 1, y: 2}"}, //fields print in the order they were declared
+		{"struct Point { int x; int y } let Point p := Point{x: 3, y: 4}; p.x * p.y", "12"},
+		{"struct Point { int x; int y } Point{x:-1, y:2}", "Point{x: -1, y: 2}"},
+		{"struct Point { int x; int y } let Point p := Point{x: 1, y: 2}; set p.x :+ 10; set p.y := 0; p", "Point{x: 11, y: 0}"},
+		{"struct Point { int x } let Point p := Point{x: 1}; let Point q := p; set q.x := 5; p.x", "5"}, //p and q are the same struct
+		{"struct Point { int x } let Point[] ps := [Point{x: 1}, Point{x: 2}]; set ps[1].x :* 10; ps", "[Point{x: 1}, Point{x: 20}]"},
+		{"struct Node { int value; Node[] children } Node{value: 1, children: [Node{value: 2, children: []}]}.children[0].value", "2"},
+		{"struct Point { int x } let Point p := Point{x: 0}; while p.x < 3 { set p.x :+ 1; } p.x", "3"},
+		{"struct Point { int x; int y } Point", "struct Point {int x; int y}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestStructErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"Point{x: 1}", "unknown type: Point"},
+		{"let int Point := 1; Point{x: 1}", "not a struct type: Point"},
+		{"struct Point { int x; int y } Point{x: 1}", "missing field y in Point literal"},
+		{"struct Point { int x } Point{x: 1, z: 2}", "unknown field z in struct Point"},
+		{"struct Point { int x } Point{x: 1, x: 2}", "duplicate field x in struct literal"},
+		{"struct Point { int x } Point{x: 1}.y", "unknown field y in struct Point"},
+		{"let int n := 1; n.x", "field access not supported: INTEGER"},
+		{"struct Point { int x } let Point p := Point{x: 1}; set p.x := true;", "type mismatch: cannot set INTEGER field x to BOOLEAN"},
+		{"struct Point { int x } let Point p := Point{x: 1}; set p.y := 1;", "unknown field y in struct Point"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message for %q. expected=%q, got=%q", tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestEnums(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"enum Month { Jan, Feb } Month.Feb", "Month.Feb"},
+		{"enum Month { Jan, Feb } Month", "enum Month {Jan, Feb}"},
+		{"enum Month { Jan, Feb } let Month m := Month.Feb; m = Month.Feb", "true"},
+		{"enum Month { Jan, Feb } Month.Jan != Month.Feb", "true"},
+		{"enum A { X } enum B { X } A.X = B.X", "false"},
+		{"enum Month { Jan, Feb, Mar } let Month m := Month.Feb; key (m) { lock Month.Jan { 31 } lock Month.Feb { 28 } lock else { 0 } }", "28"},
+		{"enum Color { Red, Blue } let map[Color]int m := {Color.Red: 1, Color.Blue: 2}; set m[Color.Red] :+ 10; m", "{Color.Red: 11, Color.Blue: 2}"},
+		{"enum Month { Jan } Month.Feb", "ERROR: unknown variant Feb in enum Month"},
+		{"enum Month { Jan } set Month.Jan := 1;", "ERROR: cannot set field Jan of ENUM_TYPE"},
+		{"enum Month { Jan } key (Month.Jan) { lock 1 { 1 } }", "ERROR: type mismatch: lock value INTEGER does not match key ENUM"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("héllo")`, 5},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`len({"a": 1, "b": 2})`, 2},
+		{`len(1)`, "argument to len not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments to len: want=1, got=2"},
+		{`func len(int x) returns int { return x; } len(7)`, 7},
+		{`len(args())`, 2},
+		{`len(args()[1])`, 5},
+		{`args(1)`, "wrong number of arguments to args: want=0, got=1"},
+	}
+
+	object.Args = []string{"one", "squid"}
+	defer func() { object.Args = nil }()
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	var out bytes.Buffer
+	object.Output = &out
+	defer func() { object.Output = os.Stdout }()
+
+	evaluated := testEval(`let int n := 2; print("squids:", n, n > 1); print("${n}!")`)
+
+	testNullObject(t, evaluated)
+	if out.String() != "squids: 2 true\n2!\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+func testEval(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return Eval(program, env)
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d",
+			result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != NULL {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
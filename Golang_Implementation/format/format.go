@@ -0,0 +1,544 @@
+//OVERVIEW: The formatter writes a parsed program back out as source code the canonical way: one statement per line, blocks indented with
+//four spaces, single spaces around operators and only the parentheses the parser needs. Comments in front of statements (and at the end
+//of blocks) are kept where they were, and a blank line between two statements stays a single blank line
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"../ast"
+	"../lexer"
+	"../parser"
+	"../token"
+)
+
+const indentation = "    "
+
+//the precedence of every infix operator, so we know which parentheses the parser needs to build the same AST
+var precedences = map[string]int{
+	"or":  parser.OR,
+	"and": parser.AND,
+	"=":   parser.EQUALS,
+	"!=":  parser.EQUALS,
+	"<":   parser.LESSGREATER,
+	">":   parser.LESSGREATER,
+	"<=":  parser.LESSGREATER,
+	">=":  parser.LESSGREATER,
+	"+":   parser.SUM,
+	"-":   parser.SUM,
+	"*":   parser.PRODUCT,
+	"/":   parser.PRODUCT,
+	"%":   parser.PRODUCT,
+}
+
+const primary = parser.INDEX + 1 //literals, identifiers and anything else that never needs parentheses around it
+
+//This is what is constructed; the main template/structure of the formatter
+type printer struct {
+	out bytes.Buffer
+
+	indent     int
+	line       int          //the line in the source where the last thing we wrote ended, 0 before anything was written
+	blockStart bool         //whether we just opened a block (no blank line goes right after a {)
+	noStruct   bool         //whether a struct literal has to be wrapped in ( ), because a { here would start a block
+	placed     map[int]bool //the offsets of the comments that were written out
+}
+
+//REQUIRES: a program that parsed without errors, and the source it was parsed from
+//MODIFIES:
+//EFFECTS: returns program written out the canonical way. Returns an error if the source has a comment the formatter can't put back (ie one
+//in the middle of an expression), rather than dropping it
+func Source(program *ast.Program, src string) (string, error) {
+	p := &printer{placed: map[int]bool{}}
+	comments, trailing := scanComments(src)
+
+	p.statements(program.Statements)
+	p.comments(trailing) //the comments after the last statement
+
+	for _, c := range comments {
+		if !p.placed[c.Pos.Offset] {
+			return "", fmt.Errorf("%d:%d: the comment %s can't be kept where it is, move it in front of a statement", c.Pos.Line, c.Pos.Column, shorten(c.Text))
+		}
+	}
+
+	if p.out.Len() > 0 {
+		p.out.WriteString("\n")
+	}
+	return p.out.String(), nil
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Comments and lines
+
+//REQUIRES: the line in the source that the next thing we write comes from
+//MODIFIES: p
+//EFFECTS: ends the line we are on and indents the next one. A blank line between two statements in the source is kept (but only one)
+func (p *printer) newline(line int) {
+	if p.out.Len() > 0 {
+		p.out.WriteString("\n")
+		if !p.blockStart && line > p.line+1 {
+			p.out.WriteString("\n")
+		}
+	}
+	p.blockStart = false
+	p.out.WriteString(strings.Repeat(indentation, p.indent))
+}
+
+//REQUIRES: the comments in front of a token
+//MODIFIES: p
+//EFFECTS: writes the comments out. One that was on the same line as what we wrote last stays at the end of that line, the others are
+//each put on a line of their own
+func (p *printer) comments(comments []token.Comment) {
+	for _, c := range comments {
+		if p.line > 0 && c.Pos.Line == p.line {
+			p.out.WriteString(" " + c.Text)
+		} else {
+			p.newline(c.Pos.Line)
+			p.out.WriteString(c.Text)
+		}
+		p.line = c.End.Line
+		p.placed[c.Pos.Offset] = true
+	}
+}
+
+//REQUIRES: the first token of a statement (or of a field, variant or lock)
+//MODIFIES: p
+//EFFECTS: writes the comments in front of the token and starts the line the token goes on
+func (p *printer) startLine(tok token.Token) {
+	p.comments(tok.Comments)
+	p.newline(tok.Pos.Line)
+}
+
+//REQUIRES: the } that closes a block
+//MODIFIES: p
+//EFFECTS: writes the comments at the end of the block, then the } on a line of its own
+func (p *printer) closeBlock(rbrace token.Token) {
+	p.comments(rbrace.Comments)
+	p.indent--
+	p.blockStart = true //no blank line right before a } either
+	p.newline(rbrace.Pos.Line)
+	p.out.WriteString("}")
+	p.line = rbrace.Pos.Line
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Statements
+
+func (p *printer) statements(stmts []ast.Statement) {
+	for i, stmt := range stmts {
+		var next ast.Statement
+		if i+1 < len(stmts) {
+			next = stmts[i+1]
+		}
+		p.statement(stmt, next)
+	}
+}
+
+//REQUIRES: a statement, and the statement after it in the same block (nil if it is the last one)
+//MODIFIES: p
+//EFFECTS: writes the statement out on a line of its own (or several, if it has a block)
+func (p *printer) statement(stmt ast.Statement, next ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.startLine(stmt.Token)
+		p.out.WriteString("let " + stmt.Type.String() + " " + stmt.Name.Value + " := ")
+		p.expression(stmt.Value, parser.LOWEST)
+		p.out.WriteString(";")
+
+	case *ast.SetStatement:
+		p.startLine(stmt.Token)
+		p.out.WriteString("set ")
+		p.expression(stmt.Target, parser.LOWEST)
+		p.out.WriteString(" " + stmt.Operator + " ")
+		p.expression(stmt.Value, parser.LOWEST)
+		p.out.WriteString(";")
+
+	case *ast.ReturnStatement:
+		p.startLine(stmt.Token)
+		if stmt.ReturnValue == nil {
+			p.out.WriteString("return;")
+			break
+		}
+		p.out.WriteString("return ")
+		p.expression(stmt.ReturnValue, parser.LOWEST)
+		p.out.WriteString(";")
+
+	case *ast.ExpressionStatement:
+		p.startLine(stmt.Token)
+		p.expression(stmt.Expression, parser.LOWEST)
+		if !endsWithBlock(stmt.Expression) {
+			p.out.WriteString(";")
+		} else if _, ok := next.(*ast.ExpressionStatement); ok { //without the ; the next expression would carry on this one (ie '-1' would subtract)
+			p.out.WriteString(";")
+		}
+
+	case *ast.BreakStatement:
+		p.startLine(stmt.Token)
+		p.out.WriteString("break;")
+
+	case *ast.ContinueStatement:
+		p.startLine(stmt.Token)
+		p.out.WriteString("continue;")
+
+	case *ast.FunctionDeclaration:
+		p.startLine(stmt.Token)
+		p.function(stmt.Function)
+
+	case *ast.WhileStatement:
+		p.startLine(stmt.Token)
+		p.out.WriteString("while ")
+		p.structFree(stmt.Condition)
+		p.out.WriteString(" ")
+		p.block(stmt.Body)
+
+	case *ast.ForInStatement:
+		p.startLine(stmt.Token)
+		p.out.WriteString("for " + stmt.Variable.Value)
+		if stmt.Value != nil {
+			p.out.WriteString(", " + stmt.Value.Value)
+		}
+		p.out.WriteString(" in ")
+		p.structFree(stmt.Iterable)
+		p.out.WriteString(" ")
+		p.block(stmt.Body)
+
+	case *ast.KeyStatement:
+		p.keyStatement(stmt)
+
+	case *ast.StructDeclaration:
+		p.startLine(stmt.Token)
+		p.out.WriteString("struct " + stmt.Name.Value + " {")
+		p.line = stmt.Name.Token.Pos.Line
+		p.indent++
+		p.blockStart = true
+		for _, f := range stmt.Fields {
+			p.startLine(typeToken(f.Type))
+			p.out.WriteString(f.Type.String() + " " + f.Name.Value + ";")
+			p.line = f.Name.Token.Pos.Line
+		}
+		p.closeBlock(stmt.Rbrace)
+
+	case *ast.EnumDeclaration:
+		p.startLine(stmt.Token)
+		p.out.WriteString("enum " + stmt.Name.Value + " {")
+		p.line = stmt.Name.Token.Pos.Line
+		p.indent++
+		p.blockStart = true
+		for i, v := range stmt.Variants {
+			p.startLine(v.Token)
+			p.out.WriteString(v.Value)
+			if i < len(stmt.Variants)-1 { //the parser doesn't take a , after the last variant
+				p.out.WriteString(",")
+			}
+			p.line = v.Token.Pos.Line
+		}
+		p.closeBlock(stmt.Rbrace)
+
+	case *ast.BlockStatement:
+		p.newline(stmt.Token.Pos.Line)
+		p.block(stmt)
+
+	default: //only a program with parser errors has anything else in it
+		p.newline(stmt.Pos().Line)
+		p.out.WriteString(stmt.String())
+	}
+
+	p.line = stmt.End().Line
+}
+
+func (p *printer) keyStatement(ks *ast.KeyStatement) {
+	p.startLine(ks.Token)
+	p.out.WriteString("key (")
+	for i, k := range ks.Keys {
+		if i > 0 {
+			p.out.WriteString(", ")
+		}
+		p.expression(k, parser.LOWEST)
+	}
+	p.out.WriteString(") {")
+	p.line = ks.Token.Pos.Line
+	p.indent++
+	p.blockStart = true
+
+	for _, lock := range ks.Locks {
+		p.startLine(lock.Token)
+		p.out.WriteString("lock ")
+		for i, v := range lock.Values {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			p.structFree(v)
+		}
+		p.out.WriteString(" ")
+		p.block(lock.Body)
+	}
+
+	if ks.Else != nil {
+		p.startLine(ks.ElseToken)
+		p.out.WriteString("lock else ")
+		p.block(ks.Else)
+	}
+
+	p.closeBlock(ks.Rbrace)
+}
+
+//REQUIRES: a block whose { goes at the end of the line we are on
+//MODIFIES: p
+//EFFECTS: writes the block out with its statements indented one level deeper. An empty block stays on one line ({})
+func (p *printer) block(b *ast.BlockStatement) {
+	if len(b.Statements) == 0 && len(b.Rbrace.Comments) == 0 {
+		p.out.WriteString("{}")
+		p.line = b.Rbrace.Pos.Line
+		return
+	}
+
+	p.out.WriteString("{")
+	p.line = b.Token.Pos.Line
+	p.indent++
+	p.blockStart = true
+	p.statements(b.Statements)
+	p.closeBlock(b.Rbrace)
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Expressions
+
+//REQUIRES: an expression, and the precedence of the operator it is an operand of (parser.LOWEST if it isn't one)
+//MODIFIES: p
+//EFFECTS: writes the expression out, in parentheses if the parser would otherwise group it differently
+func (p *printer) expression(exp ast.Expression, precedence int) {
+	if precedenceOf(exp) < precedence {
+		p.parenthesized(exp)
+		return
+	}
+
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		p.out.WriteString(exp.Operator)
+		p.expression(exp.Right, parser.PREFIX)
+
+	case *ast.InfixExpression:
+		op := precedences[exp.Operator]
+		p.expression(exp.Left, op)
+		p.out.WriteString(" " + exp.Operator + " ")
+		p.expression(exp.Right, op+1) //operators group to the left, so an equal operator on the right needs parentheses (ie a - (b - c))
+
+	case *ast.RangeExpression:
+		p.expression(exp.First, parser.RANGE)
+		p.out.WriteString("..")
+		p.expression(exp.Last, parser.RANGE+1)
+
+	case *ast.CallExpression:
+		p.expression(exp.Function, parser.CALL)
+		p.out.WriteString("(")
+		p.list(exp.Arguments)
+		p.out.WriteString(")")
+
+	case *ast.IndexExpression:
+		p.expression(exp.Left, parser.CALL) //calls, indexes and selectors all chain to the left (ie f(x)[0].y)
+		p.out.WriteString("[")
+		p.list([]ast.Expression{exp.Index})
+		p.out.WriteString("]")
+
+	case *ast.SelectorExpression:
+		p.expression(exp.Left, parser.CALL)
+		p.out.WriteString("." + exp.Field.Value)
+
+	case *ast.ArrayLiteral:
+		p.out.WriteString("[")
+		p.list(exp.Elements)
+		p.out.WriteString("]")
+
+	case *ast.HashLiteral:
+		restore := p.setNoStruct(false)
+		p.out.WriteString("{")
+		for i, pair := range exp.Pairs {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			p.expression(pair.Key, parser.LOWEST)
+			p.out.WriteString(": ")
+			p.expression(pair.Value, parser.LOWEST)
+		}
+		p.out.WriteString("}")
+		restore()
+
+	case *ast.StructLiteral:
+		if p.noStruct {
+			p.parenthesized(exp)
+			return
+		}
+		p.out.WriteString(exp.Name.Value + "{")
+		for i, f := range exp.Fields {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			p.out.WriteString(f.Name.Value + ": ")
+			p.expression(f.Value, parser.LOWEST)
+		}
+		p.out.WriteString("}")
+
+	case *ast.FunctionLiteral:
+		p.function(exp)
+
+	case *ast.IfExpression:
+		p.out.WriteString("if (")
+		p.structFree(exp.Condition)
+		p.out.WriteString(") ")
+		p.block(exp.Consequence)
+		if exp.Alternative != nil {
+			p.out.WriteString(" else ")
+			p.block(exp.Alternative)
+		}
+
+	case *ast.InterpolatedString:
+		restore := p.setNoStruct(false)
+		p.out.WriteString("\"")
+		for _, part := range exp.Parts {
+			if text, ok := part.(*ast.StringLiteral); ok { //text is written the way it was, escapes and all
+				p.out.WriteString(text.Token.Literal[1 : len(text.Token.Literal)-1])
+				continue
+			}
+			p.out.WriteString("${")
+			p.expression(part, parser.LOWEST) //the ${ } already group it, so it never needs parentheses of its own
+			p.out.WriteString("}")
+		}
+		p.out.WriteString("\"")
+		restore()
+
+	default: //identifiers and literals are written the way they were in the source (so 0xFF stays 0xFF)
+		p.out.WriteString(exp.String())
+	}
+}
+
+//REQUIRES: an expression
+//MODIFIES: p
+//EFFECTS: writes the expression out in parentheses
+func (p *printer) parenthesized(exp ast.Expression) {
+	restore := p.setNoStruct(false) //inside of ( ) a { can't be the start of a block
+	p.out.WriteString("(")
+	p.expression(exp, parser.LOWEST)
+	p.out.WriteString(")")
+	restore()
+}
+
+//REQUIRES: expressions separated by commas (ie arguments or elements)
+//MODIFIES: p
+//EFFECTS: writes the expressions out with ", " between them
+func (p *printer) list(exps []ast.Expression) {
+	restore := p.setNoStruct(false)
+	for i, exp := range exps {
+		if i > 0 {
+			p.out.WriteString(", ")
+		}
+		p.expression(exp, parser.LOWEST)
+	}
+	restore()
+}
+
+//REQUIRES: an expression that is followed by the { of a block (ie the condition of a while loop)
+//MODIFIES: p
+//EFFECTS: writes the expression out, with any struct literal in it wrapped in parentheses so its { isn't taken for the block
+func (p *printer) structFree(exp ast.Expression) {
+	restore := p.setNoStruct(true)
+	p.expression(exp, parser.LOWEST)
+	restore()
+}
+
+func (p *printer) setNoStruct(off bool) func() {
+	old := p.noStruct
+	p.noStruct = off
+	return func() { p.noStruct = old }
+}
+
+func (p *printer) function(fl *ast.FunctionLiteral) {
+	restore := p.setNoStruct(false)
+	p.out.WriteString("func")
+	if fl.Name != "" {
+		p.out.WriteString(" " + fl.Name)
+	}
+	p.out.WriteString("(")
+	for i, param := range fl.Parameters {
+		if i > 0 {
+			p.out.WriteString(", ")
+		}
+		p.out.WriteString(param.String())
+	}
+	p.out.WriteString(") ")
+	if fl.ReturnType != nil {
+		p.out.WriteString("returns " + fl.ReturnType.String() + " ")
+	}
+	p.block(fl.Body)
+	restore()
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Helpers
+
+//REQUIRES: an expression
+//MODIFIES:
+//EFFECTS: returns how tightly the expression holds together, using the parser's precedences
+func precedenceOf(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return precedences[exp.Operator]
+	case *ast.RangeExpression:
+		return parser.RANGE
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression, *ast.IndexExpression, *ast.SelectorExpression:
+		return parser.CALL
+	default:
+		return primary
+	}
+}
+
+//REQUIRES: an expression
+//MODIFIES:
+//EFFECTS: returns whether the expression ends with the } of a block, in which case it doesn't need a ; after it
+func endsWithBlock(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.IfExpression, *ast.FunctionLiteral:
+		return true
+	default:
+		return false
+	}
+}
+
+//REQUIRES: a type
+//MODIFIES:
+//EFFECTS: returns the first token of the type, which has the comments in front of it
+func typeToken(t ast.TypeNode) token.Token {
+	switch t := t.(type) {
+	case *ast.NamedType:
+		return t.Token
+	case *ast.ArrayType:
+		return typeToken(t.Element)
+	case *ast.MapType:
+		return t.Token
+	case *ast.FunctionType:
+		return t.Token
+	default:
+		return token.Token{Pos: t.Pos()}
+	}
+}
+
+//REQUIRES: source code
+//MODIFIES:
+//EFFECTS: returns every comment in the source in order, and the ones after its last token
+func scanComments(src string) ([]token.Comment, []token.Comment) {
+	var comments []token.Comment
+	l := lexer.New(src)
+	for {
+		tok := l.NextToken()
+		comments = append(comments, tok.Comments...)
+		if tok.Type == token.EOF {
+			return comments, tok.Comments
+		}
+	}
+}
+
+func shorten(text string) string { //the first line of a comment is enough to find it
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		return text[:i] + "..."
+	}
+	return text
+}
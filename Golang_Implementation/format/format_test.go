@@ -0,0 +1,135 @@
+package format
+
+import (
+	"testing"
+
+	"../ast"
+	"../lexer"
+	"../parser"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"let   int x:=1+2*3 ;set x :+ 1", "let int x := 1 + 2 * 3;\nset x :+ 1;\n"},
+		{"(1 + 2) * 3; 1 - (2 - 3); (1 - 2) - 3; -(1 + 2); !(x and y); a or b and c; (a or b) and c",
+			"(1 + 2) * 3;\n1 - (2 - 3);\n1 - 2 - 3;\n-(1 + 2);\n!(x and y);\na or b and c;\n(a or b) and c;\n"},
+		{"xs[1..len(xs) - 1]; f(x)[0].y; (-x)[0]; 0xFF + 1_000", "xs[1..len(xs) - 1];\nf(x)[0].y;\n(-x)[0];\n0xFF + 1_000;\n"},
+		{`print("hi ${name}", [1, 2], {"a": 1}, P{x: 1})`, "print(\"hi ${name}\", [1, 2], {\"a\": 1}, P{x: 1});\n"},
+		{`print("${xs[0]}, ${a+b} ${ -x }\n\${no} ${"${P{x: 1}.x}"}")`, "print(\"${xs[0]}, ${a + b} ${-x}\\n\\${no} ${\"${P{x: 1}.x}\"}\");\n"}, //the ${ } are parentheses enough
+		{"func add(int x, int y) returns int { return x + y; } let func() returns int f := func() returns int { return 1; };",
+			"func add(int x, int y) returns int {\n    return x + y;\n}\nlet func() returns int f := func() returns int {\n    return 1;\n};\n"},
+		{"while true { if (x) { break; } else { continue; } }",
+			"while true {\n    if (x) {\n        break;\n    } else {\n        continue;\n    }\n}\n"},
+		{"if (x) { 1 }; -1", "if (x) {\n    1;\n};\n-1;\n"}, //without the ; the if would be subtracted from
+		{"if (x) { 1 } let int y := 2; func f() {}", "if (x) {\n    1;\n}\nlet int y := 2;\nfunc f() {}\n"},
+		{"for k, v in m { print(k, v) } for p in (P{x: 1}).x..2 {} while (P{x: 1}).x > 0 {}",
+			"for k, v in m {\n    print(k, v);\n}\nfor p in (P{x: 1}).x..2 {}\nwhile (P{x: 1}).x > 0 {}\n"},
+		{"key (x, y) { lock 1, 2 { a } lock else { b } }",
+			"key (x, y) {\n    lock 1, 2 {\n        a;\n    }\n    lock else {\n        b;\n    }\n}\n"},
+		{"key (x) {\n  lock 1 { a } //one\n  //before else\n  lock else { b } //two\n}",
+			"key (x) {\n    lock 1 {\n        a;\n    } //one\n    //before else\n    lock else {\n        b;\n    } //two\n}\n"},
+		{"struct Point { int x; int[] ys } enum Month { Jan, Feb }",
+			"struct Point {\n    int x;\n    int[] ys;\n}\nenum Month {\n    Jan,\n    Feb\n}\n"},
+	}
+
+	for _, tt := range tests {
+		formatted := format(t, tt.input)
+		if formatted != tt.expected {
+			t.Errorf("wrong formatting of %q.\nexpected=%q\ngot     =%q", tt.input, tt.expected, formatted)
+			continue
+		}
+		if again := format(t, formatted); again != formatted { //formatting is only worth anything if it is stable
+			t.Errorf("formatting %q again changed it.\nfirst =%q\nsecond=%q", tt.input, formatted, again)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `//the header
+
+let int x := 1; //trailing
+
+
+/* before y */
+let int y := 2;
+func f() { //opens
+  //inside
+  print(x);
+
+  print(y); //last
+}
+struct P {
+  //the x
+  int x;
+}
+//at the end`
+
+	expected := `//the header
+
+let int x := 1; //trailing
+
+/* before y */
+let int y := 2;
+func f() { //opens
+    //inside
+    print(x);
+
+    print(y); //last
+}
+struct P {
+    //the x
+    int x;
+}
+//at the end
+`
+
+	formatted := format(t, input)
+	if formatted != expected {
+		t.Errorf("wrong formatting.\nexpected=%q\ngot     =%q", expected, formatted)
+	}
+}
+
+func TestCommentsThatCantBeKept(t *testing.T) {
+	input := "let int x := 1 + /* two */ 2;"
+
+	_, err := Source(parse(t, input), input)
+	if err == nil || err.Error() != "1:18: the comment /* two */ can't be kept where it is, move it in front of a statement" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+//the formatter must never change what a program means, so the formatted program has to parse to the same AST
+func TestSameAST(t *testing.T) {
+	input := `let int n := -(2 - 3) * (4 + 5) % 6;
+let bool b := !(n > 2 or n < 0) and (true or false);
+func apply(func(int) returns int f, int x) returns int { return f(x); }
+print(apply(func(int x) returns int { return x * 2; }, n)..10, "${n + 1} ${[n][0]}");
+key (n) { lock 1 { print(1) } lock else { print(2) } }`
+
+	program := parse(t, input)
+	formatted := format(t, input)
+	if parse(t, formatted).String() != program.String() {
+		t.Errorf("formatting changed the program.\nbefore=%s\nafter =%s", program.String(), parse(t, formatted).String())
+	}
+}
+
+func format(t *testing.T, input string) string {
+	formatted, err := Source(parse(t, input), input)
+	if err != nil {
+		t.Fatalf("Source failed on %q: %s", input, err)
+	}
+	return formatted
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors in %q: %v", input, p.Errors())
+	}
+	return program
+}
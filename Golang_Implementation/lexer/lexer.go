@@ -2,42 +2,100 @@
 
 package lexer
 
-import "../token"
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"../diagnostic"
+	"../token"
+)
+
+//the characters that can follow a : and the operator token the pair makes up
+var colonOperators = map[rune]token.TokenType{
+	'=': token.WALRUS,
+	'+': token.PLUS_ASSIGN,
+	'-': token.MINUS_ASSIGN,
+	'*': token.ASTERISK_ASSIGN,
+	'/': token.SLASH_ASSIGN,
+}
 
 //This is what is constructed; the main template/structure of the lexer
 type Lexer struct {
 	input        string // code provided by user
 	position     int    // current position in input (points to current char)
 	readPosition int    // current reading position in input (after current char)
-	ch           byte   // current char under examination
+	ch           rune   // current char under examination (a whole UTF-8 encoded character, which may be several bytes of input)
+
+	filename string // where input came from, which ends up in the position of every token (empty for the REPL)
+	line     int    // line of the current char
+	column   int    // column of the current char
+	offset   int    // offset of the start of input in the file (not 0 when lexing a piece of a file, ie the expression in a "${...}")
+
+	diagnostics diagnostic.List // problems found while reading the input (ie a block comment that never ends)
 } //end Lexer struct
 
 //REQUIRES: a string input
 //MODIFIES:
 //EFFECTS: creates lexer structure
 func New(src string) *Lexer { //serves as a lexer constructor
-	l := &Lexer{input: src} //the lexer structure l recieves src (source code) as input
-	l.readChar()            //reads the first character of the input and adjusts position and read position accordingly
-	return l                //returns the lexer
+	return NewFile("", src)
 } //end constructor
 
+//REQUIRES: the name of the file src was read from and its contents
+//MODIFIES:
+//EFFECTS: creates lexer structure whose tokens are positioned in filename
+func NewFile(filename string, src string) *Lexer {
+	l := &Lexer{input: src, filename: filename, line: 1} //the lexer structure l recieves src (source code) as input
+	l.readChar()                                         //reads the first character of the input and adjusts position and read position accordingly
+	return l                                             //returns the lexer
+}
+
+//REQUIRES: a piece of source code and the position it starts at in its file
+//MODIFIES:
+//EFFECTS: creates lexer structure whose tokens are positioned as if src was still in its file (used to lex the expression in a "${...}")
+func NewAt(src string, start token.Position) *Lexer {
+	l := &Lexer{input: src, filename: start.Filename, line: start.Line, column: start.Column - 1, offset: start.Offset} //readChar moves onto the first column
+	l.readChar()
+	return l
+}
+
 //REQUIRES: a lexer for input (previous method)
 //MODIFIES:
 //EFFECTS: tokenizes ch (current char under examination)
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token // a token variable of the struct defined above
 
-	l.skipWhitespace() //the function reads characters as long as they are whitespace until it has skipped all the whitespace between two other characters
+	comments := l.skipWhitespaceAndComments() //reads characters as long as they are whitespace or comments until it has skipped everything between two tokens
+
+	start := l.currentPosition() //the token starts at the first char after the whitespace
 
 	switch l.ch { //switch statement that identifies the current character in l and then tokenizes the character based on what it is and what char's surround it
 	case '=':
-		if l.peekChar() == '=' { //if the next character is a second =, meaning that the two == make a boolean operator
+		if l.peekChar() == '=' { //== from other languages. We still read it as an equality so parsing carries on, but the program won't run
+			l.readChar()
+			tok = token.Token{Type: token.EQ, Literal: "=="}
+			span := diagnostic.Span{Start: start, End: start.Advance("==")}
+			l.diagnostics.Add(diagnostic.Diagnostic{
+				Severity:    diagnostic.Error,
+				Code:        diagnostic.DoubleEquals,
+				Message:     "unexpected ==, did you mean =?",
+				Span:        span,
+				Notes:       []string{"a single = compares values in SquidScript, since := is what assigns them"},
+				Suggestions: []diagnostic.Suggestion{{Message: "replace == with =", Span: span, Replacement: "="}},
+			})
+		} else { //a single = is the equality operator
+			tok = newToken(token.EQ, l.ch)
+		}
+	case ':':
+		if tokenType, ok := colonOperators[l.peekChar()]; ok && !l.commentAfterColon() { //if the next character is =, +, -, * or /, the two make up an assignment/update operator (ie := or :+)
 			ch := l.ch
 			l.readChar()
 			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.EQ, Literal: literal}
-		} else { //the next character is not another = and therefore l.ch is an assignment = and not a boolean ==
-			tok = newToken(token.ASSIGN, l.ch)
+			tok = token.Token{Type: tokenType, Literal: literal}
+		} else { //a lone : separates a key from its value in a hash literal
+			tok = newToken(token.COLON, l.ch)
 		}
 	case '+':
 		tok = newToken(token.PLUS, l.ch)
@@ -57,13 +115,38 @@ func (l *Lexer) NextToken() token.Token {
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.peekChar() == '=' { //<= rather than <
+			l.readChar()
+			tok = token.Token{Type: token.LT_EQ, Literal: "<="}
+		} else {
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '=' { //>= rather than >
+			l.readChar()
+			tok = token.Token{Type: token.GT_EQ, Literal: ">="}
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '.':
+		if l.peekChar() == '.' { //two dots make up the range operator ..
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.DOTDOT, Literal: literal}
+		} else { //a lone . selects a field (ie p.x)
+			tok = newToken(token.DOT, l.ch)
+		}
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
 	case '{':
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
@@ -72,6 +155,10 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
+	case '"':
+		tok.Type = token.STRING
+		tok.Literal = l.readString()          //the literal is the whole string, quotes and all. The parser picks apart its escapes and interpolations
+		return l.locate(tok, start, comments) //readString already stepped past the closing "
 	case 0: //reached end of input so we need to create a EOF (end of file) token
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -79,22 +166,93 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) { //is ch a letter
 			tok.Literal = l.readIdentifier()          //ch is a letter so we need to read until the next space and determine if Literal is a keyword
 			tok.Type = token.LookupIdent(tok.Literal) //returns the appropraite keyword type if Literal is a keyword, otherwise returns IDENT token type
-			return tok                                // returns tok which contains Literal and token type
+			return l.locate(tok, start, comments)     // returns tok which contains Literal and token type
 		} else if isDigit(l.ch) { //is ch a number
-			tok.Type = token.INT         //the character is an integer, so its token is assigned token type int
-			tok.Literal = l.readNumber() //the literal becomes the entire number (until the next whitespace)
-			return tok                   // returns tok which contains Literal and token Type
+			tok.Type, tok.Literal = l.readNumber() //the literal becomes the entire number, and the type says whether it is an int or a float
+			return l.locate(tok, start, comments)  // returns tok which contains Literal and token Type
 		} else { //the character is not a digit nor is it a letter, therefore it is some illegal character the language will not support
-			tok = newToken(token.ILLEGAL, l.ch)
+			tok = token.Token{Type: token.ILLEGAL, Literal: l.input[l.position:l.readPosition]} //the bytes themselves, since an invalid one has no char to show
 		}
 	} //end cases
 
 	l.readChar()
-	return tok
+	return l.locate(tok, start, comments)
 } //end NextToken
 
+//REQUIRES:
+//MODIFIES:
+//EFFECTS: returns the problems found in the input so far
+func (l *Lexer) Diagnostics() diagnostic.List {
+	return l.diagnostics
+}
+
 //%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% LEXER HELPER METHODS
 
+//REQUIRES: a lexer structure l
+//MODIFIES: position will become the location of the next char that is neither whitespace nor part of a comment
+//EFFECTS: skips whitespace and comments, returning the comments in the order they were found
+func (l *Lexer) skipWhitespaceAndComments() []token.Comment {
+	var comments []token.Comment
+	for {
+		l.skipWhitespace()
+
+		if l.ch == '/' && l.peekChar() == '/' { // a line comment
+			comments = append(comments, l.readLineComment())
+		} else if l.ch == '/' && l.peekChar() == '*' { // a block comment
+			comments = append(comments, l.readBlockComment())
+		} else { //we have reached the next token
+			return comments
+		}
+	}
+}
+
+//REQUIRES: a lexer structure l whose current char is the first / of a //
+//MODIFIES: changes position and readPosition to the end of the line
+//EFFECTS: returns the comment, which runs until the end of the line (the newline isn't part of it)
+func (l *Lexer) readLineComment() token.Comment {
+	start := l.currentPosition()
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	return token.Comment{Text: l.input[start.Offset-l.offset : l.position], Pos: start, End: l.currentPosition()}
+}
+
+//REQUIRES: a lexer structure l whose current char is the / of a /*
+//MODIFIES: changes position and readPosition to just past the */ that closes the comment, and adds a diagnostic if it is never closed
+//EFFECTS: returns the comment. Block comments nest, so '/* a /* b */ c */' is a single comment
+func (l *Lexer) readBlockComment() token.Comment {
+	start := l.currentPosition()
+	depth := 0
+
+	for {
+		if l.ch == 0 { //we ran out of input before every /* was closed
+			l.diagnostics.Add(diagnostic.Diagnostic{
+				Severity: diagnostic.Error,
+				Code:     diagnostic.UnterminatedComment,
+				Message:  "unterminated block comment",
+				Span:     diagnostic.Span{Start: start, End: token.Position{Filename: start.Filename, Line: start.Line, Column: start.Column + 2, Offset: start.Offset + 2}},
+				Notes:    []string{"block comments nest, so every /* inside of it needs a */ too"},
+			})
+			break
+		}
+
+		if l.ch == '/' && l.peekChar() == '*' { //a comment opened (maybe inside of another one)
+			depth++
+			l.readChar()
+		} else if l.ch == '*' && l.peekChar() == '/' { //a comment closed
+			depth--
+			l.readChar()
+			if depth == 0 {
+				l.readChar() //step past the closing /
+				break
+			}
+		}
+		l.readChar()
+	}
+
+	return token.Comment{Text: l.input[start.Offset-l.offset : l.position], Pos: start, End: l.currentPosition()}
+}
+
 //REQUIRES: a lexer structure l
 //MODIFIES: position will become the location of the next non-whitespace char in input. readPosition will become the location proceeding position's location
 //EFFECTS: the position; calls readChar() and reads the next posititon/ skips to next position as long as the current character is whitespace
@@ -105,35 +263,86 @@ func (l *Lexer) skipWhitespace() {
 } //end skipWhiteSpace
 
 //REQUIRES: a lexer structure l
-//MODIFIES: the current position and readPosition are incremented
-//EFFECTS: reads the next character in the input
+//MODIFIES: the current position and readPosition are incremented (readPosition by however many bytes the char takes up). Adds a diagnostic if
+//the next char isn't valid UTF-8
+//EFFECTS: reads the next character in the input. Columns count characters rather than bytes, so a caret under column 5 of 'größe' points at the e
 func (l *Lexer) readChar() {
+	if l.readPosition > len(l.input) { //already past the end of the input, so we stay put (every token from here on is EOF at the same position)
+		return
+	}
+
+	if l.ch == '\n' { //the char we are leaving ends a line
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+
+	size := 1
 	if l.readPosition >= len(l.input) { //the read position is outside the range of the input (we have reached the end of the input) so we need to make l.ch = 0 so that an EOF token can be made
 		l.ch = 0 //we return 0 because we've reached the outside of our input
 	} else { //the read position is INSIDE the range of our input
-		l.ch = l.input[l.readPosition] //we read the next char and assign its value to l.ch
+		l.ch, size = utf8.DecodeRuneInString(l.input[l.readPosition:]) //we read the next char and assign its value to l.ch
 	}
 	l.position = l.readPosition //position becomes the location of the current char
-	l.readPosition += 1         //we increment readPosition so that the next character is ready to be read
+	l.readPosition += size      //we move readPosition past the char so that the next character is ready to be read
+
+	if l.ch == utf8.RuneError && size == 1 { //a byte that doesn't start a valid UTF-8 encoded char (a real U+FFFD is 3 bytes long)
+		start := l.currentPosition()
+		l.diagnostics.Add(diagnostic.Diagnostic{
+			Severity: diagnostic.Error,
+			Code:     diagnostic.InvalidUTF8,
+			Message:  fmt.Sprintf("invalid UTF-8 encoding (byte %#x)", l.input[l.position]),
+			Span:     diagnostic.Span{Start: start, End: token.Position{Filename: start.Filename, Line: start.Line, Column: start.Column + 1, Offset: start.Offset + 1}},
+			Notes:    []string{"source code has to be saved as UTF-8"},
+		})
+	}
 } //end readChar
 
+//REQUIRES: a lexer structure l
+//MODIFIES:
+//EFFECTS: returns the position of the current char
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{Filename: l.filename, Line: l.line, Column: l.column, Offset: l.offset + l.position}
+}
+
+//REQUIRES: a token that was just read, the position it started at and the comments that came before it
+//MODIFIES:
+//EFFECTS: returns tok with its start and end positions (the end is wherever the lexer stopped reading) and comments filled in
+func (l *Lexer) locate(tok token.Token, start token.Position, comments []token.Comment) token.Token {
+	tok.Pos = start
+	tok.End = l.currentPosition()
+	tok.Comments = comments
+	return tok
+}
+
 //REQUIRES: a lexer structure l
 //MODIFIES:
 //EFFECTS: returns the current char at readPosition of the inputted lexer l, or 0 (for an EOF token)
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) { //the current readPosition is outside the input/ has reached the end of the input
 		return 0 //returns 0 because we are outside the range of the input
 	} else { //readposition is less than the length of the input/ still inside the range
-		return l.input[l.readPosition] //returns the char at the readPosition of the input
+		r, _ := utf8.DecodeRuneInString(l.input[l.readPosition:]) //returns the char at the readPosition of the input
+		return r
 	}
 } //end peekChar
 
+//REQUIRES: a lexer structure l whose current char is a :
+//MODIFIES:
+//EFFECTS: returns whether a comment starts right after the : (ie '{"a":/* note */ 1}'), in which case the / belongs to the comment rather
+//than making up :/ with the :
+func (l *Lexer) commentAfterColon() bool {
+	rest := l.input[l.readPosition:]
+	return strings.HasPrefix(rest, "//") || strings.HasPrefix(rest, "/*")
+}
+
 //REQUIRES:a lexer structure l
 //MODIFIES: changes position and readPosition to relect the end of the identifier/label
 //EFFECTS: returns a string of chars representing an identifier/label. Ex: returns 'apple' in "let apple = 530;"
 func (l *Lexer) readIdentifier() string {
-	position := l.position //start of identifier
-	for isLetter(l.ch) {   //read all the letters of the identifier
+	position := l.position       //start of identifier
+	for isIdentifierChar(l.ch) { //read all the letters (and digits) of the identifier
 		l.readChar()
 	} //end for
 	return l.input[position:l.position] // return string of identifier so that it can become the string literal for that identifier's token
@@ -141,32 +350,74 @@ func (l *Lexer) readIdentifier() string {
 
 //REQUIRES: a lexer structure l
 //MODIFIES: changes position and readPosition to relect the end of the number
-//EFFECTS: returns a string of chars representing an number. Ex: returns '530' in "let apple = 530;"
-func (l *Lexer) readNumber() string {
+//EFFECTS: returns the token type (INT or FLOAT) and a string of chars representing a number. Ex: returns INT '530' in "let int apple := 530;",
+//FLOAT '3.14' in "3.14 * r", INT '0xFF' in "0xFF" and INT '1_000' in "1_000". Whether the digits actually make up a valid number is up to the parser
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	position := l.position //start of number
-	for isDigit(l.ch) {    //read all the digits of the number
+
+	if l.ch == '0' && strings.ContainsRune("xXbB", l.peekChar()) { //a hex or binary integer
 		l.readChar()
-	} //end for
-	return l.input[position:l.position] // return string of full number so that it can become the string literal for that number's token
+		l.readChar()
+		for isLetter(l.ch) || isDigit(l.ch) { //letters too, so a digit that doesn't belong to the base (ie the 2 in 0b102) is reported instead of starting a new token
+			l.readChar()
+		}
+		return token.INT, l.input[position:l.position]
+	}
+
+	var tokenType token.TokenType = token.INT
+	l.readDigits()
+	if l.ch == '.' && isDigit(l.peekChar()) { //a fraction. A . followed by anything else (ie the first . of the .. in 1..10) isn't part of the number
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+	if l.ch == 'e' || l.ch == 'E' { //an exponent (ie 1e9 or 2.5e-3)
+		tokenType = token.FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		l.readDigits()
+	}
+
+	return tokenType, l.input[position:l.position] // return string of full number so that it can become the string literal for that number's token
 } //end readNumber
 
+//REQUIRES: a lexer structure l
+//MODIFIES: changes position and readPosition to the first char that is neither a digit nor a _
+//EFFECTS: reads a run of decimal digits, which can be separated by _ (ie 1_000_000)
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+}
+
 //REQUIRES: a char of the input to be examined
 //MODIFIES:
 //EFFECTS: returns a bool of whether or not the char is a letter
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 } //end isLetter
 
+//REQUIRES: a char of the input to be examined
+//MODIFIES:
+//EFFECTS: returns a bool of whether or not the char can be part of an identifier after its first char. The rule for identifiers is: a Unicode
+//letter or _ (ie a, Ö, λ or 変), followed by any number of Unicode letters, Unicode decimal digits (ie 7 or ٣) and _. So 'größe' and 'x2' are
+//identifiers, '2x' is the number 2 followed by the identifier x
+func isIdentifierChar(ch rune) bool {
+	return isLetter(ch) || unicode.IsDigit(ch)
+}
+
 //REQUIRES: a char of the input to be examined
 //MODIFIES:
 //EFFECTS: returns a bool of whether or not the char is an number
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 } //end isDigit
 
 //REQUIRES: a tokentype and character input
 //MODIFIES:
 //EFFECTS: creates token of inputted token type and string literal containing inputted char
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 } //end newToken
@@ -0,0 +1,560 @@
+package lexer
+
+import (
+	"testing"
+
+	"../token"
+)
+
+func TestTokenPositions(t *testing.T) {
+	input := `let int x := 5;
+set x :+ 10;
+  while (x > 1) {
+	x
+}`
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+		expectedOffset  int
+		expectedEnd     int //column just past the token
+	}{
+		{"let", 1, 1, 0, 4},
+		{"int", 1, 5, 4, 8},
+		{"x", 1, 9, 8, 10},
+		{":=", 1, 11, 10, 13},
+		{"5", 1, 14, 13, 15},
+		{";", 1, 15, 14, 16},
+		{"set", 2, 1, 16, 4},
+		{"x", 2, 5, 20, 6},
+		{":+", 2, 7, 22, 9},
+		{"10", 2, 10, 25, 12},
+		{";", 2, 12, 27, 13},
+		{"while", 3, 3, 31, 8},
+		{"(", 3, 9, 37, 10},
+		{"x", 3, 10, 38, 11},
+		{">", 3, 12, 40, 13},
+		{"1", 3, 14, 42, 15},
+		{")", 3, 15, 43, 16},
+		{"{", 3, 17, 45, 18},
+		{"x", 4, 2, 48, 3},
+		{"}", 5, 1, 50, 2},
+		{"", 5, 2, 51, 2},
+	}
+
+	l := NewFile("test.sq", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos.Filename != "test.sq" {
+			t.Errorf("tests[%d] - filename wrong. expected=%q, got=%q", i, "test.sq", tok.Pos.Filename)
+		}
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d", i, tt.expectedLine, tt.expectedColumn, tok.Pos.Line, tok.Pos.Column)
+		}
+		if tok.Pos.Offset != tt.expectedOffset {
+			t.Errorf("tests[%d] - offset wrong. expected=%d, got=%d", i, tt.expectedOffset, tok.Pos.Offset)
+		}
+		if tok.End.Line != tt.expectedLine || tok.End.Column != tt.expectedEnd {
+			t.Errorf("tests[%d] - end wrong. expected=%d:%d, got=%d:%d", i, tt.expectedLine, tt.expectedEnd, tok.End.Line, tok.End.Column)
+		}
+	}
+
+	if tok := l.NextToken(); tok.Type != token.EOF || tok.Pos.Offset != len(input) { //reading past the end keeps returning EOF in the same place
+		t.Errorf("expected EOF at offset %d again, got %s at %d", len(input), tok.Type, tok.Pos.Offset)
+	}
+}
+
+func TestPositionString(t *testing.T) {
+	tests := []struct {
+		pos      token.Position
+		expected string
+	}{
+		{token.Position{Filename: "main.sq", Line: 3, Column: 14}, "main.sq:3:14"},
+		{token.Position{Line: 3, Column: 14}, "3:14"},
+		{token.Position{Filename: "main.sq"}, "main.sq"},
+		{token.Position{}, "-"},
+	}
+
+	for _, tt := range tests {
+		if tt.pos.String() != tt.expected {
+			t.Errorf("position string wrong. expected=%q, got=%q", tt.expected, tt.pos.String())
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// a key is tested against "locks"
+let int x := 10 / 2; // trailing
+/* a block /* with a nested */ comment */ set x :/ 5;
+x /**/ + 1`
+
+	tests := []struct {
+		expectedType     token.TokenType
+		expectedLiteral  string
+		expectedComments []string
+	}{
+		{token.LET, "let", []string{`// a key is tested against "locks"`}},
+		{token.IDENT, "int", nil},
+		{token.IDENT, "x", nil},
+		{token.WALRUS, ":=", nil},
+		{token.INT, "10", nil},
+		{token.SLASH, "/", nil},
+		{token.INT, "2", nil},
+		{token.SEMICOLON, ";", nil},
+		{token.SET, "set", []string{"// trailing", "/* a block /* with a nested */ comment */"}},
+		{token.IDENT, "x", nil},
+		{token.SLASH_ASSIGN, ":/", nil},
+		{token.INT, "5", nil},
+		{token.SEMICOLON, ";", nil},
+		{token.IDENT, "x", nil},
+		{token.PLUS, "+", []string{"/**/"}},
+		{token.INT, "1", nil},
+		{token.EOF, "", nil},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if len(tok.Comments) != len(tt.expectedComments) {
+			t.Fatalf("tests[%d] - wrong number of comments. expected=%q, got=%v", i, tt.expectedComments, tok.Comments)
+		}
+		for j, c := range tt.expectedComments {
+			if tok.Comments[j].Text != c {
+				t.Errorf("tests[%d] - comment wrong. expected=%q, got=%q", i, c, tok.Comments[j].Text)
+			}
+		}
+	}
+
+	if len(l.Diagnostics()) != 0 {
+		t.Errorf("unexpected diagnostics: %v", l.Diagnostics())
+	}
+}
+
+func TestCommentPositions(t *testing.T) {
+	l := New("x // one\n  /* two\n */ y")
+
+	l.NextToken()
+	tok := l.NextToken()
+
+	if len(tok.Comments) != 2 {
+		t.Fatalf("expected 2 comments, got %d", len(tok.Comments))
+	}
+	if tok.Comments[0].Pos.String() != "1:3" || tok.Comments[0].End.String() != "1:9" {
+		t.Errorf("first comment at wrong position. got=%s-%s", tok.Comments[0].Pos, tok.Comments[0].End)
+	}
+	if tok.Comments[1].Pos.String() != "2:3" || tok.Comments[1].End.String() != "3:4" {
+		t.Errorf("second comment at wrong position. got=%s-%s", tok.Comments[1].Pos, tok.Comments[1].End)
+	}
+	if tok.Pos.String() != "3:5" {
+		t.Errorf("token after the comments at wrong position. got=%s", tok.Pos)
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	tests := []string{
+		"let int x := 1; /* never closed",
+		"let int x := 1; /* closed /* but not this one */",
+	}
+
+	for _, input := range tests {
+		l := New(input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errors := l.Diagnostics().Errors()
+		if len(errors) != 1 || errors[0] != "1:17: unterminated block comment" {
+			t.Errorf("wrong errors for %q. got=%q", input, errors)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	input := `let string s := "hi \"squid\"" + "${name}!";`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "string"},
+		{token.IDENT, "s"},
+		{token.WALRUS, ":="},
+		{token.STRING, `"hi \"squid\""`},
+		{token.PLUS, "+"},
+		{token.STRING, `"${name}!"`},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	if errors := l.Diagnostics().Errors(); len(errors) != 0 {
+		t.Errorf("unexpected errors: %q", errors)
+	}
+}
+
+func TestSplitString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []StringPart
+	}{
+		{`""`, nil},
+		{`"a\tb\n"`, []StringPart{{Text: "a\tb\n", Start: 1, End: 7}}},
+		{`"\\ \" \$ \u{1F991}"`, []StringPart{{Text: "\\ \" $ \U0001F991", Start: 1, End: 19}}},
+		{`"${x}"`, []StringPart{{Text: "x", Interpolated: true, Start: 3, End: 4}}},
+		{`"a ${f({})} b"`, []StringPart{
+			{Text: "a ", Start: 1, End: 3},
+			{Text: "f({})", Interpolated: true, Start: 5, End: 10},
+			{Text: " b", Start: 11, End: 13},
+		}},
+		{`"${"}"}"`, []StringPart{{Text: `"}"`, Interpolated: true, Start: 3, End: 6}}},
+	}
+
+	for _, tt := range tests {
+		parts := SplitString(tt.input)
+
+		if len(parts) != len(tt.expected) {
+			t.Errorf("wrong number of parts for %s. expected=%d, got=%d (%+v)", tt.input, len(tt.expected), len(parts), parts)
+			continue
+		}
+		for i, part := range parts {
+			if part != tt.expected[i] {
+				t.Errorf("parts[%d] of %s wrong. expected=%+v, got=%+v", i, tt.input, tt.expected[i], part)
+			}
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+	}{
+		{`let string s := "never closed;`, []string{"1:17: unterminated string"}},
+		{"\"line\nbreak\"", []string{"1:1: unterminated string", "2:6: unterminated string"}},
+		{`"\q"`, []string{`1:2: unknown escape sequence \q`}},
+		{`"\u1F991"`, []string{`1:2: \u must be followed by a code point in braces (ie \u{1F991})`}},
+		{`"\u{}"`, []string{`1:2: invalid unicode escape \u{, want 1 to 6 hex digits in braces`}},
+		{`"\u{D800}"`, []string{`1:2: \u{D800} is not a valid unicode code point`}},
+		{`"${x"`, []string{"1:2: unterminated interpolation in string", "1:5: unterminated string"}},
+		{`"a" "${"\q"}"`, []string{`1:9: unknown escape sequence \q`}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		diagnostics := l.Diagnostics()
+		diagnostics.Sort()
+		errors := diagnostics.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expectedErrors, errors)
+			continue
+		}
+		for i, err := range errors {
+			if err != tt.expectedErrors[i] {
+				t.Errorf("errors[%d] for %q wrong. expected=%q, got=%q", i, tt.input, tt.expectedErrors[i], err)
+			}
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := `3.14 0xFF 0b1010 1_000_000 6.02e23 1e-3 1..10 0b102`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FLOAT, "3.14"},
+		{token.INT, "0xFF"},
+		{token.INT, "0b1010"},
+		{token.INT, "1_000_000"},
+		{token.FLOAT, "6.02e23"},
+		{token.FLOAT, "1e-3"},
+		{token.INT, "1"}, //a . that isn't followed by a digit doesn't make a float
+		{token.DOTDOT, ".."},
+		{token.INT, "10"},
+		{token.INT, "0b102"}, //the parser reports the 2
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestComparisonAndLogicalOperators(t *testing.T) {
+	input := `a <= b >= c % d and e or f < =`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "d"},
+		{token.AND, "and"},
+		{token.IDENT, "e"},
+		{token.OR, "or"},
+		{token.IDENT, "f"},
+		{token.LT, "<"}, //only <= without a space between is one token
+		{token.EQ, "="},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestBrackets(t *testing.T) {
+	input := `let int[] xs := [1, 2]; xs[0..1]`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "int"},
+		{token.LBRACKET, "["},
+		{token.RBRACKET, "]"},
+		{token.IDENT, "xs"},
+		{token.WALRUS, ":="},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "xs"},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.DOTDOT, ".."},
+		{token.INT, "1"},
+		{token.RBRACKET, "]"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestHashLiteralTokens(t *testing.T) {
+	input := `let map[string]int m := {"a": 1, "b":/* note */ 2};`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.MAP, "map"},
+		{token.LBRACKET, "["},
+		{token.IDENT, "string"},
+		{token.RBRACKET, "]"},
+		{token.IDENT, "int"},
+		{token.IDENT, "m"},
+		{token.WALRUS, ":="},
+		{token.LBRACE, "{"},
+		{token.STRING, `"a"`},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.STRING, `"b"`},
+		{token.COLON, ":"}, //the / starts a comment, so it isn't :/
+		{token.INT, "2"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestStructTokens(t *testing.T) {
+	input := `struct Point { int x; int y } set p.x := 1..2;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRUCT, "struct"},
+		{token.IDENT, "Point"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "int"},
+		{token.IDENT, "x"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "int"},
+		{token.IDENT, "y"},
+		{token.RBRACE, "}"},
+		{token.SET, "set"},
+		{token.IDENT, "p"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.WALRUS, ":="},
+		{token.INT, "1"},
+		{token.DOTDOT, ".."},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestEnumTokens(t *testing.T) {
+	input := `enum Month { Jan, Feb } Month.Feb`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.ENUM, "enum"},
+		{token.IDENT, "Month"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "Jan"},
+		{token.COMMA, ","},
+		{token.IDENT, "Feb"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "Month"},
+		{token.DOT, "."},
+		{token.IDENT, "Feb"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := "let int größe := 2; λ x2 _1 変数 2x \"🦑\" ≠"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1},
+		{token.IDENT, "int", 5},
+		{token.IDENT, "größe", 9},
+		{token.WALRUS, ":=", 15}, //größe is 5 characters long, even though it is 7 bytes
+		{token.INT, "2", 18},
+		{token.SEMICOLON, ";", 19},
+		{token.IDENT, "λ", 21},
+		{token.IDENT, "x2", 23},
+		{token.IDENT, "_1", 26},
+		{token.IDENT, "変数", 29},
+		{token.INT, "2", 32}, //identifiers can't start with a digit
+		{token.IDENT, "x", 33},
+		{token.STRING, `"🦑"`, 35},
+		{token.ILLEGAL, "≠", 39},
+		{token.EOF, "", 40},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - column wrong. expected=%d, got=%d", i, tt.expectedColumn, tok.Pos.Column)
+		}
+	}
+
+	if errors := l.Diagnostics().Errors(); len(errors) != 0 {
+		t.Errorf("unexpected errors: %q", errors)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	input := "let int a\xffb := 1; \"caf\xe9\" // \xc0 in a comment"
+
+	l := New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+
+	expected := []string{
+		"1:10: invalid UTF-8 encoding (byte 0xff)",
+		"1:23: invalid UTF-8 encoding (byte 0xe9)",
+		"1:29: invalid UTF-8 encoding (byte 0xc0)",
+	}
+
+	errors := l.Diagnostics().Errors()
+	if len(errors) != len(expected) {
+		t.Fatalf("wrong errors. expected=%q, got=%q", expected, errors)
+	}
+	for i, err := range errors {
+		if err != expected[i] {
+			t.Errorf("errors[%d] wrong. expected=%q, got=%q", i, expected[i], err)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package lexer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"../diagnostic"
+)
+
+//the characters that can follow a \ in a string and the character each escape stands for (\u{...} is handled on its own)
+var escapes = map[byte]rune{
+	'n':  '\n',
+	't':  '\t',
+	'"':  '"',
+	'\\': '\\',
+	'$':  '$', //so a string can contain a literal ${ (ie "\${not interpolated}")
+}
+
+//StringPart is a piece of a string literal: either plain text or the source of an interpolated ${expression}
+type StringPart struct {
+	Text         string //the text with its escapes decoded, or the source of the expression between ${ and }
+	Interpolated bool   //whether the part is an expression
+	Start        int    //where the part starts in the literal, in bytes
+	End          int    //where the part ends in the literal, in bytes
+}
+
+//a problem found while scanning a string literal, positioned by its offsets in the literal
+type stringProblem struct {
+	code    diagnostic.Code
+	message string
+	start   int
+	end     int
+	notes   []string
+}
+
+//REQUIRES: a lexer structure l whose current char is the opening " of a string
+//MODIFIES: changes position and readPosition to just past the closing " (or to the end of the line if the string is never closed), and adds
+//a diagnostic for every problem found in the string
+//EFFECTS: returns the source of the string, quotes included
+func (l *Lexer) readString() string {
+	start := l.currentPosition()
+	length, _, problems := scanString(l.input[l.position:])
+
+	literal := l.input[l.position : l.position+length]
+	for _, problem := range problems { //strings never span lines, so every problem is on the line the string starts on
+		l.diagnostics.Add(diagnostic.Diagnostic{
+			Severity: diagnostic.Error,
+			Code:     problem.code,
+			Message:  problem.message,
+			Span:     diagnostic.Span{Start: start.Advance(literal[:problem.start]), End: start.Advance(literal[:problem.end])},
+			Notes:    problem.notes,
+		})
+	}
+
+	for end := l.position + length; l.position < end; { //length is in bytes, and a char can be several of them
+		l.readChar()
+	}
+	return literal
+}
+
+//REQUIRES: the literal of a token.STRING token
+//MODIFIES:
+//EFFECTS: splits the string into its text and its interpolated expressions, in order. Text between two interpolations that is empty is left out,
+//so '""' has no parts at all. Problems with the string are ignored since the lexer already reported them
+func SplitString(literal string) []StringPart {
+	_, parts, _ := scanString(literal)
+	return parts
+}
+
+//REQUIRES: source that starts with the opening " of a string
+//MODIFIES:
+//EFFECTS: returns how many bytes long the string at the start of s is (up to the end of the line if it is never closed), the parts it is made of,
+//and anything wrong with it
+func scanString(s string) (int, []StringPart, []stringProblem) {
+	var parts []StringPart
+	var problems []stringProblem
+
+	var text strings.Builder
+	textStart := 1
+	flush := func(end int) { //ends the text part that has been building up, if there is one
+		if end > textStart {
+			parts = append(parts, StringPart{Text: text.String(), Start: textStart, End: end})
+		}
+		text.Reset()
+	}
+
+	i := 1 //step past the opening "
+	for i < len(s) && s[i] != '"' && s[i] != '\n' {
+		switch {
+		case s[i] == '\\':
+			r, size, message := decodeEscape(s[i:])
+			if message != "" {
+				problems = append(problems, stringProblem{code: diagnostic.InvalidEscape, message: message, start: i, end: i + size,
+					notes: []string{`the escapes are \n, \t, \", \\, \$ and \u{...}`}})
+			} else {
+				text.WriteRune(r)
+			}
+			i += size
+		case strings.HasPrefix(s[i:], "${"):
+			flush(i)
+			exprStart := i + 2
+			length, nested := scanInterpolation(s[exprStart:])
+			problems = append(problems, shiftProblems(nested, exprStart)...)
+			if length < 0 { //there is no } to end the expression on this line
+				problems = append(problems, stringProblem{code: diagnostic.UnterminatedString, message: "unterminated interpolation in string", start: i, end: exprStart,
+					notes: []string{"every ${ needs a } on the same line"}})
+				return exprStart + strings.IndexByte(s[exprStart:]+"\n", '\n'), parts, problems
+			}
+			parts = append(parts, StringPart{Text: s[exprStart : exprStart+length], Interpolated: true, Start: exprStart, End: exprStart + length})
+			i = exprStart + length + 1 //step past the }
+			textStart = i
+		default:
+			text.WriteByte(s[i])
+			i++
+		}
+	}
+	flush(i)
+
+	if i == len(s) || s[i] == '\n' { //we ran out of line before the string was closed
+		problems = append(problems, stringProblem{code: diagnostic.UnterminatedString, message: "unterminated string", start: 0, end: 1,
+			notes: []string{`strings have to end with a " on the same line they start on (use \n for a newline)`}})
+		return i, parts, problems
+	}
+	return i + 1, parts, problems //step past the closing "
+}
+
+//REQUIRES: source that starts just after the ${ of an interpolation
+//MODIFIES:
+//EFFECTS: returns how many bytes long the expression is (not counting the } that ends it, -1 if nothing ends it on this line) and anything wrong
+//with the strings inside of it. Braces nest, so '${f({...})}' is a single interpolation, and so do strings (ie "${"${x}"}")
+func scanInterpolation(s string) (int, []stringProblem) {
+	var problems []stringProblem
+	depth := 1
+
+	for i := 0; i < len(s) && s[i] != '\n'; {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i, problems
+			}
+		case '"': //a string inside of the expression, which may have braces of its own
+			length, _, nested := scanString(s[i:])
+			problems = append(problems, shiftProblems(nested, i)...)
+			i += length
+			continue
+		}
+		i++
+	}
+
+	return -1, problems
+}
+
+//moves problems found in a piece of a string literal to where that piece starts in the literal
+func shiftProblems(problems []stringProblem, offset int) []stringProblem {
+	for i := range problems {
+		problems[i].start += offset
+		problems[i].end += offset
+	}
+	return problems
+}
+
+//REQUIRES: a string that starts with a \
+//MODIFIES:
+//EFFECTS: returns the character the escape at the start of s stands for and how many bytes long the escape is. If it isn't a valid escape, the
+//message says what is wrong with it and the size covers as much of it as was read (never the end of the line, so the string still ends there)
+func decodeEscape(s string) (rune, int, string) {
+	if len(s) < 2 || s[1] == '\n' {
+		return 0, 1, `\ at the end of a line`
+	}
+	if r, ok := escapes[s[1]]; ok {
+		return r, 2, ""
+	}
+	if s[1] != 'u' {
+		_, size := utf8.DecodeRuneInString(s[1:])
+		return 0, 1 + size, fmt.Sprintf("unknown escape sequence %s", s[:1+size])
+	}
+
+	if len(s) < 3 || s[2] != '{' { //\u{...}
+		return 0, 2, `\u must be followed by a code point in braces (ie \u{1F991})`
+	}
+	end := 3
+	for end < len(s) && isHexDigit(s[end]) {
+		end++
+	}
+	if end == 3 || end >= len(s) || s[end] != '}' || end-3 > 6 {
+		return 0, end, fmt.Sprintf("invalid unicode escape %s, want 1 to 6 hex digits in braces", s[:end])
+	}
+
+	value, _ := strconv.ParseUint(s[3:end], 16, 32)
+	if value > unicode.MaxRune || 0xD800 <= value && value <= 0xDFFF { //past the last code point, or a surrogate half
+		return 0, end + 1, fmt.Sprintf("%s is not a valid unicode code point", s[:end+1])
+	}
+	return rune(value), end + 1, ""
+}
+
+//REQUIRES: a char of the input to be examined
+//MODIFIES:
+//EFFECTS: returns a bool of whether or not the char is a hexadecimal digit
+func isHexDigit(ch byte) bool {
+	return isDigit(rune(ch)) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
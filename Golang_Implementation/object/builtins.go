@@ -0,0 +1,127 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+var Output io.Writer = os.Stdout //where print writes to (the REPL points it at its own output)
+var Args []string                //what args hands back, the arguments given to the script after -- on the command line
+
+//the functions every program can call without declaring them. They live here rather than in the evaluator so the vm can call the same ones.
+//The order matters, the compiler refers to a builtin by where it is in this list
+var Builtins = []*Builtin{
+	{Name: "print", Fn: builtinPrint},
+	{Name: "len", Fn: builtinLen},
+	{Name: "int", Fn: builtinInt},
+	{Name: "float", Fn: builtinFloat},
+	{Name: "args", Fn: builtinArgs},
+}
+
+//REQUIRES: the name of a builtin
+//MODIFIES:
+//EFFECTS: returns the builtin with that name, or nil if there isn't one
+func GetBuiltinByName(name string) *Builtin {
+	for _, b := range Builtins {
+		if b.Name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+//REQUIRES: any number of values
+//MODIFIES: Output
+//EFFECTS: writes the values on one line, separated by spaces. Strings are written without quotes. Returns nil, since there is nothing to hand back
+//(the evaluator and the vm turn that into their null)
+func builtinPrint(args ...Object) Object {
+	values := make([]string, len(args))
+	for i, arg := range args {
+		values[i] = arg.Inspect()
+	}
+	io.WriteString(Output, strings.Join(values, " ")+"\n")
+
+	return nil
+}
+
+//REQUIRES: a single value
+//MODIFIES:
+//EFFECTS: returns how many characters are in a string, how many elements are in an array or how many keys are in a map
+func builtinLen(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments to len: want=1, got=%d", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *String:
+		return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))} //characters, not bytes, so "é" is 1 long
+	case *Array:
+		return &Integer{Value: int64(len(arg.Elements))}
+	case *Map:
+		return &Integer{Value: int64(len(arg.Keys))}
+	default:
+		return newError("argument to len not supported, got %s", arg.Type())
+	}
+}
+
+//REQUIRES: a single int or float
+//MODIFIES:
+//EFFECTS: converts the value to an int. The fraction of a float is dropped (ie int(-2.7) is -2)
+func builtinInt(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments to int: want=1, got=%d", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *Integer:
+		return arg
+	case *Float:
+		if math.IsNaN(arg.Value) || arg.Value >= math.MaxInt64 || arg.Value < math.MinInt64 { //Go would hand back a meaningless int for these
+			return newError("cannot convert %s to int, it is out of range", arg.Inspect())
+		}
+		return &Integer{Value: int64(arg.Value)}
+	default:
+		return newError("argument to int not supported, got %s", arg.Type())
+	}
+}
+
+//REQUIRES: a single int or float
+//MODIFIES:
+//EFFECTS: converts the value to a float
+func builtinFloat(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments to float: want=1, got=%d", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *Integer:
+		return &Float{Value: float64(arg.Value)}
+	case *Float:
+		return arg
+	default:
+		return newError("argument to float not supported, got %s", arg.Type())
+	}
+}
+
+//REQUIRES: no arguments
+//MODIFIES:
+//EFFECTS: returns the arguments the script was run with as an array of strings (empty if there weren't any)
+func builtinArgs(args ...Object) Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments to args: want=0, got=%d", len(args))
+	}
+
+	elements := make([]Object, len(Args))
+	for i, arg := range Args {
+		elements[i] = &String{Value: arg}
+	}
+	return &Array{Elements: elements}
+}
+
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,57 @@
+package object
+
+//The environment keeps track of the values bound to names (ie the 5 bound to x after 'let int x := 5;')
+type Environment struct {
+	store map[string]Object //names and the values they are bound to
+	outer *Environment      //the enclosing environment (nil for the outermost/global environment)
+}
+
+//REQUIRES:
+//MODIFIES:
+//EFFECTS: creates an empty outermost environment
+func NewEnvironment() *Environment {
+	s := make(map[string]Object)
+	return &Environment{store: s, outer: nil}
+}
+
+//REQUIRES: the environment that will enclose the new one
+//MODIFIES:
+//EFFECTS: creates an empty environment whose lookups fall back to outer when a name isn't found
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+//REQUIRES: a name to look up
+//MODIFIES:
+//EFFECTS: returns the value bound to name, checking enclosing environments if it isn't bound here, and whether it was found at all
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil { //not bound here, so we try the enclosing environment
+		obj, ok = e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+//REQUIRES: a name and the value to bind to it
+//MODIFIES: the store of this environment
+//EFFECTS: binds val to name in this environment and returns val
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
+
+//REQUIRES: the name of an already bound variable and its new value
+//MODIFIES: the store of whichever environment name is bound in
+//EFFECTS: rebinds name to val in the environment it was declared in and returns whether name was bound at all
+func (e *Environment) Assign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok { //declared here, so this is the binding we update
+		e.store[name] = val
+		return true
+	}
+	if e.outer != nil { //maybe it was declared in an enclosing environment
+		return e.outer.Assign(name, val)
+	}
+	return false
+}
@@ -0,0 +1,408 @@
+//OVERVIEW: Objects are the values the evaluator produces. Every value that exists while a SquidScript program runs is represented by something that fulfills the Object interface
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"../ast"
+	"../code"
+)
+
+type ObjectType string
+
+const ( //these are our object types
+	INTEGER_OBJ  = "INTEGER"
+	FLOAT_OBJ    = "FLOAT"
+	BOOLEAN_OBJ  = "BOOLEAN"
+	STRING_OBJ   = "STRING"
+	NULL_OBJ     = "NULL"
+	RANGE_OBJ    = "RANGE"
+	ARRAY_OBJ    = "ARRAY"
+	MAP_OBJ      = "MAP"
+	STRUCT_OBJ   = "STRUCT"
+	ENUM_OBJ     = "ENUM"
+	FUNCTION_OBJ = "FUNCTION"
+	BUILTIN_OBJ  = "BUILTIN"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION" // a function the compiler turned into bytecode
+	CLOSURE_OBJ           = "CLOSURE"           // what a compiled function is when the vm runs it
+
+	STRUCT_TYPE_OBJ = "STRUCT_TYPE" // what the name of a struct declaration is bound to, so literals know which fields to expect
+	ENUM_TYPE_OBJ   = "ENUM_TYPE"   // what the name of an enum declaration is bound to, so its variants can be looked up (ie Month.Feb)
+
+	RETURN_VALUE_OBJ = "RETURN_VALUE" // wraps the value of a return statement so it can bubble up through blocks
+	ERROR_OBJ        = "ERROR"        // runtime errors (ie type mismatches or unknown identifiers)
+	BREAK_OBJ        = "BREAK"        // signals that the innermost loop should end
+	CONTINUE_OBJ     = "CONTINUE"     // signals that the innermost loop should skip to its next iteration
+)
+
+const MaxCallDepth = 10000 //how deep calls can go before the program stops with a stack overflow (rather than crashing the interpreter)
+
+// The base Object interface
+type Object interface {
+	Type() ObjectType //which kind of object is this
+	Inspect() string  //string representation of the value (what the REPL prints)
+}
+
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") { //whole floats keep a .0 so they can't be mistaken for ints (the I and N are for Inf and NaN)
+		s += ".0"
+	}
+	return s
+}
+
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return s.Value }
+
+type Range struct { //every integer from Start up to and including End
+	Start int64
+	End   int64
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string  { return fmt.Sprintf("%d..%d", r.Start, r.End) }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type HashKey struct { //what a key is stored under in a map. Two keys that are equal have equal HashKeys, even when they are different objects
+	Type  ObjectType
+	Value uint64 //the value of INTEGER and BOOLEAN keys, and the index of ENUM keys
+	Text  string //the value of STRING keys, and the name of the enum of ENUM keys
+}
+
+type Hashable interface { //the objects that can be used as map keys
+	Object
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey { return HashKey{Type: i.Type(), Value: uint64(i.Value)} }
+func (s *String) HashKey() HashKey  { return HashKey{Type: s.Type(), Text: s.Value} }
+func (b *Boolean) HashKey() HashKey {
+	if b.Value {
+		return HashKey{Type: b.Type(), Value: 1}
+	}
+	return HashKey{Type: b.Type(), Value: 0}
+}
+
+type HashPair struct { //a key of a map and the value stored under it
+	Key   Object
+	Value Object
+}
+
+type Map struct {
+	Pairs map[HashKey]HashPair
+	Keys  []HashKey //every key in the order it was first set, so printing and looping over a map always go in the same order
+}
+
+//REQUIRES:
+//MODIFIES:
+//EFFECTS: returns a map with nothing in it
+func NewMap() *Map {
+	return &Map{Pairs: make(map[HashKey]HashPair)}
+}
+
+func (m *Map) Type() ObjectType { return MAP_OBJ }
+func (m *Map) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, k := range m.Keys {
+		pair := m.Pairs[k]
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+//REQUIRES: a key
+//MODIFIES:
+//EFFECTS: returns the value stored under key, and whether there is one
+func (m *Map) Get(key Hashable) (Object, bool) {
+	pair, ok := m.Pairs[key.HashKey()]
+	return pair.Value, ok
+}
+
+//REQUIRES: a key and a value
+//MODIFIES: the map
+//EFFECTS: stores value under key, replacing whatever was stored under it before
+func (m *Map) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+	if _, ok := m.Pairs[hashKey]; !ok {
+		m.Keys = append(m.Keys, hashKey)
+	}
+	m.Pairs[hashKey] = HashPair{Key: key, Value: value}
+}
+
+type StructType struct { //a declared struct (ie struct Point { int x; int y })
+	Name   string
+	Fields []StructField //in the order they were declared
+}
+
+type StructField struct {
+	Name string
+	Type string //the type as it was written (ie int[]), only used for printing
+}
+
+//REQUIRES: the name of the struct and its fields as they were parsed
+//MODIFIES:
+//EFFECTS: returns the struct type. It only keeps the names and the written types of the fields, not the AST, so it can be written to a file
+func NewStructType(name string, fields []*ast.Field) *StructType {
+	st := &StructType{Name: name}
+	for _, f := range fields {
+		st.Fields = append(st.Fields, StructField{Name: f.Name.Value, Type: f.Type.String()})
+	}
+	return st
+}
+
+func (st *StructType) Type() ObjectType { return STRUCT_TYPE_OBJ }
+func (st *StructType) Inspect() string {
+	fields := []string{}
+	for _, f := range st.Fields {
+		fields = append(fields, f.Type+" "+f.Name)
+	}
+	return "struct " + st.Name + " {" + strings.Join(fields, "; ") + "}"
+}
+
+//REQUIRES: the name of a field
+//MODIFIES:
+//EFFECTS: returns whether the struct has a field with that name
+func (st *StructType) HasField(name string) bool {
+	for _, f := range st.Fields {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+type Struct struct { //a value of a struct type. Like arrays and maps, a struct is shared between every variable it is stored in
+	StructType *StructType
+	Fields     map[string]Object
+}
+
+func (s *Struct) Type() ObjectType { return STRUCT_OBJ }
+func (s *Struct) Inspect() string {
+	var out bytes.Buffer
+
+	fields := []string{}
+	for _, f := range s.StructType.Fields { //in the order they were declared
+		fields = append(fields, f.Name+": "+s.Fields[f.Name].Inspect())
+	}
+
+	out.WriteString(s.StructType.Name)
+	out.WriteString("{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type EnumType struct { //a declared enum (ie enum Month { Jan, Feb })
+	Name     string
+	Variants []*Enum //there is only ever one of each variant, so they can be compared with ==
+}
+
+//REQUIRES: the name of the enum and the names of its variants
+//MODIFIES:
+//EFFECTS: returns the enum type with a value for each of the variants
+func NewEnumType(name string, variants []string) *EnumType {
+	et := &EnumType{Name: name}
+	for i, v := range variants {
+		et.Variants = append(et.Variants, &Enum{EnumType: et, Name: v, Index: i})
+	}
+	return et
+}
+
+func (et *EnumType) Type() ObjectType { return ENUM_TYPE_OBJ }
+func (et *EnumType) Inspect() string {
+	variants := []string{}
+	for _, v := range et.Variants {
+		variants = append(variants, v.Name)
+	}
+	return "enum " + et.Name + " {" + strings.Join(variants, ", ") + "}"
+}
+
+//REQUIRES: the name of a variant
+//MODIFIES:
+//EFFECTS: returns the variant of the enum with that name, and whether there is one
+func (et *EnumType) Variant(name string) (*Enum, bool) {
+	for _, v := range et.Variants {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+type Enum struct { //a variant of an enum (ie Month.Feb)
+	EnumType *EnumType
+	Name     string
+	Index    int //where the variant is in the declaration, starting at 0
+}
+
+func (e *Enum) Type() ObjectType { return ENUM_OBJ }
+func (e *Enum) Inspect() string  { return e.EnumType.Name + "." + e.Name }
+func (e *Enum) HashKey() HashKey {
+	return HashKey{Type: e.Type(), Value: uint64(e.Index), Text: e.EnumType.Name}
+}
+
+type Function struct { //a function value. It remembers the environment it was created in, which is what makes closures work
+	Name       string //empty for anonymous functions
+	Parameters []*ast.Parameter
+	ReturnType ast.TypeNode //nil when the function doesn't return anything
+	Body       *ast.BlockStatement
+	Env        *Environment //the environment the function was defined in
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("func")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	if f.ReturnType != nil {
+		out.WriteString(" returns " + f.ReturnType.String())
+	}
+	out.WriteString(" {\n")
+	out.WriteString(f.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+type CompiledFunction struct { //a function literal compiled to bytecode. It lives in the constant pool, and the vm wraps it in a Closure to call it
+	Name          string //empty for anonymous functions
+	Instructions  code.Instructions
+	NumLocals     int            //how many local variables (parameters included) the function needs room for
+	NumParameters int            //the parameters are the first locals
+	Upvalues      []UpvalueRef   //the variables of enclosing functions it uses, which a closure over it captures
+	Lines         code.LineTable //which lines of the source the instructions were compiled from
+}
+
+type UpvalueRef struct { //where a closure finds a variable it captures when it is created
+	IsLocal bool //true if it is a local of the function creating the closure, false if that function captured it itself
+	Index   int  //the index of that local or upvalue
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string  { return "compiled " + functionName(cf.Name) }
+
+type Closure struct { //a compiled function together with the variables it captured
+	Fn       *CompiledFunction
+	Upvalues []*Upvalue
+}
+
+func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
+func (c *Closure) Inspect() string  { return functionName(c.Fn.Name) }
+
+type Upvalue struct { //a variable captured by a closure. While the variable is in scope Location points at its slot on the stack of the vm,
+	//afterwards the value is moved into Closed and Location points there instead, so every closure that captured it still shares it
+	Location *Object
+	Closed   Object
+}
+
+func functionName(name string) string {
+	if name == "" {
+		return "anonymous function"
+	}
+	return "func " + name
+}
+
+type BuiltinFunction func(args ...Object) Object
+
+type Builtin struct { //a function that comes with the language (ie print), written in Go instead of SquidScript
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin function " + b.Name }
+
+type Null struct{} // the absence of a value (ie an if expression whose condition was false and had no else)
+
+func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Inspect() string  { return "null" }
+
+type ReturnValue struct {
+	Value Object //the value being returned
+}
+
+func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+
+type Break struct{} // bubbles up through blocks until it reaches the loop it breaks out of
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{} // bubbles up through blocks until it reaches the loop it continues
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
+type Error struct {
+	Message string //what went wrong
+	Line    int    //the line of the statement it went wrong in (0 until the evaluator has seen which one that was)
+}
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
@@ -3,20 +3,27 @@ package parser
 import (
 	"fmt"
 	"strconv" //For when we need to obtain the actual int value of numbers inputted in source code
+	"strings"
 
 	"../ast"
+	"../diagnostic"
 	"../lexer"
 	"../token"
 )
 
 const ( //these constants are precedence labels that make the hierarchy of expression parsing order
-	_           int = iota // we use iota to give the following constants incrementing numbers (1 - 7) as values. The order of these values decides the order in which expression get parse
+	_           int = iota // we use iota to give the following constants incrementing numbers (1 - 11) as values. The order of these values decides the order in which expression get parse
 	LOWEST                 //							VALUE 1, LOWEST PRECEDENCE
-	EQUALS                 // ==						VALUE 2
-	LESSGREATER            // > or <					VALUE 3
-	SUM                    // +							VALUE 4
-	PRODUCT                // *							VALUE 5
-	PREFIX                 // -X or !X					VALUE 6, HIGHEST PRECEDENCE
+	OR                     // or						VALUE 2
+	AND                    // and						VALUE 3
+	EQUALS                 // ==						VALUE 4
+	LESSGREATER            // > or <					VALUE 5
+	RANGE                  // ..						VALUE 6
+	SUM                    // +							VALUE 7
+	PRODUCT                // * or %					VALUE 8
+	PREFIX                 // -X or !X					VALUE 9
+	CALL                   // myFunction(X)				VALUE 10
+	INDEX                  // array[index] or point.x		VALUE 11, HIGHEST PRECEDENCE
 )
 
 //in what order do we want to parse expressions so the AST is correct (Omit?)
@@ -25,12 +32,21 @@ const ( //these constants are precedence labels that make the hierarchy of expre
 var precedences = map[token.TokenType]int{ //Depending on token type provided, the appropriate precedence *number* is provided (actual numeric value is based upon consts defined above)
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
+	token.OR:       OR,
+	token.AND:      AND,
 	token.LT:       LESSGREATER,
 	token.GT:       LESSGREATER,
+	token.LT_EQ:    LESSGREATER,
+	token.GT_EQ:    LESSGREATER,
+	token.DOTDOT:   RANGE,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.PERCENT:  PRODUCT,
+	token.LPAREN:   CALL,
+	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 } //table can tell us that + (token.PLUS) and - (token.MINUS) have the same precedence, but are lower than the precedence of * (token.ASTERISK) and / (token.SLASH), for example
 
 // Whenever a token type is encountered, the parsing functions are called to parse the appropriate expression and return an AST node that represents it
@@ -43,12 +59,20 @@ type (
 
 //This is what is constructed; the main template/structure of the parser
 type Parser struct {
-	l      *lexer.Lexer //pointer to an instance of the lexer, on which we repeatedly call NextToken() to get the next token in the input
-	errors []string     //Contains errors we have seen (messages)
+	l           *lexer.Lexer    //pointer to an instance of the lexer, on which we repeatedly call NextToken() to get the next token in the input
+	diagnostics diagnostic.List //Contains errors we have seen
 
 	curToken  token.Token //Current token
 	peekToken token.Token //Next token
 
+	loopDepth int //how many loop bodies we are currently inside of (break and continue are only allowed when this isn't 0)
+
+	noStructLiteral bool //set while parsing an expression that a block follows (ie the condition of a while loop), where 'x {' is x and then the block
+
+	braceDepth int  //how many { are still open up to and including curToken, so error recovery knows which block it is in
+	groupDepth int  //the same for ( and [, so error recovery knows whether a new line can start a new statement
+	panicMode  bool //set when an error is found, until we skip ahead to the start of the next statement (errors in between are dropped)
+
 	//In order for our parser to get the correct prefixParseFn or infixParseFn for the current token type, we add two maps to the Parser structure
 	//With these maps in place,we can just check if the appropriate map(infix or prefix)has a parsing function associated with curToken.Type
 	prefixParseFns map[token.TokenType]prefixParseFn
@@ -57,19 +81,24 @@ type Parser struct {
 
 func New(l *lexer.Lexer) *Parser { //serves as a parser constructor
 	p := &Parser{ //see 'type Parser struct {'
-		l:      l,
-		errors: []string{},
+		l:           l,
+		diagnostics: diagnostic.List{},
 	}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn) // Prefix Parse functions. Parses based on token type seen in prefix position
 	p.registerPrefix(token.IDENT, p.parseIdentifier)           // indentifier
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)         // int
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)         // float
+	p.registerPrefix(token.STRING, p.parseStringLiteral)       // string
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)      // not operator
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)     // negative sign
 	p.registerPrefix(token.TRUE, p.parseBoolean)               // true bool
 	p.registerPrefix(token.FALSE, p.parseBoolean)              // false bool
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)   // open parantheses (
 	p.registerPrefix(token.IF, p.parseIfExpression)            // if
+	p.registerPrefix(token.FUNC, p.parseFunctionLiteral)       // anonymous function
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)      // array literal [
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)         // hash literal {
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn) // Infix Parse functions. Parses based on token type seen in infix position
 	//Every infix operator gets associated with the same parsing function called parseInfixExpression
@@ -77,10 +106,19 @@ func New(l *lexer.Lexer) *Parser { //serves as a parser constructor
 	p.registerInfix(token.MINUS, p.parseInfixExpression)    // -
 	p.registerInfix(token.SLASH, p.parseInfixExpression)    // divide "/"
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression) // multiply "*"
-	p.registerInfix(token.EQ, p.parseInfixExpression)       // == in m, = in ss
+	p.registerInfix(token.EQ, p.parseInfixExpression)       // =
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)   // !=
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)  // remainder "%"
 	p.registerInfix(token.LT, p.parseInfixExpression)       // <
 	p.registerInfix(token.GT, p.parseInfixExpression)       // >
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)    // <=
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)    // >=
+	p.registerInfix(token.AND, p.parseInfixExpression)      // and
+	p.registerInfix(token.OR, p.parseInfixExpression)       // or
+	p.registerInfix(token.DOTDOT, p.parseRangeExpression)   // ..
+	p.registerInfix(token.LPAREN, p.parseCallExpression)    // function call (
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression) // array[index] or array[first..last]
+	p.registerInfix(token.DOT, p.parseSelectorExpression)   // struct.field
 
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
@@ -92,6 +130,17 @@ func New(l *lexer.Lexer) *Parser { //serves as a parser constructor
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken      //current token becomes next
 	p.peekToken = p.l.NextToken() //peek token becomes the one after that
+
+	switch {
+	case p.curTokenIs(token.LBRACE):
+		p.braceDepth++
+	case p.curTokenIs(token.RBRACE) && p.braceDepth > 0: //a stray } doesn't close anything
+		p.braceDepth--
+	case p.curTokenIs(token.LPAREN) || p.curTokenIs(token.LBRACKET):
+		p.groupDepth++
+	case (p.curTokenIs(token.RPAREN) || p.curTokenIs(token.RBRACKET)) && p.groupDepth > 0:
+		p.groupDepth--
+	}
 }
 
 func (p *Parser) curTokenIs(t token.TokenType) bool { //Is the CURRENT token type what we expect it to be
@@ -114,20 +163,163 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
+//the update operators the lexer makes out of a : and the character after it, and what that character is on its own
+var colonOperators = map[token.TokenType]token.TokenType{
+	token.PLUS_ASSIGN:     token.PLUS,
+	token.MINUS_ASSIGN:    token.MINUS,
+	token.ASTERISK_ASSIGN: token.ASTERISK,
+	token.SLASH_ASSIGN:    token.SLASH,
+}
+
+//REQUIRES:
+//MODIFIES: curToken and peekToken
+//EFFECTS: like expectPeek(token.COLON), but an update operator is split back into its : and the operator after it, since the lexer can't tell
+//'{"a":-1}' or 'Point{x:-1}' from 'set x :- 1'
+func (p *Parser) expectColon() bool {
+	operator, ok := colonOperators[p.peekToken.Type]
+	if !ok {
+		return p.expectPeek(token.COLON)
+	}
+
+	compound := p.peekToken
+	afterColon := compound.Pos.Advance(":")
+	p.curToken = token.Token{Type: token.COLON, Literal: ":", Pos: compound.Pos, End: afterColon, Comments: compound.Comments}
+	p.peekToken = token.Token{Type: operator, Literal: compound.Literal[1:], Pos: afterColon, End: compound.End}
+	return true
+}
+
 func (p *Parser) Errors() []string { //we can check if the parser encountered any errors. (USED PRIMARILY FOR TESTING)
-	return p.errors //returns list of errors
+	return p.Diagnostics().Errors() //returns list of errors as 'line:column: message'
+}
+
+func (p *Parser) Diagnostics() diagnostic.List { //every problem we (and the lexer) found, in source order, with enough information to point at the source
+	lexed := p.l.Diagnostics()
+	all := append(diagnostic.List{}, lexed...)
+	for _, d := range p.diagnostics {
+		if !startsAtAny(d, lexed) { //the lexer already explained what is wrong there (ie the invalid UTF-8 behind an ILLEGAL token)
+			all = append(all, d)
+		}
+	}
+	all.Sort()
+	return all
+}
+
+//returns whether d starts where one of the diagnostics in l does
+func startsAtAny(d diagnostic.Diagnostic, l diagnostic.List) bool {
+	for _, other := range l {
+		if other.Span.Start == d.Span.Start {
+			return true
+		}
+	}
+	return false
 }
 
 func (p *Parser) peekError(t token.TokenType) {
 	//used to add an error to errors field of parser struct when the type of peekToken doesn’t match the expectation
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
+	p.errorAt(diagnostic.UnexpectedToken, diagnostic.TokenSpan(p.peekToken), "expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg) //add error message to errors field
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) { //just adds a formatted error message to our parser’s errors field when something is misused as a prefix parse function
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.errorAt(diagnostic.ExpectedExpression, diagnostic.TokenSpan(p.curToken), "no prefix parse function for %s found", t)
+}
+
+//REQUIRES: a format string with its arguments (like fmt.Sprintf), where the peek token should have been an assignment operator
+//MODIFIES: the diagnostics field of the parser
+//EFFECTS: reports an error at the peek token. If it is a = the error suggests := instead, since that is almost always someone used to assigning with =
+func (p *Parser) assignOperatorError(format string, a ...interface{}) {
+	d := diagnostic.Diagnostic{Severity: diagnostic.Error, Code: diagnostic.UnexpectedToken, Message: fmt.Sprintf(format, a...), Span: diagnostic.TokenSpan(p.peekToken)}
+	if p.peekToken.Type == token.EQ && p.peekToken.Literal == "=" { //a == already has its own error from the lexer
+		d.Suggestions = []diagnostic.Suggestion{{Message: "replace = with :=", Span: d.Span, Replacement: ":="}}
+	}
+	p.report(d)
+}
+
+//REQUIRES: whether struct literals should be turned off
+//MODIFIES: noStructLiteral
+//EFFECTS: sets noStructLiteral and returns a function that puts it back the way it was (ie 'defer p.setNoStructLiteral(false)()')
+func (p *Parser) setNoStructLiteral(off bool) func() {
+	old := p.noStructLiteral
+	p.noStructLiteral = off
+	return func() { p.noStructLiteral = old }
+}
+
+//REQUIRES: what kind of error it is, where in the source it is, and a format string with its arguments (like fmt.Sprintf)
+//MODIFIES: the diagnostics field of the parser
+//EFFECTS: reports an error with the formatted message (see report)
+func (p *Parser) errorAt(code diagnostic.Code, span diagnostic.Span, format string, a ...interface{}) {
+	p.report(diagnostic.Diagnostic{Severity: diagnostic.Error, Code: code, Message: fmt.Sprintf(format, a...), Span: span})
+}
+
+const maxErrors = 20 //after this many errors the rest of the file is probably just fallout, so we stop reporting them
+
+//REQUIRES: a diagnostic
+//MODIFIES: the diagnostics field and panicMode of the parser
+//EFFECTS: adds d to the diagnostics field and puts the parser in panic mode. Errors found while already in panic mode, errors at the same place as an
+//earlier one and errors past maxErrors are dropped, since they are almost always caused by the first one
+func (p *Parser) report(d diagnostic.Diagnostic) {
+	inPanic := p.panicMode
+	p.panicMode = true
+
+	if inPanic || len(p.diagnostics) > maxErrors {
+		return
+	}
+	for _, earlier := range p.diagnostics {
+		if earlier.Span.Start == d.Span.Start { //a duplicate
+			return
+		}
+	}
+
+	if len(p.diagnostics) == maxErrors {
+		d = diagnostic.Diagnostic{Severity: diagnostic.Error, Code: diagnostic.TooManyErrors, Message: "too many errors", Span: d.Span}
+	}
+	p.diagnostics.Add(d)
+}
+
+//the keywords that start a statement, which are safe places to pick parsing back up after an error
+var statementKeywords = map[token.TokenType]bool{
+	token.LET:      true,
+	token.SET:      true,
+	token.RETURN:   true,
+	token.IF:       true,
+	token.KEY:      true,
+	token.FOR:      true,
+	token.WHILE:    true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+	token.FUNC:     true,
+	token.STRUCT:   true,
+	token.ENUM:     true,
+}
+
+//REQUIRES: the brace depth and the ( and [ depth the broken statement started at
+//MODIFIES: curToken, peekToken and panicMode
+//EFFECTS: skips the rest of a broken statement, stopping on its ; or right before the next statement keyword, } or line at the same depth (so
+//a broken statement never swallows the statements after it or the block around it). A new line inside ( or [ is still the same statement
+//(ie the arguments of a call split over several lines). Returns false if we ended up on the } that closes the block the statement was in,
+//which the caller must not step past
+func (p *Parser) synchronize(depth, groups int) bool {
+	p.panicMode = false
+
+	for !p.curTokenIs(token.EOF) {
+		if p.braceDepth < depth { //the block we were in just closed
+			return false
+		}
+		if p.braceDepth == depth {
+			if p.curTokenIs(token.SEMICOLON) {
+				return true
+			}
+			if p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) || statementKeywords[p.peekToken.Type] {
+				return true
+			}
+			if p.groupDepth <= groups && p.peekToken.Pos.Line > p.curToken.Pos.Line {
+				return true
+			}
+		}
+		p.nextToken()
+	}
+
+	return true
 }
 
 func (p *Parser) ParseProgram() *ast.Program { // 	THIS IS WHERE THE MAGIC STARTS (what gets called from REPL)
@@ -136,40 +328,111 @@ func (p *Parser) ParseProgram() *ast.Program { // 	THIS IS WHERE THE MAGIC START
 
 	//Fills the statements list of ast program struct with parsed statements
 	for !p.curTokenIs(token.EOF) { //iterates over every token in the input until it encounters an token.EOF token
-		stmt := p.parseStatement() //parses the line of code
-		if stmt != nil {           //making sure that the statement we are adding is valid within syntax (stmt will be nil if statement is not valid)
-			program.Statements = append(program.Statements, stmt) //return value is added to Statements slice of the AST root node |AND/OR| adding parsed statements to the list
-		}
-		p.nextToken() // advances both p.curToken and p.peekToken to the next statement
+		stmt, _ := p.parseStatement()                         //parses the line of code (if it is broken we get an ast.BadStatement covering it)
+		program.Statements = append(program.Statements, stmt) //return value is added to Statements slice of the AST root node |AND/OR| adding parsed statements to the list
+		p.nextToken()                                         // advances both p.curToken and p.peekToken to the next statement
 	}
 
 	return program //When nothing is left to parse the *ast.Program root node is returned.
 }
 
-func (p *Parser) parseStatement() ast.Statement { // Deciding how to parse a statment based upon the token type that lets us know what kind of statement we are looking at
+//REQUIRES:
+//MODIFIES: curToken and peekToken
+//EFFECTS: parses the statement starting at curToken. If it is broken, the rest of it is skipped and an ast.BadStatement covering it is returned
+//instead. The bool is false if recovering left us on the } closing the enclosing block
+func (p *Parser) parseStatement() (ast.Statement, bool) {
+	from := p.curToken
+	depth, groups := p.braceDepth, p.groupDepth
+	switch { //the bracket we are on was already counted, but it belongs to this statement
+	case p.curTokenIs(token.LBRACE):
+		depth--
+	case p.curTokenIs(token.LPAREN) || p.curTokenIs(token.LBRACKET):
+		groups--
+	}
+
+	stmt := p.parseStatementKind()
+	if !p.panicMode {
+		return stmt, true
+	}
+
+	more := p.synchronize(depth, groups)
+	if stmt == nil { //the statement itself was broken. If only an expression in it was, it is kept with an ast.BadExpression standing in
+		stmt = &ast.BadStatement{From: from, To: p.curToken}
+	}
+	return stmt, more
+}
+
+//the parse functions return nil pointers for broken statements. Handing one back as an ast.Statement would make a non-nil interface
+//holding a nil pointer, so every case checks for nil itself
+func (p *Parser) parseStatementKind() ast.Statement { // Deciding how to parse a statment based upon the token type that lets us know what kind of statement we are looking at
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement() //return parsed let statement
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt //return parsed let statement
+		}
+	case token.SET:
+		if stmt := p.parseSetStatement(); stmt != nil {
+			return stmt //return parsed set statement
+		}
 	case token.RETURN:
 		return p.parseReturnStatement() //return parsed statement
+	case token.KEY:
+		if stmt := p.parseKeyStatement(); stmt != nil {
+			return stmt //return parsed key statement
+		}
+	case token.FOR:
+		if stmt := p.parseForInStatement(); stmt != nil {
+			return stmt //return parsed for in loop
+		}
+	case token.WHILE:
+		if stmt := p.parseWhileStatement(); stmt != nil {
+			return stmt //return parsed while loop
+		}
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement() //return parsed break or continue
+	case token.STRUCT:
+		if stmt := p.parseStructDeclaration(); stmt != nil {
+			return stmt //return parsed struct declaration
+		}
+	case token.ENUM:
+		if stmt := p.parseEnumDeclaration(); stmt != nil {
+			return stmt //return parsed enum declaration
+		}
+	case token.FUNC:
+		if p.peekTokenIs(token.IDENT) { //func followed by a name declares a function, otherwise it is an anonymous function
+			if stmt := p.parseFunctionDeclaration(); stmt != nil {
+				return stmt //return parsed function declaration
+			}
+			return nil
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement() // return parsed expression statement
 	}
+	return nil
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement { //constructs an *ast.LetStatement node with the token it’s currently sitting on (a token.LET token) and then advances the tokens while making assertions about the next token with calls to expectPeek
-	//let <identifier> = <expression>; let apple = pie;
+	//let <type> <identifier> := <expression>; let int apple := pie;
 	stmt := &ast.LetStatement{Token: p.curToken} //let statement struct in AST obtains the let token
 
+	p.nextToken()             //advancing tokens to the type
+	stmt.Type = p.parseType() //every variable has to be declared with a type
+	if stmt.Type == nil {
+		return nil
+	}
+
 	if !p.expectPeek(token.IDENT) { //we expect to see a identifier/label to have some value assigned to it
 		return nil
 	}
 
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal} //constructs an *ast.Identifier node (ie variable label)
 
-	if !p.expectPeek(token.ASSIGN) { //we expect to see assignment operator
+	if !p.peekTokenIs(token.WALRUS) { //we expect to see the walrus operator :=
+		p.assignOperatorError("expected next token to be :=, got %s instead", p.peekToken.Type)
 		return nil
 	}
+	p.nextToken()
 
 	p.nextToken() //advancing tokens to expression (this is what comes after the assignment operator)
 
@@ -182,10 +445,66 @@ func (p *Parser) parseLetStatement() *ast.LetStatement { //constructs an *ast.Le
 	return stmt // send back the statement so that it can be added to program's statement list (if it is valid)
 }
 
+//the operators that can follow the variable name in a set statement
+var setOperators = map[token.TokenType]bool{
+	token.WALRUS:          true, // set x := 2
+	token.PLUS_ASSIGN:     true, // set x :+ 2
+	token.MINUS_ASSIGN:    true, // set x :- 2
+	token.ASTERISK_ASSIGN: true, // set x :* 2
+	token.SLASH_ASSIGN:    true, // set x :/ 2
+}
+
+func (p *Parser) parseSetStatement() *ast.SetStatement { // constructs a ast.SetStatement
+	//set <identifier> <update operator> <expression>; set apple :+ 3;
+	stmt := &ast.SetStatement{Token: p.curToken} //set statement struct in AST obtains the set token
+
+	if !p.expectPeek(token.IDENT) { //we expect to see the label of the variable being updated
+		return nil
+	}
+
+	stmt.Target = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	for p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.DOT) { //setting an element or a field of the variable (ie set m["k"] := 1, or set ps[0].x := 1)
+		p.nextToken()
+		if p.curTokenIs(token.LBRACKET) {
+			stmt.Target = p.parseIndexExpression(stmt.Target)
+		} else {
+			stmt.Target = p.parseSelectorExpression(stmt.Target)
+		}
+		if stmt.Target == nil {
+			return nil
+		}
+	}
+
+	if !setOperators[p.peekToken.Type] { //we expect to see one of the update operators
+		p.assignOperatorError("expected next token to be one of :=, :+, :-, :*, :/, got %s instead", p.peekToken.Type)
+		return nil
+	}
+	p.nextToken()
+
+	stmt.Operator = p.curToken.Literal
+
+	p.nextToken() //advancing tokens to the expression that comes after the operator
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) { //checking to see that the set statement has ended and advancing the cur and peek tokens
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement { // constructs a ast.ReturnStatement
 	//return <expression>;
 	stmt := &ast.ReturnStatement{Token: p.curToken} //return statement struct in AST obtains the return token
 
+	if p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) { //a bare 'return;' leaves a function that doesn't return anything
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+		return stmt
+	}
+
 	p.nextToken() //advancing tokens to expression (what comes after 'return')
 
 	stmt.ReturnValue = p.parseExpression(LOWEST) //value that we are returning
@@ -197,6 +516,285 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement { // constructs a a
 	return stmt // send back the statement so that it can be added to program's statement list (if it is valid)
 }
 
+func (p *Parser) parseKeyStatement() *ast.KeyStatement { // constructs a ast.KeyStatement
+	//key (<keys>) { lock <values> {<body>} ... lock else {<body>} }
+	stmt := &ast.KeyStatement{Token: p.curToken} //key statement struct in AST obtains the key token
+
+	if !p.expectPeek(token.LPAREN) { //the keys are wrapped in ( )
+		return nil
+	}
+
+	stmt.Keys = p.parseExpressionList(token.RPAREN) //the comma separated keys
+	if stmt.Keys == nil {
+		return nil
+	}
+	if len(stmt.Keys) == 0 { //key () has nothing to test the locks against
+		p.errorAt(diagnostic.InvalidKey, diagnostic.Span{Start: stmt.Token.Pos, End: p.curToken.End}, "key statement needs at least one key")
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) { //the locks are wrapped in { }
+		return nil
+	}
+
+	p.nextToken() //let's look at the first lock
+
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) { //either we hit the end of the key statement or the end of the file
+		if !p.curTokenIs(token.LOCK) { //only locks can go inside a key statement
+			p.errorAt(diagnostic.UnexpectedToken, diagnostic.TokenSpan(p.curToken), "expected lock inside key statement, got %s instead", p.curToken.Type)
+			return nil
+		}
+
+		if stmt.Else != nil { //lock else catches everything, so anything after it could never run
+			p.report(diagnostic.Diagnostic{
+				Severity: diagnostic.Error,
+				Code:     diagnostic.InvalidKey,
+				Message:  "lock else must be the last lock in a key statement",
+				Span:     diagnostic.TokenSpan(p.curToken),
+				Notes:    []string{"lock else unlocks for anything, so the locks after it could never run"},
+			})
+			return nil
+		}
+
+		if p.peekTokenIs(token.ELSE) { // is this the lock else?
+			stmt.ElseToken = p.curToken
+			p.nextToken() // advances curToken to be the else token
+
+			if !p.expectPeek(token.LBRACE) { // we expect to see a { for the body of the lock else
+				return nil
+			}
+
+			stmt.Else = p.parseBlockStatement()
+		} else {
+			lock := p.parseLockClause()
+			if lock == nil {
+				return nil
+			}
+			if len(lock.Values) != 1 && len(lock.Values) != len(stmt.Keys) { //either a value per key, or a single condition
+				p.report(diagnostic.Diagnostic{
+					Severity: diagnostic.Error,
+					Code:     diagnostic.InvalidKey,
+					Message:  fmt.Sprintf("lock has %d values but key has %d keys", len(lock.Values), len(stmt.Keys)),
+					Span:     diagnostic.Span{Start: lock.Token.Pos, End: lock.Values[len(lock.Values)-1].End()},
+					Notes:    []string{"a lock needs either one value per key, or a single bool condition"},
+				})
+				return nil
+			}
+			stmt.Locks = append(stmt.Locks, lock)
+		}
+
+		p.nextToken() // let's look at the next lock
+	}
+
+	if !p.curTokenIs(token.RBRACE) { //we ran out of input before the key statement was closed
+		p.peekError(token.RBRACE)
+		return nil
+	}
+	stmt.Rbrace = p.curToken
+
+	p.skipOptionalSemicolon()
+
+	return stmt
+}
+
+func (p *Parser) parseLockClause() *ast.LockClause { // constructs a ast.LockClause
+	//lock <values> {<body>}
+	lock := &ast.LockClause{Token: p.curToken} //lock clause obtains the lock token
+
+	restore := p.setNoStructLiteral(true) //the { after the values starts the body
+	p.nextToken()                         //advancing curToken to the first value
+	lock.Values = append(lock.Values, p.parseExpression(LOWEST))
+
+	for p.peekTokenIs(token.COMMA) { //the rest of the comma separated values
+		p.nextToken()
+		p.nextToken()
+		lock.Values = append(lock.Values, p.parseExpression(LOWEST))
+	}
+	restore()
+
+	if !p.expectPeek(token.LBRACE) { // we expect to see a { to start the body
+		return nil
+	}
+
+	lock.Body = p.parseBlockStatement()
+
+	return lock
+}
+
+func (p *Parser) parseForInStatement() *ast.ForInStatement { // constructs a ast.ForInStatement
+	//for <identifier> in <iterable> {<body>} or for <identifier>, <identifier> in <iterable> {<body>}
+	stmt := &ast.ForInStatement{Token: p.curToken} //for in statement struct in AST obtains the for token
+
+	if !p.expectPeek(token.IDENT) { //we expect to see the label of the loop variable
+		return nil
+	}
+
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.COMMA) { //a second variable for the value (ie for k, v in m)
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()                         //advancing curToken to the iterable
+	restore := p.setNoStructLiteral(true) //the { after the iterable starts the body
+	stmt.Iterable = p.parseExpression(LOWEST)
+	restore()
+
+	if !p.expectPeek(token.LBRACE) { // we expect to see a { to start the body
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	p.skipOptionalSemicolon()
+
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() *ast.WhileStatement { // constructs a ast.WhileStatement
+	//while <condition> {<body>}
+	stmt := &ast.WhileStatement{Token: p.curToken} //while statement struct in AST obtains the while token
+
+	p.nextToken()                         //advancing curToken to the condition
+	restore := p.setNoStructLiteral(true) //the { after the condition starts the body
+	stmt.Condition = p.parseExpression(LOWEST)
+	restore()
+
+	if !p.expectPeek(token.LBRACE) { // we expect to see a { to start the body
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	p.skipOptionalSemicolon()
+
+	return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement { //parses the body of a loop, keeping track of the fact that break and continue are allowed in it
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseLoopControlStatement() ast.Statement { // constructs a ast.BreakStatement or ast.ContinueStatement
+	//break; or continue;
+	var stmt ast.Statement
+	if p.curTokenIs(token.BREAK) {
+		stmt = &ast.BreakStatement{Token: p.curToken}
+	} else {
+		stmt = &ast.ContinueStatement{Token: p.curToken}
+	}
+
+	if p.loopDepth == 0 { //there is no loop to break out of or continue
+		p.errorAt(diagnostic.MisplacedLoopExit, diagnostic.TokenSpan(p.curToken), "%s is only allowed inside of a loop", p.curToken.Literal)
+		stmt = nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) { //checking to see that the statement has ended and advancing the cur and peek tokens
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseFunctionDeclaration() *ast.FunctionDeclaration { // constructs a ast.FunctionDeclaration
+	//func <name>(<type> <parameter>, ...) returns <type> {<body>}
+	stmt := &ast.FunctionDeclaration{Token: p.curToken} //function declaration struct in AST obtains the func token
+
+	p.nextToken() //advancing curToken to the name of the function
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	stmt.Function = &ast.FunctionLiteral{Token: stmt.Token, Name: stmt.Name.Value}
+	if !p.parseFunction(stmt.Function) {
+		return nil
+	}
+
+	p.skipOptionalSemicolon()
+
+	return stmt
+}
+
+func (p *Parser) parseStructDeclaration() *ast.StructDeclaration { // constructs a ast.StructDeclaration
+	//struct <name> { <type> <field>; <type> <field>; ... }
+	stmt := &ast.StructDeclaration{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) { //we expect to see the name of the new type
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken() //advancing curToken to the type of the field
+		fieldType := p.parseType()
+		if fieldType == nil {
+			return nil
+		}
+		if !p.expectPeek(token.IDENT) { //the name of the field
+			return nil
+		}
+		stmt.Fields = append(stmt.Fields, &ast.Field{Type: fieldType, Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.SEMICOLON) { //fields are separated by semicolons (the last one can have one too)
+			return nil
+		}
+	}
+	p.nextToken() //curToken is now the }
+	stmt.Rbrace = p.curToken
+
+	p.skipOptionalSemicolon()
+
+	return stmt
+}
+
+func (p *Parser) parseEnumDeclaration() *ast.EnumDeclaration { // constructs a ast.EnumDeclaration
+	//enum <name> { <variant>, <variant>, ... }
+	stmt := &ast.EnumDeclaration{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) { //we expect to see the name of the new type
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for { //an enum needs at least one variant, so enum Month {} expects an IDENT
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Variants = append(stmt.Variants, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(token.COMMA) { //variants are separated by commas
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	stmt.Rbrace = p.curToken
+
+	p.skipOptionalSemicolon()
+
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement { // constructs a ast.ExpressionStatement
 	stmt := &ast.ExpressionStatement{Token: p.curToken} //expression statement struct in AST obtains the current token
 
@@ -213,12 +811,16 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement { // constr
 
 //Determines which parsing function (if any) should parse the given expression based off of token type seen
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	from := p.curToken
 	prefix := p.prefixParseFns[p.curToken.Type] //checkes whether we have a parsing function associated with p.curToken.Type in the prefix position. (prefix becomes that function)
 	if prefix == nil {                          //if we don't
 		p.noPrefixParseFnError(p.curToken.Type) //add a error message to our parser’s errors field
-		return nil
+		return &ast.BadExpression{From: from, To: from}
 	}
 	leftExp := prefix() //If we do, it calls that parsing function and stores that parsed expression in leftExp
+	if leftExp == nil { //the expression was broken, so a placeholder stands in for it
+		leftExp = &ast.BadExpression{From: from, To: p.curToken}
+	}
 
 	//Checking if The expression has explicitly ended using a semicolon or....
 	//this checks that expressions are parsed in the correct groups based upon precedense
@@ -231,6 +833,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		p.nextToken() //advance tokens to the next part of the expression
 
 		leftExp = infix(leftExp) //stores parsed expression
+		if leftExp == nil {      //the same as for prefix, an infix that broke (like a. or x[1) leaves a placeholder behind
+			leftExp = &ast.BadExpression{From: from, To: p.curToken}
+		}
 	}
 
 	return leftExp
@@ -257,16 +862,19 @@ func (p *Parser) curPrecedence() int {
 //%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Below are the parsing functions are registered in the parser constructor
 
 func (p *Parser) parseIdentifier() ast.Expression { //returns a *ast.Identifier node with the current token in the Token field and the literal value of the token in Value field
-	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTokenIs(token.LBRACE) && !p.noStructLiteral { //a name followed by { constructs a struct (ie Point {x: 1, y: 2})
+		return p.parseStructLiteral(ident)
+	}
+	return ident
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression { //returns a *ast.IntegerLiteral node with the current token in the Token field and the literal value of the token in Value field
 	lit := &ast.IntegerLiteral{Token: p.curToken} // IntegerLiteral node obtains int token
 
-	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64) //turning the token literal(a string) into a int variable called value.
-	if err != nil {                                           //if the inputted token literal could not be parsed into an int, err != nil (meaning an error had occured)
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg) //adding error to parser struct's errors list
+	value, err := parseInt(p.curToken.Literal) //turning the token literal(a string) into a int variable called value.
+	if err != nil {                            //if the inputted token literal could not be parsed into an int, err != nil (meaning an error had occured)
+		p.errorAt(diagnostic.InvalidInteger, diagnostic.TokenSpan(p.curToken), "could not parse %q as integer", p.curToken.Literal) //adding error to parser struct's diagnostics
 		return nil
 	}
 
@@ -275,6 +883,93 @@ func (p *Parser) parseIntegerLiteral() ast.Expression { //returns a *ast.Integer
 	return lit
 }
 
+//REQUIRES: the literal of an INT token
+//MODIFIES:
+//EFFECTS: returns the value of the literal. 0x and 0b prefixes pick the base, otherwise it is decimal. strconv would read a leading 0 as octal,
+//so leading zeros are dropped first (010 is 10). _ can separate digits (ie 1_000), but only between two of them
+func parseInt(literal string) (int64, error) {
+	if len(literal) > 1 && strings.ContainsRune("xXbB", rune(literal[1])) {
+		return strconv.ParseInt(literal, 0, 64)
+	}
+
+	digits := strings.TrimLeft(literal, "0")
+	if digits == "" || digits[0] == '_' { //it was all zeros, or the zeros we dropped were followed by a _ that needs a digit before it
+		digits = "0" + digits
+	}
+	return strconv.ParseInt(digits, 0, 64)
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression { //returns a *ast.FloatLiteral node with the current token in the Token field and the value of the token in Value field
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64) //understands fractions, exponents and _ separators
+	if err != nil {
+		p.errorAt(diagnostic.InvalidFloat, diagnostic.TokenSpan(p.curToken), "could not parse %q as float", p.curToken.Literal)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression { //returns a *ast.StringLiteral, or a *ast.InterpolatedString if there are ${expression}s in it
+	tok := p.curToken
+	parts := lexer.SplitString(tok.Literal) //the lexer already reported anything wrong with the string, so we just take it apart
+
+	interpolated := false
+	value := ""
+	for _, part := range parts {
+		interpolated = interpolated || part.Interpolated
+		value += part.Text
+	}
+	if !interpolated {
+		return &ast.StringLiteral{Token: tok, Value: value}
+	}
+
+	str := &ast.InterpolatedString{Token: tok}
+	for _, part := range parts {
+		start := tok.Pos.Advance(tok.Literal[:part.Start]) //where the part is in the source
+		end := tok.Pos.Advance(tok.Literal[:part.End])
+
+		if part.Interpolated {
+			str.Parts = append(str.Parts, p.parseInterpolation(part.Text, start, end))
+		} else { //the text gets a token of its own, as if it was a string by itself
+			text := token.Token{Type: token.STRING, Literal: "\"" + tok.Literal[part.Start:part.End] + "\"", Pos: start, End: end}
+			str.Parts = append(str.Parts, &ast.StringLiteral{Token: text, Value: part.Text})
+		}
+	}
+
+	return str
+}
+
+//REQUIRES: the source of the expression in a ${...} and where it starts and ends in the file
+//MODIFIES: the diagnostics field of the parser
+//EFFECTS: parses the expression with a parser of its own (positioned where the expression is in the file) and returns it. Anything wrong with it is
+//reported as if this parser found it
+func (p *Parser) parseInterpolation(src string, start, end token.Position) ast.Expression {
+	sub := New(lexer.NewAt(src, start))
+	sub.loopDepth = p.loopDepth
+
+	if sub.curTokenIs(token.EOF) { //nothing (or only whitespace) between the braces
+		from := start
+		from.Column -= 2 //point at the ${ too
+		from.Offset -= 2
+		p.errorAt(diagnostic.ExpectedExpression, diagnostic.Span{Start: from, End: end}, "empty interpolation in string")
+		return &ast.BadExpression{From: sub.curToken, To: sub.curToken}
+	}
+
+	exp := sub.parseExpression(LOWEST)
+	if !sub.peekTokenIs(token.EOF) { //something is left over after the expression (ie "${x y}")
+		sub.errorAt(diagnostic.UnexpectedToken, diagnostic.TokenSpan(sub.peekToken), "expected } to end interpolation, got %s instead", sub.peekToken.Type)
+	}
+
+	for _, d := range sub.diagnostics { //the lexer of the string already reported the problems the sub-lexer would find, so only the parser's count
+		p.report(d)
+	}
+	return exp
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression { // token seen is prefix operator "-" or "!"
 	//<prefix operator><expression>;
 	expression := &ast.PrefixExpression{ //creates prefix expression node with the current token and its literal
@@ -304,13 +999,24 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseRangeExpression(start ast.Expression) ast.Expression { // start..end
+	expression := &ast.RangeExpression{Token: p.curToken, First: start}
+
+	precedence := p.curPrecedence()
+	p.nextToken() //advancing curToken to the end of the range
+	expression.Last = p.parseExpression(precedence)
+
+	return expression
+}
+
 func (p *Parser) parseBoolean() ast.Expression { // I mean just look at it man. EZ
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
 //This is for when we encounter a grouped expression that would other wise break the predefined presedence order
 func (p *Parser) parseGroupedExpression() ast.Expression { // EX: (5 + 5) * 10 needs to have the (5 + 5) deeper in the AST than * 10
-	p.nextToken() //advancing the curToken after the open (
+	defer p.setNoStructLiteral(false)() //inside of ( ) a { can't be the start of a block
+	p.nextToken()                       //advancing the curToken after the open (
 
 	exp := p.parseExpression(LOWEST) //parsing the expression that comes after the (
 
@@ -331,7 +1037,9 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	}
 
 	p.nextToken()                                    //advancing curToken to actually look at condition
+	restore := p.setNoStructLiteral(true)            //so a missing ) in 'if (x {' is reported as that, not as a broken struct literal
 	expression.Condition = p.parseExpression(LOWEST) //parsing the condition
+	restore()
 
 	if !p.expectPeek(token.RPAREN) { // we expect to see a ) after the condition
 		return nil
@@ -356,23 +1064,328 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression //return the parsed if expression
 }
 
+func (p *Parser) parseFunctionLiteral() ast.Expression { // anonymous functions: func(<type> <parameter>, ...) returns <type> {<body>}
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	if !p.parseFunction(lit) {
+		return nil
+	}
+
+	return lit
+}
+
+//REQUIRES: a function literal that has its token (and name, if it has one) filled in, with curToken sitting right before the (
+//MODIFIES: lit, the tokens are advanced to the closing } of the body
+//EFFECTS: parses the parameters, the optional return type and the body of a function into lit and returns whether that went ok
+func (p *Parser) parseFunction(lit *ast.FunctionLiteral) bool {
+	if !p.expectPeek(token.LPAREN) { // we expect the parameters to be wrapped in ( )
+		return false
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
+	if lit.Parameters == nil {
+		return false
+	}
+
+	if p.peekTokenIs(token.RETURNS) { //is there a return type?
+		p.nextToken()
+		p.nextToken() //advancing curToken to the return type
+		lit.ReturnType = p.parseType()
+		if lit.ReturnType == nil {
+			return false
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) { // we expect to see a { to start the body
+		return false
+	}
+
+	loopDepth := p.loopDepth //a function body isn't inside of the loop it was written in, so break and continue can't reach past it
+	p.loopDepth = 0
+	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
+
+	return true
+}
+
+func (p *Parser) parseFunctionParameters() []*ast.Parameter { //parses (<type> <name>, <type> <name>, ...) with curToken sitting on the (
+	params := []*ast.Parameter{}
+
+	if p.peekTokenIs(token.RPAREN) { //no parameters
+		p.nextToken()
+		return params
+	}
+
+	p.nextToken()
+	param := p.parseParameter()
+	if param == nil {
+		return nil
+	}
+	params = append(params, param)
+
+	for p.peekTokenIs(token.COMMA) { //keep going as long as there is another comma
+		p.nextToken()
+		p.nextToken()
+		param := p.parseParameter()
+		if param == nil {
+			return nil
+		}
+		params = append(params, param)
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return params
+}
+
+func (p *Parser) parseParameter() *ast.Parameter { //parses a single '<type> <name>' with curToken sitting on the type
+	param := &ast.Parameter{Type: p.parseType()}
+	if param.Type == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) { //every parameter needs a name
+		return nil
+	}
+
+	param.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return param
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression { // <function>(<arguments>)
+	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Rparen = p.curToken //parseExpressionList leaves us on the closing )
+	return exp
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression { // [<element>, <element>, ...]
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken //parseExpressionList leaves us on the closing ]
+	return array
+}
+
+func (p *Parser) parseStructLiteral(name *ast.Identifier) ast.Expression { // <name> {<field>: <value>, <field>: <value>, ...}
+	lit := &ast.StructLiteral{Name: name, Fields: []ast.FieldValue{}}
+	defer p.setNoStructLiteral(false)()
+
+	p.nextToken() //curToken is now the {
+	for !p.peekTokenIs(token.RBRACE) {
+		if len(lit.Fields) > 0 && !p.expectPeek(token.COMMA) { //fields are separated by commas
+			return nil
+		}
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if !p.expectColon() {
+			return nil
+		}
+
+		p.nextToken()
+		lit.Fields = append(lit.Fields, ast.FieldValue{Name: field, Value: p.parseExpression(LOWEST)})
+	}
+	p.nextToken() //curToken is now the }
+	lit.Rbrace = p.curToken
+
+	return lit
+}
+
+func (p *Parser) parseSelectorExpression(left ast.Expression) ast.Expression { // <left>.<field>
+	exp := &ast.SelectorExpression{Token: p.curToken, Left: left}
+
+	if !p.expectPeek(token.IDENT) { //the name of the field
+		return nil
+	}
+	exp.Field = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+//a { is only ever parsed as a block where a statement needs one (ie after 'if (x)' or 'while x'), so a { where an expression starts is always
+//a hash literal
+func (p *Parser) parseHashLiteral() ast.Expression { // {<key>: <value>, <key>: <value>, ...}
+	hash := &ast.HashLiteral{Token: p.curToken, Pairs: []ast.HashPair{}}
+	defer p.setNoStructLiteral(false)()
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if len(hash.Pairs) > 0 && !p.expectPeek(token.COMMA) { //pairs are separated by commas
+			return nil
+		}
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectColon() {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+	}
+	p.nextToken() //curToken is now the }
+	hash.Rbrace = p.curToken
+
+	return hash
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression { // <left>[<index>], where a range as the index makes it a slice
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	defer p.setNoStructLiteral(false)()
+
+	p.nextToken() //advancing curToken to the index
+	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	exp.Rbracket = p.curToken
+
+	return exp
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken} //p.curToken being of type token.LBRACE
+	defer p.setNoStructLiteral(false)()             //a block can be inside of a while condition (ie in a function literal)
 	block.Statements = []ast.Statement{}            //statements that will make up the contents of {...}
 
 	p.nextToken() //let's look at the first thing after {
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) { //either we hit the end of the block or the end of the file
-		stmt := p.parseStatement() //parse the statement we are looking at
-		if stmt != nil {           //if the statement is valid
-			block.Statements = append(block.Statements, stmt) //add it to the statement list of block
+		stmt, more := p.parseStatement()                  //parse the statement we are looking at
+		block.Statements = append(block.Statements, stmt) //add it to the statement list of block
+		if !more {                                        //the statement was broken and recovering from it brought us to the } of this block
+			break
 		}
 		p.nextToken() // let's look at the next statement in {...}
 	}
+	block.Rbrace = p.curToken //the } (or EOF if the block was never closed)
 
 	return block //let's return the parsed block
 }
 
+//parses a comma separated list of expressions that is closed by end (ie the 'x, y)' in 'key (x, y)')
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	defer p.setNoStructLiteral(false)()
+	list := []ast.Expression{}
+
+	if p.peekTokenIs(end) { //empty list
+		p.nextToken()
+		return list
+	}
+
+	p.nextToken()
+	list = append(list, p.parseExpression(LOWEST))
+
+	for p.peekTokenIs(token.COMMA) { //keep going as long as there is another comma
+		p.nextToken()
+		p.nextToken()
+		list = append(list, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectPeek(end) { //the list has to be closed
+		return nil
+	}
+
+	return list
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Type parsing
+
+func (p *Parser) parseType() ast.TypeNode { //parses the type annotation curToken is sitting on (ie the 'int' in 'let int x := 3')
+	var t ast.TypeNode
+	switch p.curToken.Type {
+	case token.IDENT: //types are referred to by name
+		t = &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+	case token.FUNC: //function types (ie 'func(int, int) returns int')
+		t = p.parseFunctionType()
+	case token.MAP: //map types (ie 'map[string]int')
+		t = p.parseMapType()
+	default:
+		p.errorAt(diagnostic.UnexpectedToken, diagnostic.TokenSpan(p.curToken), "expected a type, got %s instead", p.curToken.Type)
+		return nil
+	}
+
+	for t != nil && p.peekTokenIs(token.LBRACKET) { //every [] after the type makes it an array of it (ie int[][] is an array of int arrays)
+		p.nextToken()
+		if !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		t = &ast.ArrayType{Element: t, Rbracket: p.curToken}
+	}
+
+	return t
+}
+
+func (p *Parser) parseMapType() ast.TypeNode { //map[<type>]<type>
+	mt := &ast.MapType{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACKET) {
+		return nil
+	}
+	p.nextToken()
+	mt.Key = p.parseType()
+	if mt.Key == nil {
+		return nil
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	p.nextToken()
+	mt.Value = p.parseType() //any [] after the value type belong to it, so map[string]int[] is a map of int arrays
+	if mt.Value == nil {
+		return nil
+	}
+
+	return mt
+}
+
+func (p *Parser) parseFunctionType() ast.TypeNode { //func(<type>, <type>, ...) returns <type>
+	ft := &ast.FunctionType{Token: p.curToken, Parameters: []ast.TypeNode{}}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RPAREN) {
+		if len(ft.Parameters) > 0 && !p.expectPeek(token.COMMA) { //parameter types are separated by commas
+			return nil
+		}
+		p.nextToken()
+		param := p.parseType()
+		if param == nil {
+			return nil
+		}
+		ft.Parameters = append(ft.Parameters, param)
+	}
+	p.nextToken() //curToken is now the )
+	ft.Rparen = p.curToken
+
+	if p.peekTokenIs(token.RETURNS) { //is there a return type?
+		p.nextToken()
+		p.nextToken()
+		ft.ReturnType = p.parseType()
+		if ft.ReturnType == nil {
+			return nil
+		}
+	}
+
+	return ft
+}
+
+func (p *Parser) skipOptionalSemicolon() { //statements that end with a } may still be followed by a ;
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+}
+
 //below are two helper methods for the parser that add entries to the prefixParseFns and infixParseFns maps
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
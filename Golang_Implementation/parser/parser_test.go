@@ -2,21 +2,24 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"../ast"
+	"../diagnostic"
 	"../lexer"
 )
 
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input              string
+		expectedType       string
 		expectedIdentifier string
 		expectedValue      interface{}
 	}{
-		{"let x = 5;", "x", 5},
-		{"let y = true;", "y", true},
-		{"let foobar = y;", "foobar", "y"},
+		{"let int x := 5;", "int", "x", 5},
+		{"let bool y := true;", "bool", "y", true},
+		{"let bool foobar := y;", "bool", "foobar", "y"},
 	}
 
 	for _, tt := range tests {
@@ -35,6 +38,11 @@ func TestLetStatements(t *testing.T) {
 			return
 		}
 
+		letType := stmt.(*ast.LetStatement).Type
+		if letType.String() != tt.expectedType {
+			t.Errorf("letStmt.Type not '%s'. got=%s", tt.expectedType, letType)
+		}
+
 		val := stmt.(*ast.LetStatement).Value
 		if !testLiteralExpression(t, val, tt.expectedValue) {
 			return
@@ -42,6 +50,134 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestLetStatementErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let x := 5;", "1:7: expected next token to be IDENT, got := instead"},
+		{"let int x = 5;", "1:11: expected next token to be :=, got = instead"},
+		{"let 5 x := 5;", "1:5: expected a type, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong first error for %q. want=%q, got=%q",
+				tt.input, tt.expectedError, errors[0])
+		}
+	}
+}
+
+func TestSetStatements(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedIdentifier string
+		expectedOperator   string
+		expectedValue      interface{}
+	}{
+		{"set x := 5;", "x", ":=", 5},
+		{"set x :+ 3;", "x", ":+", 3},
+		{"set y :- z;", "y", ":-", "z"},
+		{"set y :* 2", "y", ":*", 2},
+		{"set foobar :/ 10;", "foobar", ":/", 10},
+		{"set done := true;", "done", ":=", true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.SetStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.SetStatement. got=%T", program.Statements[0])
+		}
+		if stmt.TokenLiteral() != "set" {
+			t.Errorf("stmt.TokenLiteral not 'set'. got=%q", stmt.TokenLiteral())
+		}
+		if !testIdentifier(t, stmt.Target, tt.expectedIdentifier) {
+			return
+		}
+		if stmt.Operator != tt.expectedOperator {
+			t.Errorf("stmt.Operator not '%s'. got=%s", tt.expectedOperator, stmt.Operator)
+		}
+		if !testLiteralExpression(t, stmt.Value, tt.expectedValue) {
+			return
+		}
+	}
+}
+
+func TestSetIndexStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`set m["k"] := 5;`, `set (m["k"]) := 5;`},
+		{"set grid[0][i + 1] :+ 1;", "set ((grid[0])[(i + 1)]) :+ 1;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.SetStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.SetStatement. got=%T", program.Statements[0])
+		}
+		if _, ok := stmt.Target.(*ast.IndexExpression); !ok {
+			t.Errorf("stmt.Target is not ast.IndexExpression. got=%T", stmt.Target)
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("stmt.String() wrong. want=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestSetStatementErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"set := 5;", "1:5: expected next token to be IDENT, got := instead"},
+		{"set x = 5;", "1:7: expected next token to be one of :=, :+, :-, :*, :/, got = instead"},
+		{"set x + 5;", "1:7: expected next token to be one of :=, :+, :-, :*, :/, got + instead"},
+		{"set m[1 := 5;", "1:9: expected next token to be ], got := instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong first error for %q. want=%q, got=%q",
+				tt.input, tt.expectedError, errors[0])
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -78,6 +214,32 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
+func TestBareReturnStatement(t *testing.T) {
+	input := `return; func f() { return }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	returnStmt, ok := program.Statements[0].(*ast.ReturnStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ReturnStatement. got=%T", program.Statements[0])
+	}
+	if returnStmt.ReturnValue != nil {
+		t.Errorf("returnStmt.ReturnValue not nil. got=%s", returnStmt.ReturnValue)
+	}
+
+	decl := program.Statements[1].(*ast.FunctionDeclaration)
+	if len(decl.Function.Body.Statements) != 1 {
+		t.Fatalf("function body does not contain 1 statement. got=%d", len(decl.Function.Body.Statements))
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := "foobar;"
 
@@ -140,6 +302,155 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestNumberLiteralExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"0xFF;", 255},
+		{"0b1010;", 10},
+		{"1_000_000;", 1000000},
+		{"010;", 10}, //not octal
+		{"0;", 0},
+		{"3.14;", 3.14},
+		{"2.5e-3;", 0.0025},
+		{"1_000.5;", 1000.5},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		switch expected := tt.expected.(type) {
+		case int: //testIntegerLiteral would compare the literal too, which is written differently
+			literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+			if !ok || literal.Value != int64(expected) {
+				t.Errorf("exp not *ast.IntegerLiteral with value %d. got=%#v", expected, stmt.Expression)
+			}
+		case float64:
+			literal, ok := stmt.Expression.(*ast.FloatLiteral)
+			if !ok {
+				t.Errorf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+				continue
+			}
+			if literal.Value != expected {
+				t.Errorf("literal.Value not %g. got=%g", expected, literal.Value)
+			}
+		}
+	}
+}
+
+func TestNumberLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"0b102;", `1:1: could not parse "0b102" as integer`},
+		{"1__000;", `1:1: could not parse "1__000" as integer`},
+		{"1_;", `1:1: could not parse "1_" as integer`},
+		{"0x;", `1:1: could not parse "0x" as integer`},
+		{"2e;", `1:1: could not parse "2e" as float`},
+		{"1e999;", `1:1: could not parse "1e999" as float`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expectedError {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello\t\"world\"";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != "hello\t\"world\"" {
+		t.Errorf("literal.Value not %q. got=%q", "hello\t\"world\"", literal.Value)
+	}
+	if literal.String() != `"hello\t\"world\""` {
+		t.Errorf("literal.String() not %q. got=%q", `"hello\t\"world\""`, literal.String())
+	}
+}
+
+func TestInterpolatedStringParsing(t *testing.T) {
+	input := `"${x + 1} squids \u{1F991} and ${"${y}"}!"`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+	if str.String() != `"${(x + 1)} squids \u{1F991} and ${"${y}"}!"` {
+		t.Errorf("str.String() wrong. got=%q", str.String())
+	}
+	if len(str.Parts) != 4 {
+		t.Fatalf("str.Parts does not contain 4 parts. got=%d", len(str.Parts))
+	}
+
+	testInfixExpression(t, str.Parts[0], "x", "+", 1)
+
+	text, ok := str.Parts[1].(*ast.StringLiteral)
+	if !ok || text.Value != " squids \U0001F991 and " {
+		t.Errorf("str.Parts[1] wrong. got=%#v", str.Parts[1])
+	}
+	if text.Pos().String() != "1:10" || text.End().String() != "1:32" {
+		t.Errorf("str.Parts[1] position wrong. got=%s-%s", text.Pos(), text.End())
+	}
+
+	nested, ok := str.Parts[2].(*ast.InterpolatedString)
+	if !ok || len(nested.Parts) != 1 {
+		t.Fatalf("str.Parts[2] is not an interpolated string with 1 part. got=%#v", str.Parts[2])
+	}
+	testIdentifier(t, nested.Parts[0], "y")
+	if nested.Parts[0].Pos().String() != "1:37" {
+		t.Errorf("nested interpolation position wrong. got=%s", nested.Parts[0].Pos())
+	}
+}
+
+func TestInterpolationErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`"a ${} b"`, "1:4: empty interpolation in string"},
+		{`let string s := "${x y}";`, "1:22: expected } to end interpolation, got IDENT instead"},
+		{`"${1 +}"`, "1:7: no prefix parse function for EOF found"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expectedError {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -198,19 +509,24 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 / 5;", 5, "/", 5},
 		{"5 > 5;", 5, ">", 5},
 		{"5 < 5;", 5, "<", 5},
-		{"5 == 5;", 5, "==", 5},
+		{"5 = 5;", 5, "=", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 % 5;", 5, "%", 5},
 		{"foobar + barfoo;", "foobar", "+", "barfoo"},
 		{"foobar - barfoo;", "foobar", "-", "barfoo"},
 		{"foobar * barfoo;", "foobar", "*", "barfoo"},
 		{"foobar / barfoo;", "foobar", "/", "barfoo"},
 		{"foobar > barfoo;", "foobar", ">", "barfoo"},
 		{"foobar < barfoo;", "foobar", "<", "barfoo"},
-		{"foobar == barfoo;", "foobar", "==", "barfoo"},
+		{"foobar = barfoo;", "foobar", "=", "barfoo"},
 		{"foobar != barfoo;", "foobar", "!=", "barfoo"},
-		{"true == true", true, "==", true},
+		{"true = true", true, "=", true},
 		{"true != false", true, "!=", false},
-		{"false == false", false, "==", false},
+		{"false = false", false, "=", false},
+		{"true and false", true, "and", false},
+		{"true or false", true, "or", false},
 	}
 
 	for _, tt := range infixTests {
@@ -279,16 +595,36 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"(3 + 4)((-5) * 5)",
 		},
 		{
-			"5 > 4 == 3 < 4",
-			"((5 > 4) == (3 < 4))",
+			"5 > 4 = 3 < 4",
+			"((5 > 4) = (3 < 4))",
 		},
 		{
 			"5 < 4 != 3 > 4",
 			"((5 < 4) != (3 > 4))",
 		},
 		{
-			"3 + 4 * 5 == 3 * 1 + 4 * 5",
-			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
+			"a <= b = c >= d",
+			"((a <= b) = (c >= d))",
+		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"a or b and c",
+			"(a or (b and c))",
+		},
+		{
+			"a and b or c and d",
+			"((a and b) or (c and d))",
+		},
+		{
+			"x < 1 or x > 9 and !y",
+			"((x < 1) or ((x > 9) and (!y)))",
+		},
+		{
+			"3 + 4 * 5 = 3 * 1 + 4 * 5",
+			"((3 + (4 * 5)) = ((3 * 1) + (4 * 5)))",
 		},
 		{
 			"true",
@@ -299,12 +635,12 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"false",
 		},
 		{
-			"3 > 5 == false",
-			"((3 > 5) == false)",
+			"3 > 5 = false",
+			"((3 > 5) = false)",
 		},
 		{
-			"3 < 5 == true",
-			"((3 < 5) == true)",
+			"3 < 5 = true",
+			"((3 < 5) = true)",
 		},
 		{
 			"1 + (2 + 3) + 4",
@@ -327,8 +663,20 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"(-(5 + 5))",
 		},
 		{
-			"!(true == true)",
-			"(!(true == true))",
+			"!(true = true)",
+			"(!(true = true))",
+		},
+		{
+			"1..10",
+			"(1..10)",
+		},
+		{
+			"a + 1..b * 2",
+			"((a + 1)..(b * 2))",
+		},
+		{
+			"1..n < m",
+			"((1..n) < m)",
 		},
 		{
 			"a + add(b * c) + d",
@@ -342,6 +690,30 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"-xs[0]",
+			"(-(xs[0]))",
+		},
+		{
+			"xs[1..len(xs) - 1]",
+			"(xs[(1..(len(xs) - 1))])",
+		},
+		{
+			"-p.x * ps[0].y",
+			"((-(p.x)) * ((ps[0]).y))",
+		},
+		{
+			"a.b.c(1)",
+			"((a.b).c)(1)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -502,8 +874,14 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
-func TestFunctionLiteralParsing(t *testing.T) {
-	input := `fn(x, y) { x + y; }`
+func TestKeyStatement(t *testing.T) {
+	input := `
+key (x, y)
+{
+  lock x = y { x }
+  lock 1, 2 { y }
+  lock else { 0 }
+}`
 
 	l := lexer.New(input)
 	p := New(l)
@@ -511,70 +889,1041 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	checkParserErrors(t, p)
 
 	if len(program.Statements) != 1 {
-		t.Fatalf("program.Body does not contain %d statements. got=%d\n",
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
 			1, len(program.Statements))
 	}
 
-	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	stmt, ok := program.Statements[0].(*ast.KeyStatement)
 	if !ok {
-		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+		t.Fatalf("program.Statements[0] is not ast.KeyStatement. got=%T",
 			program.Statements[0])
 	}
 
-	function, ok := stmt.Expression.(*ast.FunctionLiteral)
-	if !ok {
-		t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T",
-			stmt.Expression)
+	if len(stmt.Keys) != 2 {
+		t.Fatalf("key statement does not have 2 keys. got=%d", len(stmt.Keys))
 	}
+	testIdentifier(t, stmt.Keys[0], "x")
+	testIdentifier(t, stmt.Keys[1], "y")
 
-	if len(function.Parameters) != 2 {
-		t.Fatalf("function literal parameters wrong. want 2, got=%d\n",
-			len(function.Parameters))
+	if len(stmt.Locks) != 2 {
+		t.Fatalf("key statement does not have 2 locks. got=%d", len(stmt.Locks))
 	}
 
-	testLiteralExpression(t, function.Parameters[0], "x")
-	testLiteralExpression(t, function.Parameters[1], "y")
+	if len(stmt.Locks[0].Values) != 1 {
+		t.Fatalf("first lock does not have 1 value. got=%d", len(stmt.Locks[0].Values))
+	}
+	testInfixExpression(t, stmt.Locks[0].Values[0], "x", "=", "y")
 
-	if len(function.Body.Statements) != 1 {
-		t.Fatalf("function.Body.Statements has not 1 statements. got=%d\n",
-			len(function.Body.Statements))
+	if len(stmt.Locks[1].Values) != 2 {
+		t.Fatalf("second lock does not have 2 values. got=%d", len(stmt.Locks[1].Values))
 	}
+	testLiteralExpression(t, stmt.Locks[1].Values[0], 1)
+	testLiteralExpression(t, stmt.Locks[1].Values[1], 2)
 
-	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
-	if !ok {
-		t.Fatalf("function body stmt is not ast.ExpressionStatement. got=%T",
-			function.Body.Statements[0])
+	if stmt.Else == nil {
+		t.Fatalf("stmt.Else is nil")
+	}
+	if len(stmt.Else.Statements) != 1 {
+		t.Fatalf("stmt.Else does not have 1 statement. got=%d", len(stmt.Else.Statements))
 	}
 
-	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+	expected := "key (x, y) {lock (x = y) {x}lock 1, 2 {y}lock else {0}}"
+	if stmt.String() != expected {
+		t.Errorf("stmt.String() wrong. want=%q, got=%q", expected, stmt.String())
+	}
 }
 
-func TestFunctionParameterParsing(t *testing.T) {
+func TestKeyStatementErrors(t *testing.T) {
 	tests := []struct {
-		input          string
-		expectedParams []string
+		input         string
+		expectedError string
 	}{
-		{input: "fn() {};", expectedParams: []string{}},
-		{input: "fn(x) {};", expectedParams: []string{"x"}},
-		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+		{"key () { lock 1 { 1 } }", "1:1: key statement needs at least one key"},
+		{"key (x) { 1 }", "1:11: expected lock inside key statement, got INT instead"},
+		{"key (x) { lock else { 1 } lock 2 { 2 } }", "1:27: lock else must be the last lock in a key statement"},
+		{"key (x, y, z) { lock 1, 2 { 1 } }", "1:17: lock has 2 values but key has 3 keys"},
+		{"key (x) { lock 1 { 1 }", "1:23: expected next token to be }, got EOF instead"},
 	}
 
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
 		p := New(l)
-		program := p.ParseProgram()
-		checkParserErrors(t, p)
-
-		stmt := program.Statements[0].(*ast.ExpressionStatement)
-		function := stmt.Expression.(*ast.FunctionLiteral)
+		p.ParseProgram()
 
-		if len(function.Parameters) != len(tt.expectedParams) {
-			t.Errorf("length parameters wrong. want %d, got=%d\n",
-				len(tt.expectedParams), len(function.Parameters))
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
 		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong first error for %q. want=%q, got=%q",
+				tt.input, tt.expectedError, errors[0])
+		}
+	}
+}
 
-		for i, ident := range tt.expectedParams {
-			testLiteralExpression(t, function.Parameters[i], ident)
+func TestForInStatement(t *testing.T) {
+	input := `for num in 1..100 { num }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForInStatement. got=%T",
+			program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmt.Variable, "num") {
+		return
+	}
+
+	rng, ok := stmt.Iterable.(*ast.RangeExpression)
+	if !ok {
+		t.Fatalf("stmt.Iterable is not ast.RangeExpression. got=%T", stmt.Iterable)
+	}
+	testLiteralExpression(t, rng.First, 1)
+	testLiteralExpression(t, rng.Last, 100)
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("stmt.Body does not have 1 statement. got=%d", len(stmt.Body.Statements))
+	}
+
+	body, ok := stmt.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T",
+			stmt.Body.Statements[0])
+	}
+	testIdentifier(t, body.Expression, "num")
+}
+
+func TestForInWithTwoVariables(t *testing.T) {
+	input := `for k, v in {"a": 1} { v }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForInStatement. got=%T", program.Statements[0])
+	}
+
+	testIdentifier(t, stmt.Variable, "k")
+	testIdentifier(t, stmt.Value, "v")
+	if _, ok := stmt.Iterable.(*ast.HashLiteral); !ok {
+		t.Errorf("stmt.Iterable is not ast.HashLiteral. got=%T", stmt.Iterable)
+	}
+
+	expected := `for k, v in {"a": 1} {v}`
+	if stmt.String() != expected {
+		t.Errorf("stmt.String() wrong. want=%q, got=%q", expected, stmt.String())
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while x < 10 { set x :+ 1; if (x = 5) { continue; } break; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T",
+			program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 3 {
+		t.Fatalf("stmt.Body does not have 3 statements. got=%d", len(stmt.Body.Statements))
+	}
+
+	if _, ok := stmt.Body.Statements[2].(*ast.BreakStatement); !ok {
+		t.Errorf("Statements[2] is not ast.BreakStatement. got=%T", stmt.Body.Statements[2])
+	}
+
+	expected := "while (x < 10) {set x :+ 1;if(x = 5) continue;break;}"
+	if stmt.String() != expected {
+		t.Errorf("stmt.String() wrong. want=%q, got=%q", expected, stmt.String())
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"break;", "1:1: break is only allowed inside of a loop"},
+		{"continue;", "1:1: continue is only allowed inside of a loop"},
+		{"if (true) { break; }", "1:13: break is only allowed inside of a loop"},
+		{"while true { } continue;", "1:16: continue is only allowed inside of a loop"},
+		{"key (1) { lock 1 { break; } }", "1:20: break is only allowed inside of a loop"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("expected 1 parser error for %q, got %d", tt.input, len(errors))
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. want=%q, got=%q",
+				tt.input, tt.expectedError, errors[0])
+		}
+	}
+
+	inLoops := []string{
+		"while true { break; }",
+		"for i in 1..3 { continue; }",
+		"while true { for i in 1..3 { break; } continue; }",
+		"for i in 1..3 { key (i) { lock 2 { break; } } }",
+	}
+
+	for _, input := range inLoops {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+		checkParserErrors(t, p)
+	}
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+	input := `func(int x, bool y) returns int { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T",
+			stmt.Expression)
+	}
+
+	if len(function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got=%d\n",
+			len(function.Parameters))
+	}
+
+	testLiteralExpression(t, function.Parameters[0].Name, "x")
+	testLiteralExpression(t, function.Parameters[1].Name, "y")
+
+	if function.Parameters[0].Type.String() != "int" {
+		t.Errorf("first parameter type wrong. want int, got=%s", function.Parameters[0].Type)
+	}
+	if function.Parameters[1].Type.String() != "bool" {
+		t.Errorf("second parameter type wrong. want bool, got=%s", function.Parameters[1].Type)
+	}
+	if function.ReturnType == nil || function.ReturnType.String() != "int" {
+		t.Errorf("function return type wrong. want int, got=%v", function.ReturnType)
+	}
+
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("function.Body.Statements has not 1 statements. got=%d\n",
+			len(function.Body.Statements))
+	}
+
+	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("function body stmt is not ast.ExpressionStatement. got=%T",
+			function.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestFunctionParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{input: "func() {};", expectedParams: []string{}},
+		{input: "func(int x) {};", expectedParams: []string{"x"}},
+		{input: "func(int x, bool y, int z) {};", expectedParams: []string{"x", "y", "z"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Errorf("length parameters wrong. want %d, got=%d\n",
+				len(tt.expectedParams), len(function.Parameters))
+		}
+
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i].Name, ident)
+		}
+
+		if function.ReturnType != nil {
+			t.Errorf("function.ReturnType not nil. got=%s", function.ReturnType)
+		}
+	}
+}
+
+func TestFunctionDeclarationParsing(t *testing.T) {
+	input := `
+func add(int x, int y) returns int
+{
+    return x + y;
+}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.FunctionDeclaration)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.FunctionDeclaration. got=%T",
+			program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmt.Name, "add") {
+		return
+	}
+	if stmt.Function.Name != "add" {
+		t.Errorf("stmt.Function.Name not 'add'. got=%q", stmt.Function.Name)
+	}
+	if len(stmt.Function.Parameters) != 2 {
+		t.Fatalf("function parameters wrong. want 2, got=%d", len(stmt.Function.Parameters))
+	}
+
+	expected := "func add(int x, int y) returns int {return (x + y);}"
+	if stmt.String() != expected {
+		t.Errorf("stmt.String() wrong. want=%q, got=%q", expected, stmt.String())
+	}
+}
+
+func TestFunctionTypeParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let func() f := g;", "func()"},
+		{"let func(int) returns int f := g;", "func(int) returns int"},
+		{"let func(int, bool) returns func(int) returns int f := g;", "func(int, bool) returns func(int) returns int"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.LetStatement)
+		if _, ok := stmt.Type.(*ast.FunctionType); !ok {
+			t.Fatalf("stmt.Type is not ast.FunctionType. got=%T", stmt.Type)
+		}
+		if stmt.Type.String() != tt.expected {
+			t.Errorf("stmt.Type.String() wrong. want=%q, got=%q", tt.expected, stmt.Type.String())
+		}
+	}
+}
+
+func TestLoopControlInsideFunction(t *testing.T) {
+	input := "while true { func() { break; }; }"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0] != "1:23: break is only allowed inside of a loop" {
+		t.Errorf("expected break inside a function body to be rejected. got=%q", errors)
+	}
+}
+
+func TestParserDiagnostics(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedCode  diagnostic.Code
+		expectedStart string
+		expectedEnd   string
+	}{
+		{"let int x = 5;", diagnostic.UnexpectedToken, "1:11", "1:12"},
+		{"let int x := 99999999999999999999;", diagnostic.InvalidInteger, "1:14", "1:34"},
+		{"let int x := );", diagnostic.ExpectedExpression, "1:14", "1:15"},
+		{"key (x, y) { lock 1, 2, 3 { 1 } }", diagnostic.InvalidKey, "1:14", "1:26"},
+		{"continue;", diagnostic.MisplacedLoopExit, "1:1", "1:9"},
+		{"let bool b := x == 1;", diagnostic.DoubleEquals, "1:17", "1:19"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) == 0 {
+			t.Errorf("expected diagnostics for %q, got none", tt.input)
+			continue
+		}
+
+		d := diagnostics[0]
+		if d.Severity != diagnostic.Error {
+			t.Errorf("wrong severity for %q. want=error, got=%s", tt.input, d.Severity)
+		}
+		if d.Code != tt.expectedCode {
+			t.Errorf("wrong code for %q. want=%s, got=%s", tt.input, tt.expectedCode, d.Code)
+		}
+		if d.Span.Start.String() != tt.expectedStart || d.Span.End.String() != tt.expectedEnd {
+			t.Errorf("wrong span for %q. want=%s-%s, got=%s-%s", tt.input, tt.expectedStart, tt.expectedEnd, d.Span.Start, d.Span.End)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements []string
+	}{
+		{
+			"let int x = 5; let int y := 10; set z + 1; let int w := 3;",
+			[]string{"1:11: expected next token to be :=, got = instead", "1:39: expected next token to be one of :=, :+, :-, :*, :/, got + instead"},
+			[]string{"<bad statement>", "let int y := 10;", "<bad statement>", "let int w := 3;"},
+		},
+		{
+			"func f() { let int x = ; return 1; } let int y := 2;",
+			[]string{"1:22: expected next token to be :=, got = instead"},
+			[]string{"func f() {<bad statement>return 1;}", "let int y := 2;"},
+		},
+		{
+			"while (true) { let int x := } let int y := 1;",
+			[]string{"1:29: no prefix parse function for } found"},
+			[]string{"while true {let int x := <bad expression>;}", "let int y := 1;"},
+		},
+		{
+			"if (x { 1 } let int y := 1;",
+			[]string{"1:7: expected next token to be ), got { instead"},
+			[]string{"<bad expression>", "let int y := 1;"},
+		},
+		{
+			"key (x) { lock 1, 2 { 1 } lock 3 { 3 } } key (y) { lock 4 { 4 } }",
+			[]string{"1:11: lock has 2 values but key has 1 keys"},
+			[]string{"<bad statement>", "key (y) {lock 4 {4}}"},
+		},
+		{
+			"let int x := (1 + ; 5",
+			[]string{"1:19: no prefix parse function for ; found"},
+			[]string{"let int x := <bad expression>;", "5"},
+		},
+		{
+			"print(1 +)\nprint(2 +)\nprint(3)",
+			[]string{"1:10: no prefix parse function for ) found", "2:10: no prefix parse function for ) found"}, //a new line is a new statement
+			[]string{"print()", "print()", "print(3)"},
+		},
+		{
+			"print(1 +,\n  2)\n[3 +]\nprint(4)",
+			[]string{"1:10: no prefix parse function for , found", "3:5: no prefix parse function for ] found"}, //but not inside ( or [
+			[]string{"print()", "[]", "print(4)"},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("wrong number of errors for %q. want=%q, got=%q", tt.input, tt.expectedErrors, errors)
+			continue
+		}
+		for i, err := range tt.expectedErrors {
+			if errors[i] != err {
+				t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, err, errors[i])
+			}
+		}
+
+		if len(program.Statements) != len(tt.expectedStatements) {
+			t.Errorf("wrong number of statements for %q. want=%d, got=%d (%q)", tt.input, len(tt.expectedStatements), len(program.Statements), program.String())
+			continue
+		}
+		for i, stmt := range tt.expectedStatements {
+			if program.Statements[i].String() != stmt {
+				t.Errorf("wrong statement for %q. want=%q, got=%q", tt.input, stmt, program.Statements[i].String())
+			}
+		}
+	}
+}
+
+func TestBadNodePositions(t *testing.T) {
+	input := "let int x := 1;\nlet int y = 2 + 3;\nx"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	bad, ok := program.Statements[1].(*ast.BadStatement)
+	if !ok {
+		t.Fatalf("program.Statements[1] not *ast.BadStatement. got=%T", program.Statements[1])
+	}
+	if bad.Pos().String() != "2:1" || bad.End().String() != "2:19" {
+		t.Errorf("bad statement covers the wrong source. want=2:1-2:19, got=%s-%s", bad.Pos(), bad.End())
+	}
+
+	let, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+	if _, ok := let.Value.(*ast.IntegerLiteral); !ok {
+		t.Errorf("let.Value not *ast.IntegerLiteral. got=%T", let.Value)
+	}
+
+	l = lexer.New("let int x := ;")
+	p = New(l)
+	program = p.ParseProgram()
+
+	let, ok = program.Statements[0].(*ast.LetStatement) //only the expression was broken, so the let statement is kept
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+	bad2, ok := let.Value.(*ast.BadExpression)
+	if !ok {
+		t.Fatalf("let.Value not *ast.BadExpression. got=%T", let.Value)
+	}
+	if bad2.Pos().String() != "1:14" {
+		t.Errorf("bad expression at wrong position. want=1:14, got=%s", bad2.Pos())
+	}
+}
+
+func TestBrokenInfixPositions(t *testing.T) {
+	inputs := []string{
+		"let int y := a.;",
+		"print(a.)",
+		"-x[1",
+		`"${x[}"`,
+		"let int y := a.;\nlet int z := 2;",
+	}
+
+	for _, input := range inputs {
+		l := lexer.New(input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected errors for %q", input)
+		}
+		for _, stmt := range program.Statements { //none of these may panic on a missing expression
+			stmt.Pos()
+			stmt.End()
+		}
+	}
+
+	l := lexer.New("let int y := a.;")
+	p := New(l)
+	program := p.ParseProgram()
+
+	let, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+	bad, ok := let.Value.(*ast.BadExpression)
+	if !ok {
+		t.Fatalf("let.Value not *ast.BadExpression. got=%T", let.Value)
+	}
+	if bad.Pos().String() != "1:14" {
+		t.Errorf("bad expression at wrong position. want=1:14, got=%s", bad.Pos())
+	}
+}
+
+func TestTooManyErrors(t *testing.T) {
+	input := strings.Repeat("let int x = 1;\n", 30)
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != maxErrors+1 {
+		t.Fatalf("expected errors to stop at %d, got %d", maxErrors+1, len(errors))
+	}
+	if errors[maxErrors] != "21:11: too many errors" {
+		t.Errorf("last error wrong. got=%q", errors[maxErrors])
+	}
+}
+
+func TestEqualsSuggestions(t *testing.T) {
+	tests := []struct {
+		input               string
+		expectedMessage     string
+		expectedReplacement string
+	}{
+		{"if (x == 1) { x }", "1:7: unexpected ==, did you mean =?", "="},
+		{"let int x = 5;", "1:11: expected next token to be :=, got = instead", ":="},
+		{"set x = 5;", "1:7: expected next token to be one of :=, :+, :-, :*, :/, got = instead", ":="},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Errorf("expected 1 diagnostic for %q, got %d: %v", tt.input, len(diagnostics), diagnostics.Errors())
+			continue
+		}
+
+		d := diagnostics[0]
+		if d.String() != tt.expectedMessage {
+			t.Errorf("wrong message for %q. want=%q, got=%q", tt.input, tt.expectedMessage, d.String())
+		}
+		if len(d.Suggestions) != 1 || d.Suggestions[0].Replacement != tt.expectedReplacement || d.Suggestions[0].Span != d.Span {
+			t.Errorf("wrong suggestion for %q. want a replacement with %q, got=%+v", tt.input, tt.expectedReplacement, d.Suggestions)
+		}
+	}
+}
+
+func TestCommentedProgram(t *testing.T) {
+	input := `// a key is tested against "locks"
+let int x := 4; // the key
+key (x) {
+	lock 1 { 0 } /* small */
+	lock else { x / 2 } // halve it
+}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := "let int x := 4;key (x) {lock 1 {0}lock else {(x / 2)}}"
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. want=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestUnterminatedCommentError(t *testing.T) {
+	l := lexer.New("let int x := 1;\n/* oops\nlet int y := 2;")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0] != "2:1: unterminated block comment" {
+		t.Errorf("wrong errors. got=%q", errors)
+	}
+}
+
+func TestInvalidUTF8Error(t *testing.T) {
+	l := lexer.New("let string größe := \"${1 + \xff}\";")
+	p := New(l)
+	p.ParseProgram()
+
+	//the ILLEGAL token the byte turns into isn't reported again by the parser
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0] != "1:28: invalid UTF-8 encoding (byte 0xff)" {
+		t.Errorf("wrong errors. got=%q", errors)
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := `let int x := 1 + 2;
+add(x, 3);
+func double(int n) returns int {
+  return n * 2;
+}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	fn := program.Statements[2].(*ast.FunctionDeclaration).Function
+
+	tests := []struct {
+		node          ast.Node
+		expectedStart string
+		expectedEnd   string
+	}{
+		{program.Statements[0], "1:1", "1:19"},
+		{program.Statements[0].(*ast.LetStatement).Value, "1:14", "1:19"},
+		{program.Statements[1], "2:1", "2:10"},
+		{program.Statements[2], "3:1", "5:2"},
+		{fn.Parameters[0], "3:13", "3:18"},
+		{fn.ReturnType, "3:28", "3:31"},
+		{fn.Body.Statements[0], "4:3", "4:15"},
+		{program, "1:1", "5:2"},
+	}
+
+	for _, tt := range tests {
+		if tt.node.Pos().String() != tt.expectedStart {
+			t.Errorf("%q starts at wrong position. want=%s, got=%s", tt.node.String(), tt.expectedStart, tt.node.Pos())
+		}
+		if tt.node.End().String() != tt.expectedEnd {
+			t.Errorf("%q ends at wrong position. want=%s, got=%s", tt.node.String(), tt.expectedEnd, tt.node.End())
+		}
+	}
+}
+
+func TestArrayLiteralParsing(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, _ := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+
+	if array.Pos().Column != 1 || array.End().Column != 18 {
+		t.Errorf("array span wrong. got=%d-%d", array.Pos().Column, array.End().Column)
+	}
+}
+
+func TestEmptyArrayLiteralParsing(t *testing.T) {
+	l := lexer.New("[]")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, _ := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+	if len(array.Elements) != 0 {
+		t.Errorf("len(array.Elements) not 0. got=%d", len(array.Elements))
+	}
+}
+
+func TestIndexExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input string
+		slice bool
+	}{
+		{"myArray[1 + 1]", false},
+		{"myArray[1..2]", true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, _ := program.Statements[0].(*ast.ExpressionStatement)
+		indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+		}
+
+		if !testIdentifier(t, indexExp.Left, "myArray") {
+			return
+		}
+
+		if _, isRange := indexExp.Index.(*ast.RangeExpression); isRange != tt.slice {
+			t.Errorf("index of %q wrong. got=%T", tt.input, indexExp.Index)
+		}
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	expected := []struct {
+		key   string
+		value int64
+	}{{"one", 1}, {"two", 2}, {"three", 3}}
+
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	for i, pair := range hash.Pairs { //pairs stay in the order they were written
+		key, ok := pair.Key.(*ast.StringLiteral)
+		if !ok || key.Value != expected[i].key {
+			t.Errorf("pair %d has wrong key. got=%s", i, pair.Key)
+		}
+		testIntegerLiteral(t, pair.Value, expected[i].value)
+	}
+}
+
+func TestParsingHashLiteralsWithExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{}", "{}"},
+		{`{"one": 0 + 1, "two": 10 - 8}`, `{"one": (0 + 1), "two": (10 - 8)}`},
+		{"{1: [1, 2], 2: []}[1][0]", "(({1: [1, 2], 2: []}[1])[0])"},
+		{`while x { {"a": x}["a"] }`, `while x {({"a": x}["a"])}`},
+		{`{"a":-1, "b":-x}`, `{"a": (-1), "b": (-x)}`}, //the lexer reads :- as an update operator, which the parser splits back up
+		{`{"a":/* c */ 1, "b"://c` + "\n2}", `{"a": 1, "b": 2}`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. want=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestHashLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`{"a" 1}`, "1:6: expected next token to be :, got INT instead"},
+		{`{"a":*2}`, "1:6: no prefix parse function for * found"}, //split up, the * is still in the wrong place
+		{`{"a": 1 "b": 2}`, "1:9: expected next token to be ,, got STRING instead"},
+		{"let map[string int m := {};", "1:16: expected next token to be ], got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expectedError {
+			t.Errorf("wrong errors for %q. want=%q first, got=%q", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestStructDeclarationParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		fields   int
+	}{
+		{"struct Point { int x; int y }", "struct Point {int x; int y}", 2},
+		{"struct Node { int value; Node[] children; };", "struct Node {int value; Node[] children}", 2}, //the last field can end with a ; too
+		{"struct Empty {}", "struct Empty {}", 0},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.StructDeclaration)
+		if !ok {
+			t.Fatalf("stmt not *ast.StructDeclaration. got=%T", program.Statements[0])
+		}
+		if len(stmt.Fields) != tt.fields {
+			t.Errorf("stmt.Fields has wrong length. want=%d, got=%d", tt.fields, len(stmt.Fields))
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("stmt.String() wrong. want=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestStructLiteralParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Point{x: 1, y: 2 * 3}", "Point{x: 1, y: (2 * 3)}"},
+		{"Empty{}", "Empty{}"},
+		{"Point{x:-1, y:2}", "Point{x: (-1), y: 2}"},
+		{"Line{from: Point{x: 0, y: 0}, to: p}.to.x", "((Line{from: Point{x: 0, y: 0}, to: p}.to).x)"},
+		{"let Point p := Point{x: 1, y: 2};", "let Point p := Point{x: 1, y: 2};"},
+		{"set p.x :+ 1;", "set (p.x) :+ 1;"},
+		{"set ps[0].x := 1;", "set ((ps[0]).x) := 1;"},
+		//the { after the condition of a loop starts its body, unless the literal is wrapped in ( )
+		{"while p.x < n { 1 }", "while ((p.x) < n) {1}"},
+		{"for q in ps { q }", "for q in ps {q}"},
+		{"while (p = Point{x: 1}) { 1 }", "while (p = Point{x: 1}) {1}"},
+		{"key (n) { lock m { 1 } }", "key (n) {lock m {1}}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. want=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestStructErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"struct { int x }", "1:8: expected next token to be IDENT, got { instead"},
+		{"struct Point { int x int y }", "1:22: expected next token to be ;, got IDENT instead"},
+		{"Point{x 1}", "1:9: expected next token to be :, got INT instead"},
+		{"Point{x: 1 y: 2}", "1:12: expected next token to be ,, got IDENT instead"},
+		{"p.1", "1:3: expected next token to be IDENT, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expectedError {
+			t.Errorf("wrong errors for %q. want=%q first, got=%q", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestEnumDeclarationParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		variants []string
+	}{
+		{"enum Month { Jan, Feb, Mar }", "enum Month {Jan, Feb, Mar}", []string{"Jan", "Feb", "Mar"}},
+		{"enum Unit { One };", "enum Unit {One}", []string{"One"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.EnumDeclaration)
+		if !ok {
+			t.Fatalf("stmt not *ast.EnumDeclaration. got=%T", program.Statements[0])
+		}
+		if len(stmt.Variants) != len(tt.variants) {
+			t.Fatalf("stmt.Variants has wrong length. want=%d, got=%d", len(tt.variants), len(stmt.Variants))
+		}
+		for i, v := range tt.variants {
+			testIdentifier(t, stmt.Variants[i], v)
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("stmt.String() wrong. want=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestEnumErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"enum Month {}", "1:13: expected next token to be IDENT, got } instead"},
+		{"enum Month { Jan Feb }", "1:18: expected next token to be }, got IDENT instead"},
+		{"enum Month { Jan, }", "1:19: expected next token to be IDENT, got } instead"},
+		{"enum { Jan }", "1:6: expected next token to be IDENT, got { instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expectedError {
+			t.Errorf("wrong errors for %q. want=%q first, got=%q", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestArrayTypeParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let int[] xs := ys;", "int[]"},
+		{"let string[][] grid := ys;", "string[][]"},
+		{"let func(int[]) returns bool[] f := g;", "func(int[]) returns bool[]"},
+		{"let map[string]int m := ys;", "map[string]int"},
+		{"let map[int]string[] m := ys;", "map[int]string[]"},
+		{"let map[string]map[bool]int m := ys;", "map[string]map[bool]int"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.LetStatement)
+		if stmt.Type.String() != tt.expected {
+			t.Errorf("stmt.Type.String() wrong. want=%q, got=%q", tt.expected, stmt.Type.String())
 		}
 	}
 }
@@ -2,40 +2,125 @@ package repl
 
 import (
 	"bufio"
-	"fmt"
 	"io"
+	"strings"
 
+	"../checker"
+	"../diagnostic"
+	"../evaluator"
 	"../lexer"
+	"../object"
 	"../parser"
+	"../token"
 )
 
 const PROMPT = ">> "
+const CONTINUATION_PROMPT = ".. " //shown while what was typed so far is still open (ie after 'func f() {')
+const ABORT = ".break"            //typed on a continuation line, throws away everything typed since the last >> prompt
 
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
+	env := object.NewEnvironment() //one environment for the whole session so names bound on one line can be used on the next
+	c := checker.New()             //likewise the checker remembers the types of those names
+	object.Output = out            //print writes to the same place as everything else
 
+	pending := "" //the lines of a statement that isn't finished yet
 	for {
-		fmt.Printf(PROMPT)
+		if pending == "" {
+			io.WriteString(out, PROMPT)
+		} else {
+			io.WriteString(out, CONTINUATION_PROMPT)
+		}
+
 		scanned := scanner.Scan()
 		if !scanned {
+			if pending != "" { //the input ended in the middle of a statement, so show what is wrong with it
+				io.WriteString(out, "\n")
+				run(out, pending, env, c)
+			}
 			return
 		}
 
 		line := scanner.Text() //Take in code input from user
-		l := lexer.New(line)   //we create a lexer from user input
-		p := parser.New(l)     //we create a parser from the lexer that was just created
+		if pending != "" && strings.TrimSpace(line) == ABORT {
+			pending = ""
+			continue
+		}
 
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		input := line
+		if pending != "" {
+			input = pending + "\n" + line
+		}
+		if isIncomplete(input) { //wait for the rest before parsing anything
+			pending = input
 			continue
 		}
+		pending = ""
+
+		run(out, input, env, c)
+	}
+}
+
+//REQUIRES: a complete piece of input, and the environment and checker of the session
+//MODIFIES: out, env and c
+//EFFECTS: parses, checks and evaluates input, printing its value or whatever went wrong
+func run(out io.Writer, input string, env *object.Environment, c *checker.Checker) {
+	l := lexer.New(input) //we create a lexer from user input
+	p := parser.New(l)    //we create a parser from the lexer that was just created
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(out, input, p.Diagnostics())
+		return
+	}
+
+	diagnostics := c.Check(program)
+	if diagnostics.HasErrors() { //type errors are caught before any code runs
+		printTypeErrors(out, input, diagnostics)
+		return
+	}
+	diagnostic.RenderAll(out, input, diagnostics) //warnings are shown, but the code still runs
 
-		io.WriteString(out, program.String())
+	evaluated := evaluator.Eval(program, env)            //run the parsed program
+	if evaluated != nil && evaluated != evaluator.NULL { //statements like let (and calls like print) don't produce a value worth printing
+		io.WriteString(out, evaluated.Inspect())
 		io.WriteString(out, "\n")
 	}
 }
 
+//REQUIRES: what has been typed so far
+//MODIFIES:
+//EFFECTS: returns whether input stops in the middle of something that the next line can finish: a (, [ or { that hasn't been closed, or
+//a block comment that hasn't ended. Strings always end on the line they start on, so an unterminated string is an error right away rather
+//than something to wait for. A closing bracket without an opening one is an error too, so that is complete as well
+func isIncomplete(input string) bool {
+	closers := map[token.TokenType]token.TokenType{token.LPAREN: token.RPAREN, token.LBRACKET: token.RBRACKET, token.LBRACE: token.RBRACE}
+
+	l := lexer.New(input)
+	open := []token.TokenType{} //the closers we are waiting for, innermost last
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			open = append(open, closers[tok.Type])
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if len(open) == 0 || open[len(open)-1] != tok.Type { //mismatched, which more input can't fix
+				return false
+			}
+			open = open[:len(open)-1]
+		}
+	}
+
+	for _, d := range l.Diagnostics() {
+		switch d.Code {
+		case diagnostic.UnterminatedString:
+			return false
+		case diagnostic.UnterminatedComment:
+			return true
+		}
+	}
+	return len(open) > 0
+}
+
 const Blooper = `        
   
 
@@ -116,11 +201,16 @@ const Squiggle = `
 
 `
 
-func printParserErrors(out io.Writer, errors []string) {
+func printParserErrors(out io.Writer, src string, diagnostics diagnostic.List) {
 	io.WriteString(out, Squiggle)
 	io.WriteString(out, "Woops! We ran into some squidy business here!\n")
 	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
-	}
+	diagnostic.RenderAll(out, src, diagnostics)
+}
+
+func printTypeErrors(out io.Writer, src string, diagnostics diagnostic.List) {
+	io.WriteString(out, Squiggle)
+	io.WriteString(out, "Woops! We ran into some squidy business here!\n")
+	io.WriteString(out, " type errors:\n")
+	diagnostic.RenderAll(out, src, diagnostics)
 }
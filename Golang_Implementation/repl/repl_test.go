@@ -0,0 +1,89 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"../object"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"", false},
+		{"let int x := 5;", false},
+		{"func add(int x, int y) returns int {", true},
+		{"func add(int x, int y) returns int {\n  return x + y;\n}", false},
+		{"key (x) {\n  lock 1 {", true},
+		{"print(1,", true},
+		{"let int[] xs := [1,\n2", true},
+		{`print("{")`, false}, //braces in strings don't count
+		{`print("${len("(")}")`, false},
+		{"/* a comment\nthat goes on", true},
+		{"/* a comment */ 5", false},
+		{`print("abc`, false}, //strings end on the line they start on, so waiting wouldn't help
+		{`print("${x + `, false},
+		{`print("${x}")`, false},
+		{"print(1))", false},
+		{"f(]", false},
+	}
+
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	input := `func add(int x,
+           int y) returns int {
+  return x + y;
+}
+add(1, 2)
+let int[] xs := [1,
+.break
+print("done")
+`
+
+	expected := ">> .. .. .. >> 3\n>> .. >> done\n>> "
+
+	if out := session(input); out != expected {
+		t.Errorf("wrong transcript.\nexpected=%q\ngot     =%q", expected, out)
+	}
+}
+
+func TestUnterminatedStringIsAnError(t *testing.T) {
+	out := session("print(\"abc\nprint(\"after\")\n")
+
+	if !strings.Contains(out, "error[unterminated-string]") {
+		t.Errorf("expected an unterminated string error. got=%q", out)
+	}
+	if strings.Contains(out, ">> .. ") { //the next line is a new input, not more of the string
+		t.Errorf("expected no continuation prompt. got=%q", out)
+	}
+	if !strings.HasSuffix(out, ">> after\n>> ") {
+		t.Errorf("the next line should run on its own. got=%q", out)
+	}
+}
+
+func TestAbortedInputIsForgotten(t *testing.T) {
+	out := session("let int x := [\n.break\nx\n") //the let never ran, so x doesn't exist
+
+	if !strings.Contains(out, "undefined: x") {
+		t.Errorf("x should be undefined. got=%q", out)
+	}
+}
+
+//feeds input to a REPL and returns everything it wrote
+func session(input string) string {
+	var out bytes.Buffer
+	defer func() { object.Output = os.Stdout }()
+
+	Start(strings.NewReader(input), &out)
+	return out.String()
+}
@@ -0,0 +1,567 @@
+//OVERVIEW: Sqdc is the file format precompiled SquidScript is shipped in (.sqdc files), so a program can be run without its source.
+//All numbers are big endian, like the operands of instructions. A file is laid out as:
+//
+//	header      "SQDC", the format version (2 bytes) and flags (1 byte, FlagDebug if there is a debug section)
+//	main        the locals of the program (2 bytes) and its instructions
+//	functions   how many (4 bytes), then for each one its name, locals (2 bytes), parameters (2 bytes), upvalues and instructions
+//	constants   how many (4 bytes), then for each one a tag (1 byte) and its value. Functions are an index into the function table
+//	debug       (only with FlagDebug) the source file name, then the line table of main and of each function
+//	checksum    CRC-32 (IEEE) of everything before it (4 bytes)
+//
+//Strings and instructions are written as their length (4 bytes) followed by their bytes
+package sqdc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+
+	"../code"
+	"../compiler"
+	"../object"
+)
+
+const Magic = "SQDC"
+
+const Version = 1 //bump whenever the layout or the instruction set changes, older files then have to be compiled again
+
+const FlagDebug = 1 << 0 //the file has a debug section
+
+const ( //the tags of the constants in the constant pool
+	tagInteger byte = iota + 1
+	tagFloat
+	tagString
+	tagFunction
+	tagStructType
+	tagEnumType
+)
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Marshal
+
+//REQUIRES: the bytecode of a program, and whether to keep the line tables and source file name in it
+//MODIFIES:
+//EFFECTS: returns the bytecode in the .sqdc format, or an error if it holds something that can't be written (ie a constant the format
+//doesn't know, or more of something than the format has room for)
+func Marshal(bytecode *compiler.Bytecode, debug bool) ([]byte, error) {
+	w := &writer{}
+
+	functions := []*object.CompiledFunction{}
+	functionIndex := make(map[*object.CompiledFunction]int)
+	for _, c := range bytecode.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			functionIndex[fn] = len(functions)
+			functions = append(functions, fn)
+		}
+	}
+
+	// Header
+	w.buf.WriteString(Magic)
+	w.uint16(Version)
+	flags := 0
+	if debug {
+		flags |= FlagDebug
+	}
+	w.uint8(flags)
+
+	// Main
+	w.uint16(bytecode.NumLocals)
+	w.bytes(bytecode.Instructions)
+
+	// Functions
+	w.uint32(len(functions))
+	for _, fn := range functions {
+		w.string(fn.Name)
+		w.uint16(fn.NumLocals)
+		w.uint16(fn.NumParameters)
+		w.uint16(len(fn.Upvalues))
+		for _, u := range fn.Upvalues {
+			isLocal := 0
+			if u.IsLocal {
+				isLocal = 1
+			}
+			w.uint8(isLocal)
+			w.uint16(u.Index)
+		}
+		w.bytes(fn.Instructions)
+	}
+
+	// Constants
+	w.uint32(len(bytecode.Constants))
+	for i, c := range bytecode.Constants {
+		switch c := c.(type) {
+		case *object.Integer:
+			w.uint8(int(tagInteger))
+			w.uint64(uint64(c.Value))
+		case *object.Float:
+			w.uint8(int(tagFloat))
+			w.uint64(math.Float64bits(c.Value))
+		case *object.String:
+			w.uint8(int(tagString))
+			w.string(c.Value)
+		case *object.CompiledFunction:
+			w.uint8(int(tagFunction))
+			w.uint32(functionIndex[c])
+		case *object.StructType:
+			w.uint8(int(tagStructType))
+			w.string(c.Name)
+			w.uint16(len(c.Fields))
+			for _, f := range c.Fields {
+				w.string(f.Name)
+				w.string(f.Type)
+			}
+		case *object.EnumType:
+			w.uint8(int(tagEnumType))
+			w.string(c.Name)
+			w.uint16(len(c.Variants))
+			for _, v := range c.Variants {
+				w.string(v.Name)
+			}
+		default:
+			return nil, fmt.Errorf("cannot write constant %d, %s isn't a constant the .sqdc format knows", i, c.Type())
+		}
+	}
+
+	// Debug
+	if debug {
+		w.string(bytecode.Source)
+		w.lineTable(bytecode.Lines)
+		for _, fn := range functions {
+			w.lineTable(fn.Lines)
+		}
+	}
+
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	w.buf.Write(binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(w.buf.Bytes())))
+	return w.buf.Bytes(), nil
+}
+
+//writes the parts of a file, remembering the first number that didn't fit in its field
+type writer struct {
+	buf bytes.Buffer
+	err error
+}
+
+func (w *writer) fits(n int, max uint64) bool {
+	if n < 0 || uint64(n) > max {
+		if w.err == nil {
+			w.err = fmt.Errorf("cannot write %d, the .sqdc format only has room for %d", n, max)
+		}
+		return false
+	}
+	return true
+}
+
+func (w *writer) uint8(n int) {
+	if w.fits(n, math.MaxUint8) {
+		w.buf.WriteByte(byte(n))
+	}
+}
+
+func (w *writer) uint16(n int) {
+	if w.fits(n, math.MaxUint16) {
+		w.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	}
+}
+
+func (w *writer) uint32(n int) {
+	if w.fits(n, math.MaxUint32) {
+		w.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+func (w *writer) uint64(n uint64) {
+	w.buf.Write(binary.BigEndian.AppendUint64(nil, n))
+}
+
+func (w *writer) bytes(b []byte) {
+	w.uint32(len(b))
+	w.buf.Write(b)
+}
+
+func (w *writer) string(s string) {
+	w.bytes([]byte(s))
+}
+
+func (w *writer) lineTable(lines code.LineTable) {
+	w.uint32(len(lines))
+	for _, entry := range lines {
+		w.uint32(entry.Offset)
+		w.uint32(entry.Line)
+	}
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Unmarshal
+
+//REQUIRES: the contents of a .sqdc file
+//MODIFIES:
+//EFFECTS: returns the bytecode in it, or an error if data isn't a .sqdc file, was written by a different version of the format or is
+//corrupted. Besides the checksum, the instructions are checked to only refer to constants, locals, upvalues, builtins and jump targets
+//that exist, and to end in a return like everything the compiler produces
+func Unmarshal(data []byte) (*compiler.Bytecode, error) {
+	if len(data) < len(Magic) || string(data[:len(Magic)]) != Magic {
+		return nil, fmt.Errorf("not a .sqdc file")
+	}
+	r := &reader{data: data, pos: len(Magic)}
+
+	version := r.uint16("the version")
+	if r.err == nil && version != Version {
+		return nil, fmt.Errorf("unsupported .sqdc version %d, this build reads version %d (compile the source again)", version, Version)
+	}
+
+	if len(data) < len(Magic)+2+4 {
+		return nil, corrupted("the file is too short")
+	}
+	body, checksum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != checksum {
+		return nil, corrupted("the checksum doesn't match")
+	}
+	r.data = body
+
+	flags := r.uint8("the flags")
+	if flags&^FlagDebug != 0 {
+		return nil, corrupted(fmt.Sprintf("unknown flags %#x", flags))
+	}
+
+	bytecode := &compiler.Bytecode{}
+	bytecode.NumLocals = int(r.uint16("the locals of main"))
+	bytecode.Instructions = r.bytes("the instructions of main")
+
+	numFunctions := r.count("the number of functions", 4+2+2+2+4)
+	functions := make([]*object.CompiledFunction, numFunctions)
+	for i := range functions {
+		fn := &object.CompiledFunction{}
+		fn.Name = r.string("the name of a function")
+		fn.NumLocals = int(r.uint16("the locals of a function"))
+		fn.NumParameters = int(r.uint16("the parameters of a function"))
+		numUpvalues := int(r.uint16("the upvalues of a function"))
+		for j := 0; j < numUpvalues && r.err == nil; j++ {
+			isLocal := r.uint8("an upvalue")
+			index := r.uint16("an upvalue")
+			if isLocal > 1 {
+				r.fail("an upvalue is neither local nor captured")
+			}
+			fn.Upvalues = append(fn.Upvalues, object.UpvalueRef{IsLocal: isLocal == 1, Index: int(index)})
+		}
+		fn.Instructions = r.bytes("the instructions of a function")
+
+		if fn.NumParameters > fn.NumLocals {
+			r.fail(fmt.Sprintf("function %d has more parameters than locals", i))
+		}
+		functions[i] = fn
+	}
+
+	numConstants := r.count("the number of constants", 1)
+	bytecode.Constants = make([]object.Object, numConstants)
+	used := make([]bool, numFunctions) //every function is in the constant pool exactly once
+	for i := range bytecode.Constants {
+		bytecode.Constants[i] = r.constant(functions, used)
+	}
+	for i, u := range used {
+		if !u && r.err == nil {
+			r.fail(fmt.Sprintf("function %d isn't in the constant pool", i))
+		}
+	}
+
+	if flags&FlagDebug != 0 {
+		bytecode.Source = r.string("the source file name")
+		bytecode.Lines = r.lineTable(len(bytecode.Instructions))
+		for _, fn := range functions {
+			fn.Lines = r.lineTable(len(fn.Instructions))
+		}
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.pos != len(r.data) {
+		return nil, corrupted(fmt.Sprintf("%d bytes left over after the last section", len(r.data)-r.pos))
+	}
+
+	if err := validate(bytecode); err != nil {
+		return nil, err
+	}
+	return bytecode, nil
+}
+
+func corrupted(reason string) error {
+	return fmt.Errorf("corrupted .sqdc file: %s", reason)
+}
+
+//reads the parts of a file, remembering the first thing that went wrong so the callers don't have to check after every read
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *reader) fail(reason string) {
+	if r.err == nil {
+		r.err = corrupted(reason)
+	}
+}
+
+//returns the next n bytes, or nil if there aren't that many left
+func (r *reader) next(n int, what string) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data)-r.pos {
+		r.fail("unexpected end of file reading " + what)
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) uint8(what string) uint8 {
+	if b := r.next(1, what); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uint16(what string) uint16 {
+	if b := r.next(2, what); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) uint32(what string) uint32 {
+	if b := r.next(4, what); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) uint64(what string) uint64 {
+	if b := r.next(8, what); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+//reads how many of something follow. Each one takes up at least minSize bytes, so a count the rest of the file can't hold is
+//caught here rather than by allocating room for it
+func (r *reader) count(what string, minSize int) int {
+	n := int(r.uint32(what))
+	if r.err == nil && n > (len(r.data)-r.pos)/minSize {
+		r.fail(fmt.Sprintf("%s (%d) is more than the file holds", what, n))
+	}
+	if r.err != nil {
+		return 0
+	}
+	return n
+}
+
+func (r *reader) bytes(what string) []byte {
+	n := r.uint32(what)
+	b := r.next(int(n), what)
+	return append([]byte{}, b...) //a copy, so the bytecode doesn't hold on to the whole file
+}
+
+func (r *reader) string(what string) string {
+	return string(r.bytes(what))
+}
+
+//reads a constant of the constant pool, marking the function it refers to (if it is one) as used
+func (r *reader) constant(functions []*object.CompiledFunction, used []bool) object.Object {
+	switch tag := r.uint8("the tag of a constant"); tag {
+	case tagInteger:
+		return &object.Integer{Value: int64(r.uint64("an integer constant"))}
+	case tagFloat:
+		return &object.Float{Value: math.Float64frombits(r.uint64("a float constant"))}
+	case tagString:
+		return &object.String{Value: r.string("a string constant")}
+	case tagFunction:
+		index := int(r.uint32("a function constant"))
+		if r.err != nil {
+			return nil
+		}
+		if index >= len(functions) || used[index] {
+			r.fail(fmt.Sprintf("function constant refers to function %d, which doesn't exist or is already in the constant pool", index))
+			return nil
+		}
+		used[index] = true
+		return functions[index]
+	case tagStructType:
+		st := &object.StructType{Name: r.string("the name of a struct")}
+		numFields := int(r.uint16("the fields of a struct"))
+		for i := 0; i < numFields && r.err == nil; i++ {
+			st.Fields = append(st.Fields, object.StructField{Name: r.string("a field"), Type: r.string("a field")})
+		}
+		return st
+	case tagEnumType:
+		name := r.string("the name of an enum")
+		numVariants := int(r.uint16("the variants of an enum"))
+		variants := []string{}
+		for i := 0; i < numVariants && r.err == nil; i++ {
+			variants = append(variants, r.string("a variant"))
+		}
+		return object.NewEnumType(name, variants)
+	default:
+		if r.err == nil {
+			r.fail(fmt.Sprintf("unknown constant tag %d", tag))
+		}
+		return nil
+	}
+}
+
+//reads a line table for instructions of the given length. The entries have to go forward through the instructions
+func (r *reader) lineTable(length int) code.LineTable {
+	n := r.count("the size of a line table", 8)
+	lines := make(code.LineTable, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		entry := code.LineEntry{Offset: int(r.uint32("a line")), Line: int(r.uint32("a line"))}
+		if entry.Offset >= length || (i > 0 && entry.Offset <= lines[i-1].Offset) {
+			r.fail(fmt.Sprintf("line table entry at %d is out of order or past the instructions", entry.Offset))
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Validation
+
+//a function being checked: its instructions, how many locals and upvalues it has, and what it is called in errors
+type scope struct {
+	name         string
+	instructions code.Instructions
+	numLocals    int
+	numUpvalues  int
+}
+
+//checks the instructions of main and of every function. Functions are checked as they are created by OpClosure, since whether their
+//upvalues exist depends on the function that creates them
+func validate(bytecode *compiler.Bytecode) error {
+	main := scope{name: "main", instructions: bytecode.Instructions, numLocals: bytecode.NumLocals}
+	checked := make(map[*object.CompiledFunction]bool)
+	return validateScope(main, bytecode.Constants, checked)
+}
+
+func validateScope(s scope, constants []object.Object, checked map[*object.CompiledFunction]bool) error {
+	fail := func(pos int, format string, a ...interface{}) error {
+		return corrupted(fmt.Sprintf("%s at %d in %s", fmt.Sprintf(format, a...), pos, s.name))
+	}
+
+	starts := make(map[int]bool) //where every instruction starts, which is where jumps are allowed to go
+	jumps := make(map[int]int)   //the target of every jump, by where the jump is
+
+	ins := s.instructions
+	last := -1 //where the last instruction starts
+	for pos := 0; pos < len(ins); {
+		def, err := code.Lookup(ins[pos])
+		if err != nil {
+			return fail(pos, "%s", err)
+		}
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if pos+1+width > len(ins) {
+			return fail(pos, "%s is missing operands", def.Name)
+		}
+		operands, _ := code.ReadOperands(def, ins[pos+1:])
+		starts[pos] = true
+		last = pos
+
+		switch op := code.Opcode(ins[pos]); op {
+		case code.OpConstant:
+			if operands[0] >= len(constants) {
+				return fail(pos, "constant %d doesn't exist", operands[0])
+			}
+
+		case code.OpGetField, code.OpSetField, code.OpStruct:
+			if operands[0] >= len(constants) {
+				return fail(pos, "constant %d doesn't exist", operands[0])
+			}
+			if _, ok := constants[operands[0]].(*object.String); !ok {
+				return fail(pos, "%s needs a name, constant %d is %s", def.Name, operands[0], constants[operands[0]].Type())
+			}
+			if op == code.OpSetField && !validUpdateOperator(operands[1]) {
+				return fail(pos, "unknown update operator %d", operands[1])
+			}
+
+		case code.OpSetIndex:
+			if !validUpdateOperator(operands[0]) {
+				return fail(pos, "unknown update operator %d", operands[0])
+			}
+
+		case code.OpGetLocal, code.OpSetLocal:
+			if operands[0] >= s.numLocals {
+				return fail(pos, "local %d doesn't exist", operands[0])
+			}
+
+		case code.OpCloseUpvalues:
+			if operands[0] > s.numLocals {
+				return fail(pos, "local %d doesn't exist", operands[0])
+			}
+
+		case code.OpGetUpvalue, code.OpSetUpvalue:
+			if operands[0] >= s.numUpvalues {
+				return fail(pos, "upvalue %d doesn't exist", operands[0])
+			}
+
+		case code.OpGetBuiltin:
+			if operands[0] >= len(object.Builtins) {
+				return fail(pos, "builtin %d doesn't exist", operands[0])
+			}
+
+		case code.OpJump, code.OpJumpIfFalse, code.OpIterNext:
+			jumps[pos] = operands[0]
+
+		case code.OpClosure:
+			if operands[0] >= len(constants) {
+				return fail(pos, "constant %d doesn't exist", operands[0])
+			}
+			fn, ok := constants[operands[0]].(*object.CompiledFunction)
+			if !ok {
+				return fail(pos, "OpClosure needs a function, constant %d is %s", operands[0], constants[operands[0]].Type())
+			}
+			for _, u := range fn.Upvalues {
+				if (u.IsLocal && u.Index >= s.numLocals) || (!u.IsLocal && u.Index >= s.numUpvalues) {
+					return fail(pos, "the function captures a variable that doesn't exist")
+				}
+			}
+			if !checked[fn] {
+				checked[fn] = true
+				name := "anonymous function"
+				if fn.Name != "" {
+					name = "function " + fn.Name
+				}
+				inner := scope{name: name, instructions: fn.Instructions, numLocals: fn.NumLocals, numUpvalues: len(fn.Upvalues)}
+				if err := validateScope(inner, constants, checked); err != nil {
+					return err
+				}
+			}
+		}
+
+		pos += 1 + width
+	}
+
+	if last == -1 || (code.Opcode(ins[last]) != code.OpReturn && code.Opcode(ins[last]) != code.OpReturnValue) {
+		return fail(len(ins), "missing return")
+	}
+	for pos, target := range jumps {
+		if !starts[target] {
+			return fail(pos, "jump to %d isn't the start of an instruction", target)
+		}
+	}
+
+	return nil
+}
+
+func validUpdateOperator(op int) bool {
+	switch code.Opcode(op) {
+	case 0, code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,247 @@
+package sqdc
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"../code"
+	"../compiler"
+	"../lexer"
+	"../object"
+	"../parser"
+	"../vm"
+)
+
+const program = `struct Point { int x; int y }
+enum Month { Jan, Feb }
+
+func adder(int x) returns func(int) returns int {
+  return func(int y) returns int { return x + y; };
+}
+
+let Point p := Point{x: 1, y: 2};
+let func(int) returns int addTwo := adder(2);
+let float half := 0.5;
+let string name := "squid";
+key (Month.Feb) { lock Month.Jan { 0 } lock Month.Feb { addTwo(p.x + p.y) + int(half * 2.0) + len(name) } }`
+
+func TestRoundTrip(t *testing.T) {
+	bytecode := compile(t, program)
+
+	for _, debug := range []bool{true, false} {
+		data, err := Marshal(bytecode, debug)
+		if err != nil {
+			t.Fatalf("Marshal failed: %s", err)
+		}
+
+		loaded, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("Unmarshal failed: %s", err)
+		}
+
+		if loaded.Instructions.String() != bytecode.Instructions.String() || loaded.NumLocals != bytecode.NumLocals {
+			t.Errorf("main changed.\nwant=%q\ngot =%q", bytecode.Instructions, loaded.Instructions)
+		}
+		if len(loaded.Constants) != len(bytecode.Constants) {
+			t.Fatalf("wrong number of constants. want=%d, got=%d", len(bytecode.Constants), len(loaded.Constants))
+		}
+		for i, c := range bytecode.Constants {
+			if loaded.Constants[i].Type() != c.Type() || loaded.Constants[i].Inspect() != c.Inspect() {
+				t.Errorf("constant %d changed. want=%s, got=%s", i, c.Inspect(), loaded.Constants[i].Inspect())
+			}
+		}
+
+		if debug {
+			if loaded.Source != "program.sqd" || len(loaded.Lines) == 0 || loaded.Lines.Line(len(loaded.Instructions)-1) != 12 {
+				t.Errorf("debug section wasn't kept. source=%q, lines=%v", loaded.Source, loaded.Lines)
+			}
+		} else if loaded.Source != "" || loaded.Lines != nil {
+			t.Errorf("debug section should have been left out. source=%q, lines=%v", loaded.Source, loaded.Lines)
+		}
+
+		machine := vm.New(loaded)
+		if err := machine.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		if result := machine.Result(); result == nil || result.Inspect() != "11" {
+			t.Errorf("loaded program gave the wrong result. want=11, got=%v", result)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	data, err := Marshal(compile(t, program), true)
+	if err != nil {
+		t.Fatalf("Marshal failed: %s", err)
+	}
+
+	flipped := append([]byte{}, data...)
+	flipped[len(flipped)/2] ^= 0xFF
+
+	newer := append([]byte{}, data...)
+	binary.BigEndian.PutUint16(newer[len(Magic):], Version+1)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"empty", []byte{}, "not a .sqdc file"},
+		{"source", []byte("let int x := 1;"), "not a .sqdc file"},
+		{"version", newer, "unsupported .sqdc version 2, this build reads version 1"},
+		{"truncated header", data[:6], "corrupted .sqdc file: the file is too short"},
+		{"truncated", data[:len(data)-10], "corrupted .sqdc file: the checksum doesn't match"},
+		{"flipped byte", flipped, "corrupted .sqdc file: the checksum doesn't match"},
+		{"truncated body", withChecksum(data[:40]), "corrupted .sqdc file: unexpected end of file"},
+		{"trailing bytes", withChecksum(append(append([]byte{}, data[:len(data)-4]...), 0)), "corrupted .sqdc file: 1 bytes left over"},
+	}
+
+	for _, tt := range tests {
+		_, err := Unmarshal(tt.data)
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tt.expected) {
+			t.Errorf("%s: wrong error. expected=%q, got=%q", tt.name, tt.expected, err.Error())
+		}
+	}
+}
+
+//files whose checksum is fine but whose instructions refer to things that don't exist
+func TestValidation(t *testing.T) {
+	fn := &object.CompiledFunction{Instructions: concat(code.Make(code.OpGetUpvalue, 0), code.Make(code.OpReturnValue)), Upvalues: []object.UpvalueRef{{IsLocal: true, Index: 3}}}
+
+	tests := []struct {
+		name     string
+		bytecode *compiler.Bytecode
+		expected string
+	}{
+		{
+			"constant",
+			&compiler.Bytecode{Instructions: concat(code.Make(code.OpConstant, 1), code.Make(code.OpReturnValue)), Constants: []object.Object{&object.Integer{Value: 1}}},
+			"corrupted .sqdc file: constant 1 doesn't exist at 0 in main",
+		},
+		{
+			"opcode",
+			&compiler.Bytecode{Instructions: code.Instructions{255}},
+			"corrupted .sqdc file: opcode 255 undefined at 0 in main",
+		},
+		{
+			"operands",
+			&compiler.Bytecode{Instructions: code.Make(code.OpConstant, 0)[:2]},
+			"corrupted .sqdc file: OpConstant is missing operands at 0 in main",
+		},
+		{
+			"local",
+			&compiler.Bytecode{Instructions: concat(code.Make(code.OpGetLocal, 0), code.Make(code.OpReturnValue))},
+			"corrupted .sqdc file: local 0 doesn't exist at 0 in main",
+		},
+		{
+			"jump",
+			&compiler.Bytecode{Instructions: concat(code.Make(code.OpJump, 1), code.Make(code.OpReturn))},
+			"corrupted .sqdc file: jump to 1 isn't the start of an instruction at 0 in main",
+		},
+		{
+			"return",
+			&compiler.Bytecode{Instructions: code.Make(code.OpNull)},
+			"corrupted .sqdc file: missing return at 1 in main",
+		},
+		{
+			"upvalue",
+			&compiler.Bytecode{Instructions: concat(code.Make(code.OpClosure, 0), code.Make(code.OpReturnValue)), Constants: []object.Object{fn}},
+			"corrupted .sqdc file: the function captures a variable that doesn't exist at 0 in main",
+		},
+	}
+
+	for _, tt := range tests {
+		data, err := Marshal(tt.bytecode, false)
+		if err != nil {
+			t.Fatalf("%s: Marshal failed: %s", tt.name, err)
+		}
+
+		_, err = Unmarshal(data)
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%s: wrong error. expected=%q, got=%q", tt.name, tt.expected, err.Error())
+		}
+	}
+}
+
+//files that get past the loader but could never have come out of the compiler. The vm has to fail on them with an error, not crash
+func TestBrokenBytecode(t *testing.T) {
+	mutated := compile(t, program)
+	for pos := 0; pos < len(mutated.Instructions); pos++ { //calls adder with 200 arguments that aren't on the stack
+		if code.Opcode(mutated.Instructions[pos]) == code.OpCall {
+			mutated.Instructions[pos+1] = 200
+			break
+		}
+	}
+
+	tests := []struct {
+		name     string
+		bytecode *compiler.Bytecode
+	}{
+		{"mutated call", mutated},
+		{"underflow", &compiler.Bytecode{Instructions: concat(code.Make(code.OpAdd), code.Make(code.OpReturnValue))}},
+		{"unset global", &compiler.Bytecode{Instructions: concat(code.Make(code.OpGetGlobal, 5), code.Make(code.OpCall, 0), code.Make(code.OpReturnValue))}},
+		{"not an iterator", &compiler.Bytecode{Instructions: concat(code.Make(code.OpTrue), code.Make(code.OpIterNext, 0), code.Make(code.OpReturn))}},
+	}
+
+	for _, tt := range tests {
+		data, err := Marshal(tt.bytecode, false)
+		if err != nil {
+			t.Fatalf("%s: Marshal failed: %s", tt.name, err)
+		}
+		loaded, err := Unmarshal(data)
+		if err != nil {
+			continue //refusing to load it is fine too
+		}
+
+		err = vm.New(loaded).Run()
+		if err == nil || !strings.HasPrefix(err.Error(), "broken bytecode: ") {
+			t.Errorf("%s: wrong error. got=%v", tt.name, err)
+		}
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	bytecode := &compiler.Bytecode{Instructions: code.Make(code.OpReturn), Constants: []object.Object{&object.Null{}}}
+
+	_, err := Marshal(bytecode, false)
+	if err == nil || err.Error() != "cannot write constant 0, NULL isn't a constant the .sqdc format knows" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func compile(t *testing.T, input string) *compiler.Bytecode {
+	p := parser.New(lexer.NewFile("program.sqd", input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return c.Bytecode()
+}
+
+//adds the right checksum to body, so the test gets past the checksum to what it is testing
+func withChecksum(body []byte) []byte {
+	return binary.BigEndian.AppendUint32(append([]byte{}, body...), crc32.ChecksumIEEE(body))
+}
+
+func concat(instructions ...[]byte) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range instructions {
+		out = append(out, ins...)
+	}
+	return out
+}
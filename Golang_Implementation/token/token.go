@@ -1,6 +1,8 @@
 //OVERVIEW: Tokenizer gives value to certain words and characters, defines keywords, operators, special characters, etc.
 package token
 
+import "fmt"
+
 type TokenType string
 
 const ( //these are our token types
@@ -8,53 +10,156 @@ const ( //these are our token types
 	EOF     = "EOF"
 
 	// Identifiers + literals
-	IDENT = "IDENT" // user given input that does not match any pre-defined operators or keywords, (ie variable labels, strings, etc)
-	INT   = "INT"   // 1343456
+	IDENT  = "IDENT"  // user given input that does not match any pre-defined operators or keywords, (ie variable labels, strings, etc)
+	INT    = "INT"    // 1343456, 0xFF, 0b1010 or 1_000_000
+	FLOAT  = "FLOAT"  // 3.14 or 6.02e23
+	STRING = "STRING" // "hello ${name}" (the literal is the source of the string, quotes included)
 
 	// Operators
-	ASSIGN   = "="
 	PLUS     = "+"
 	MINUS    = "-"
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%" // the remainder of an integer division (ie 7 % 2 is 1)
+
+	WALRUS          = ":=" // declares and initializes a variable (ie let int x := 3), or assigns to it (ie set x := 3)
+	PLUS_ASSIGN     = ":+" // set x :+ 3 is the same as set x := x + 3
+	MINUS_ASSIGN    = ":-" // set x :- 3 is the same as set x := x - 3
+	ASTERISK_ASSIGN = ":*" // set x :* 3 is the same as set x := x * 3
+	SLASH_ASSIGN    = ":/" // set x :/ 3 is the same as set x := x / 3
 
-	LT = "<"
-	GT = ">"
+	LT    = "<"
+	GT    = ">"
+	LT_EQ = "<="
+	GT_EQ = ">="
 
-	EQ     = "=="
+	EQ     = "=" // x = y compares x and y, since := is what assigns. == isn't an operator, but the lexer reads it as = so it can point at the fix
 	NOT_EQ = "!="
 
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"  // between a key and its value in a hash literal (ie {"a": 1})
+	DOTDOT    = ".." // ranges (ie 1..100)
+	DOT       = "."  // the field of a struct (ie p.x)
 
 	LPAREN = "("
 	RPAREN = ")"
 	LBRACE = "{"
 	RBRACE = "}"
 
+	LBRACKET = "[" // array literals (ie [1, 2, 3]), indexing (ie xs[0]) and array types (ie int[])
+	RBRACKET = "]"
+
 	// Keywords
-	LET    = "LET"
-	TRUE   = "TRUE"
-	FALSE  = "FALSE"
-	IF     = "IF"
-	ELSE   = "ELSE"
-	RETURN = "RETURN"
+	LET      = "LET"
+	SET      = "SET"
+	TRUE     = "TRUE"
+	FALSE    = "FALSE"
+	IF       = "IF"
+	ELSE     = "ELSE"
+	RETURN   = "RETURN"
+	KEY      = "KEY"
+	LOCK     = "LOCK"
+	FOR      = "FOR"
+	IN       = "IN"
+	WHILE    = "WHILE"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	FUNC     = "FUNC"
+	RETURNS  = "RETURNS"
+	MAP      = "MAP"    // map types (ie map[string]int)
+	STRUCT   = "STRUCT" // declares a struct type (ie struct Point { int x; int y })
+	ENUM     = "ENUM"   // declares an enum type (ie enum Month { Jan, Feb, Mar })
+	AND      = "AND"    // a and b is only true if both are, and b is only evaluated if a is true
+	OR       = "OR"     // a or b is true if either is, and b is only evaluated if a is false
 )
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position //where the token starts in the source
+	End     Position //just past the last character of the token
+
+	Comments []Comment //comments between the previous token and this one, kept so a formatter or doc generator can put them back
+}
+
+//Comment is a // line comment or a /* block comment */. Text includes the comment markers
+type Comment struct {
+	Text string
+	Pos  Position
+	End  Position
+}
+
+//Position is a place in the source code. Lines and columns start at 1, offsets start at 0. Columns count characters (runes) and offsets count bytes
+type Position struct {
+	Filename string //empty when the source didn't come from a file (ie the REPL)
+	Line     int
+	Column   int
+	Offset   int
+}
+
+//REQUIRES:
+//MODIFIES:
+//EFFECTS: returns whether the position was set by the lexer (the zero Position isn't anywhere)
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+//REQUIRES: source text that starts at p
+//MODIFIES:
+//EFFECTS: returns the position just past text. Like the lexer, it counts columns in characters and offsets in bytes
+func (p Position) Advance(text string) Position {
+	for _, r := range text {
+		if r == '\n' {
+			p.Line++
+			p.Column = 1
+		} else {
+			p.Column++
+		}
+	}
+	p.Offset += len(text)
+	return p
+}
+
+//REQUIRES:
+//MODIFIES:
+//EFFECTS: returns the position as file:line:column (or line:column without a filename), which is what error messages are prefixed with
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
 }
 
 var keywords = map[string]TokenType{ //this is a hashmap where inputted text may match a keyword, thus requiring the token thereof to have the appropriate keyword token type
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"let":      LET,
+	"set":      SET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"key":      KEY,
+	"lock":     LOCK,
+	"for":      FOR,
+	"in":       IN,
+	"while":    WHILE,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"func":     FUNC,
+	"returns":  RETURNS,
+	"map":      MAP,
+	"struct":   STRUCT,
+	"enum":     ENUM,
+	"and":      AND,
+	"or":       OR,
 }
 
 //REQUIRES: a string input (the string literal of the identifier we are trying to tokenize)
@@ -0,0 +1,177 @@
+//OVERVIEW: Types are what the checker knows about a value before the program ever runs. Every declared type and every inferred expression type is represented by something that fulfills the Type interface
+package types
+
+import (
+	"bytes"
+	"strings"
+)
+
+// The base Type interface
+type Type interface {
+	String() string //how the type is written in SquidScript source (ie 'int' or 'func(int) returns int')
+}
+
+type Basic struct { //the built in types that are referred to by name
+	Name string
+}
+
+func (b *Basic) String() string { return b.Name }
+
+var ( //there is only ever one of each basic type, so they can be compared with ==
+	Int    = &Basic{Name: "int"}
+	Float  = &Basic{Name: "float"}
+	Bool   = &Basic{Name: "bool"}
+	String = &Basic{Name: "string"}
+	Range  = &Basic{Name: "range"} //the type of a..b, which can't be declared but can be looped over
+	Void   = &Basic{Name: "void"}  //what statements and functions without a return type produce
+
+	Invalid = &Basic{Name: "invalid"} //the type of expressions that already had an error, so the error isn't reported over and over
+)
+
+//names of the types that can be written in a declaration (ie the 'int' in 'let int x := 3')
+var universe = map[string]Type{
+	"int":    Int,
+	"float":  Float,
+	"bool":   Bool,
+	"string": String,
+}
+
+//REQUIRES: the name of a type
+//MODIFIES:
+//EFFECTS: returns the built in type with that name, and whether there is one
+func LookupBasic(name string) (Type, bool) {
+	t, ok := universe[name]
+	return t, ok
+}
+
+type Array struct { //the type of an array (ie 'int[]')
+	Element Type //the type every element has
+}
+
+func (a *Array) String() string { return a.Element.String() + "[]" }
+
+type Map struct { //the type of a map (ie 'map[string]int')
+	Key   Type //the type every key has (see IsHashable)
+	Value Type //the type every value has
+}
+
+func (m *Map) String() string { return "map[" + m.Key.String() + "]" + m.Value.String() }
+
+type Struct struct { //a struct type declared in the program (ie 'struct Point { int x; int y }'). Structs are only ever identical to themselves
+	Name   string
+	Fields []Field //in the order they were declared
+}
+
+type Field struct { //a field of a struct
+	Name string
+	Type Type
+}
+
+func (s *Struct) String() string { return s.Name }
+
+//REQUIRES: the name of a field
+//MODIFIES:
+//EFFECTS: returns the type of the field of s with that name, and whether s has one
+func (s *Struct) Field(name string) (Type, bool) {
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
+
+type Enum struct { //an enum type declared in the program (ie 'enum Month { Jan, Feb }'). Like structs, enums are only ever identical to themselves
+	Name     string
+	Variants []string //in the order they were declared
+}
+
+func (e *Enum) String() string { return e.Name }
+
+//REQUIRES: the name of a variant
+//MODIFIES:
+//EFFECTS: returns whether e has a variant with that name
+func (e *Enum) HasVariant(name string) bool {
+	for _, v := range e.Variants {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+type Function struct { //the type of a function value (ie 'func(int, int) returns int')
+	Parameters []Type
+	Return     Type //Void when the function doesn't return anything
+}
+
+func (f *Function) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("func(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	if f.Return != Void {
+		out.WriteString(" returns " + f.Return.String())
+	}
+
+	return out.String()
+}
+
+type Builtin struct { //the type of a builtin function (ie print). Builtins are checked by name, since some of them take any number or type of arguments
+	Name string
+}
+
+func (b *Builtin) String() string { return "builtin " + b.Name }
+
+//REQUIRES: a type
+//MODIFIES:
+//EFFECTS: returns whether t is int or float. Numbers of different types still can't be mixed without converting one of them (ie float(n) * 1.5)
+func IsNumeric(t Type) bool {
+	return t == Int || t == Float
+}
+
+//REQUIRES: a type
+//MODIFIES:
+//EFFECTS: returns whether values of type t can be map keys. Floats can't, since values that print the same aren't always equal
+func IsHashable(t Type) bool {
+	_, isEnum := t.(*Enum)
+	return t == Int || t == String || t == Bool || isEnum
+}
+
+//REQUIRES: two types
+//MODIFIES:
+//EFFECTS: returns whether a and b are the same type. Basic, struct and enum types are the same when they are the same object, array types when their elements are,
+//map types when their keys and values are, function types when their parameters and return types are
+func Identical(a, b Type) bool {
+	if a == b {
+		return true
+	}
+
+	switch a := a.(type) {
+	case *Array:
+		b, ok := b.(*Array)
+		return ok && Identical(a.Element, b.Element)
+	case *Map:
+		b, ok := b.(*Map)
+		return ok && Identical(a.Key, b.Key) && Identical(a.Value, b.Value)
+	case *Function:
+		b, ok := b.(*Function)
+		if !ok || len(a.Parameters) != len(b.Parameters) {
+			return false
+		}
+		for i := range a.Parameters {
+			if !Identical(a.Parameters[i], b.Parameters[i]) {
+				return false
+			}
+		}
+		return Identical(a.Return, b.Return)
+	default:
+		return false
+	}
+}
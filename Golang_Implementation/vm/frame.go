@@ -0,0 +1,24 @@
+package vm
+
+import (
+	"../code"
+	"../object"
+)
+
+//A Frame is a call that is running: the closure being run, where in its instructions we are, and where its locals start on the stack
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int //the first local of the call. The function being called sits right below it
+}
+
+//REQUIRES: a closure and where its locals start on the stack
+//MODIFIES:
+//EFFECTS: returns a frame that starts running the closure from its first instruction
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
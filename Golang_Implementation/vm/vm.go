@@ -0,0 +1,990 @@
+//OVERVIEW: The vm runs the bytecode produced by the compiler. It is a stack machine: instructions pop their operands off the stack and push
+//their results back on. Every call gets a frame, whose locals live on the stack right above the function being called
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+
+	"../code"
+	"../compiler"
+	"../object"
+)
+
+const StackSize = 16 * object.MaxCallDepth //room for the deepest calls the evaluator allows, with a few locals and operands each
+const GlobalsSize = 65536                  //OpGetGlobal and OpSetGlobal have two bytes for the index
+const MaxFrames = object.MaxCallDepth + 1  //the program itself runs in a frame too
+
+var ( //like in the evaluator there is only ever one true, one false and one null
+	True  = &object.Boolean{Value: true}
+	False = &object.Boolean{Value: false}
+	Null  = &object.Null{}
+)
+
+//This is what is constructed; the main template/structure of the vm
+type VM struct {
+	constants []object.Object
+	globals   []object.Object
+
+	stack [StackSize]object.Object //an array rather than a slice, so the upvalues pointing into it stay valid
+	sp    int                      //always points at the next free slot, the top of the stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	openUpvalues []*openUpvalue //the upvalues whose variables are still on the stack
+	result       object.Object  //what the program ended with, nil if it didn't end with a value
+}
+
+//an upvalue that still points at its slot on the stack
+type openUpvalue struct {
+	slot    int
+	upvalue *object.Upvalue
+}
+
+//REQUIRES: the bytecode of a program
+//MODIFIES:
+//EFFECTS: creates a vm that runs the program in a frame of its own, with room for the locals of its blocks
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, NumLocals: bytecode.NumLocals, Lines: bytecode.Lines}
+	mainFrame := NewFrame(&object.Closure{Fn: mainFn}, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     make([]object.Object, GlobalsSize),
+		sp:          bytecode.NumLocals,
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+//REQUIRES: a program that has been Run
+//MODIFIES:
+//EFFECTS: returns the value the program ended with (the value of its last statement, like the evaluator), or nil if it doesn't have one
+func (vm *VM) Result() object.Object {
+	return vm.result
+}
+
+//REQUIRES:
+//MODIFIES:
+//EFFECTS: returns the line of the source the running instruction was compiled from (after Run returned an error, the instruction that
+//failed), or 0 if the bytecode doesn't say
+func (vm *VM) Line() int {
+	frame := vm.currentFrame()
+	return frame.cl.Fn.Lines.Line(frame.ip)
+}
+
+//REQUIRES:
+//MODIFIES: the vm, and whatever the program changes
+//EFFECTS: runs the program until it ends, returning an error (with the same message the evaluator would give) if something goes wrong.
+//Bytecode the compiler would never produce (ie a .sqdc file that was tampered with) can still get past the loader, what it makes the
+//vm trip over is handed back as an error as well instead of crashing
+func (vm *VM) Run() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			rerr, ok := r.(runtime.Error) //anything else is a bug in the vm itself
+			if !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("broken bytecode: %s", rerr)
+		}
+	}()
+
+	return vm.run()
+}
+
+func (vm *VM) run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		var err error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.push(vm.constants[constIndex])
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpNull:
+			err = vm.push(Null)
+
+		case code.OpTrue:
+			err = vm.push(True)
+
+		case code.OpFalse:
+			err = vm.push(False)
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpEqual, code.OpNotEqual, code.OpLessThan, code.OpGreaterThan, code.OpLessEqual, code.OpGreaterEqual:
+			right := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(executeBinaryOperation(op, left, right))
+
+		case code.OpMinus:
+			err = vm.pushResult(executeMinusOperator(vm.pop()))
+
+		case code.OpBang:
+			operand := vm.pop()
+			if operand.Type() != object.BOOLEAN_OBJ { //we are explicitly typed, so ! only makes sense on booleans
+				return fmt.Errorf("unknown operator: !%s", operand.Type())
+			}
+			err = vm.push(nativeBoolToBooleanObject(operand != True))
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1 //the loop moves ip forward before reading the next instruction
+
+		case code.OpJumpIfFalse:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if condition.Type() != object.BOOLEAN_OBJ { //no truthiness, the condition has to actually be a bool
+				return fmt.Errorf("condition must be BOOLEAN, got %s", condition.Type())
+			}
+			if condition == False {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.push(vm.globals[globalIndex])
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.push(vm.stack[vm.currentFrame().basePointer+int(localIndex)])
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			vm.stack[vm.currentFrame().basePointer+int(localIndex)] = vm.pop()
+
+		case code.OpGetUpvalue:
+			upvalueIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.push(*vm.currentFrame().cl.Upvalues[upvalueIndex].Location)
+
+		case code.OpSetUpvalue:
+			upvalueIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			*vm.currentFrame().cl.Upvalues[upvalueIndex].Location = vm.pop()
+
+		case code.OpCloseUpvalues:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			vm.closeUpvalues(vm.currentFrame().basePointer + int(localIndex))
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.push(object.Builtins[builtinIndex])
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.pushClosure(int(constIndex))
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.executeCall(int(numArgs))
+
+		case code.OpReturnValue:
+			if vm.returnFromFrame(vm.pop()) { //the program itself returned
+				return nil
+			}
+
+		case code.OpReturn:
+			if vm.returnFromFrame(nil) {
+				return nil
+			}
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp -= numElements
+			err = vm.push(&object.Array{Elements: elements})
+
+		case code.OpHash:
+			numPairs := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			m, hashErr := vm.buildMap(vm.sp-2*numPairs, vm.sp)
+			if hashErr != nil {
+				return hashErr
+			}
+			vm.sp -= 2 * numPairs
+			err = vm.push(m)
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(executeIndexExpression(left, index))
+
+		case code.OpSetIndex:
+			operator := code.Opcode(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			val := vm.pop()
+			index := vm.pop()
+			container := vm.pop()
+			err = executeSetIndex(container, index, val, operator)
+
+		case code.OpRange:
+			last := vm.pop()
+			first := vm.pop()
+			if first.Type() != object.INTEGER_OBJ || last.Type() != object.INTEGER_OBJ { //ranges are only made up of integers
+				return fmt.Errorf("range bounds must be INTEGER, got %s..%s", first.Type(), last.Type())
+			}
+			err = vm.push(&object.Range{Start: first.(*object.Integer).Value, End: last.(*object.Integer).Value})
+
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			var out bytes.Buffer
+			for _, part := range vm.stack[vm.sp-numParts : vm.sp] { //written the way print writes them
+				out.WriteString(part.Inspect())
+			}
+			vm.sp -= numParts
+			err = vm.push(&object.String{Value: out.String()})
+
+		case code.OpStruct:
+			numFields := int(code.ReadUint8(ins[ip+3:]))
+			vm.currentFrame().ip += 3
+
+			s, structErr := vm.buildStruct(numFields)
+			if structErr != nil {
+				return structErr
+			}
+			err = vm.push(s)
+
+		case code.OpGetField:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			field := vm.constants[constIndex].(*object.String).Value
+			err = vm.pushResult(executeSelectorExpression(vm.pop(), field))
+
+		case code.OpSetField:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			operator := code.Opcode(code.ReadUint8(ins[ip+3:]))
+			vm.currentFrame().ip += 3
+
+			field := vm.constants[constIndex].(*object.String).Value
+			val := vm.pop()
+			left := vm.pop()
+			err = executeSetField(left, field, val, operator)
+
+		case code.OpMatch:
+			numKeys := int(code.ReadUint8(ins[ip+1:]))
+			numValues := int(code.ReadUint8(ins[ip+2:]))
+			vm.currentFrame().ip += 2
+
+			values := vm.stack[vm.sp-numValues : vm.sp]
+			keys := vm.stack[vm.sp-numValues-numKeys : vm.sp-numValues]
+			unlocked, matchErr := executeMatch(keys, values)
+			if matchErr != nil {
+				return matchErr
+			}
+			vm.sp -= numKeys + numValues
+			err = vm.push(nativeBoolToBooleanObject(unlocked))
+
+		case code.OpIter:
+			variables := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			it, iterErr := newIterator(vm.pop(), variables)
+			if iterErr != nil {
+				return iterErr
+			}
+			err = vm.push(it)
+
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			it := vm.pop().(*iterator)
+			key, element, ok := it.next()
+			if !ok { //nothing left to loop over
+				vm.currentFrame().ip = pos - 1
+				break
+			}
+			switch {
+			case it.variables == 2: //for i, x in xs or for k, v in m
+				err = vm.push(key)
+				if err == nil {
+					err = vm.push(element)
+				}
+			case it.iterable.Type() == object.MAP_OBJ: //a single variable goes over the keys of a map
+				err = vm.push(key)
+			default:
+				err = vm.push(element)
+			}
+
+		default:
+			def, lookupErr := code.Lookup(byte(op))
+			if lookupErr != nil {
+				return lookupErr
+			}
+			return fmt.Errorf("opcode %s not supported", def.Name)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Stack and frames
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+//pushes the result of an operation, unless it is an error, which is handed back instead so the vm stops
+func (vm *VM) pushResult(o object.Object) error {
+	if err, ok := o.(*object.Error); ok {
+		return fmt.Errorf("%s", err.Message)
+	}
+	return vm.push(o)
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return fmt.Errorf("stack overflow")
+	}
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+//REQUIRES: the value being returned (nil if there is none)
+//MODIFIES: the frames and the stack of the vm
+//EFFECTS: ends the running call, closing its locals, and pushes what it returned (null if nothing) for the caller. Returns true when the
+//call was the program itself, which is then over and keeps the value as its result
+func (vm *VM) returnFromFrame(value object.Object) bool {
+	if vm.framesIndex == 1 {
+		vm.result = value
+		return true
+	}
+
+	frame := vm.popFrame()
+	vm.closeUpvalues(frame.basePointer)
+	vm.sp = frame.basePointer - 1 //the function that was called goes too
+
+	if value == nil { //we only hand values back through return
+		value = Null
+	}
+	vm.push(value) //can't overflow, the function was in this slot
+	return false
+}
+
+//REQUIRES: how many arguments were pushed after the function being called
+//MODIFIES: the frames and the stack of the vm
+//EFFECTS: starts running a closure in a new frame, or calls a builtin and pushes what it returned
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		if numArgs != callee.Fn.NumParameters {
+			return fmt.Errorf("wrong number of arguments to %s: want=%d, got=%d",
+				functionName(callee.Fn), callee.Fn.NumParameters, numArgs)
+		}
+
+		basePointer := vm.sp - numArgs //the arguments are the first locals
+		if basePointer+callee.Fn.NumLocals >= StackSize {
+			return fmt.Errorf("stack overflow")
+		}
+		if err := vm.pushFrame(NewFrame(callee, basePointer)); err != nil {
+			return err
+		}
+		vm.sp = basePointer + callee.Fn.NumLocals
+		return nil
+
+	case *object.Builtin:
+		args := vm.stack[vm.sp-numArgs : vm.sp]
+		result := callee.Fn(args...)
+		vm.sp = vm.sp - numArgs - 1
+
+		if result == nil { //the builtin didn't have anything to hand back (ie print)
+			result = Null
+		}
+		return vm.pushResult(result)
+
+	default:
+		return fmt.Errorf("not a function: %s", callee.Type())
+	}
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Closures
+
+//REQUIRES: the constant index of a compiled function
+//MODIFIES: the stack of the vm
+//EFFECTS: pushes a closure over the function, capturing the variables it uses from the function running now
+func (vm *VM) pushClosure(constIndex int) error {
+	fn, ok := vm.constants[constIndex].(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %s", vm.constants[constIndex].Type())
+	}
+
+	frame := vm.currentFrame()
+	upvalues := make([]*object.Upvalue, len(fn.Upvalues))
+	for i, ref := range fn.Upvalues {
+		if ref.IsLocal {
+			upvalues[i] = vm.captureUpvalue(frame.basePointer + ref.Index)
+		} else { //the running closure captured it already, so they share it
+			upvalues[i] = frame.cl.Upvalues[ref.Index]
+		}
+	}
+
+	return vm.push(&object.Closure{Fn: fn, Upvalues: upvalues})
+}
+
+//returns the upvalue pointing at the slot, creating it if no closure captured the slot yet, so that closures capturing the same
+//variable share it
+func (vm *VM) captureUpvalue(slot int) *object.Upvalue {
+	for _, open := range vm.openUpvalues {
+		if open.slot == slot {
+			return open.upvalue
+		}
+	}
+
+	upvalue := &object.Upvalue{Location: &vm.stack[slot]}
+	vm.openUpvalues = append(vm.openUpvalues, &openUpvalue{slot: slot, upvalue: upvalue})
+	return upvalue
+}
+
+//REQUIRES: the first slot of the locals going out of scope
+//MODIFIES: the open upvalues of the vm
+//EFFECTS: moves the value of every captured variable from that slot up off of the stack and into its upvalue, so the slot can be used
+//again without changing what the closures see
+func (vm *VM) closeUpvalues(from int) {
+	open := vm.openUpvalues[:0]
+	for _, o := range vm.openUpvalues {
+		if o.slot < from {
+			open = append(open, o)
+			continue
+		}
+		o.upvalue.Closed = *o.upvalue.Location
+		o.upvalue.Location = &o.upvalue.Closed
+	}
+	vm.openUpvalues = open
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Operators
+
+//the infix operator of each opcode, for error messages
+var operators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpEqual:        "=",
+	code.OpNotEqual:     "!=",
+	code.OpLessThan:     "<",
+	code.OpGreaterThan:  ">",
+	code.OpLessEqual:    "<=",
+	code.OpGreaterEqual: ">=",
+}
+
+//REQUIRES: the opcode of an infix operator and its operands
+//MODIFIES:
+//EFFECTS: returns the result of the operator, or an *object.Error if it can't be applied. There are no implicit conversions between types
+func executeBinaryOperation(op code.Opcode, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return executeIntegerOperation(op, left.(*object.Integer).Value, right.(*object.Integer).Value)
+	case left.Type() == object.FLOAT_OBJ && right.Type() == object.FLOAT_OBJ:
+		return executeFloatOperation(op, left.(*object.Float).Value, right.(*object.Float).Value)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return executeStringOperation(op, left.(*object.String).Value, right.(*object.String).Value)
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), operators[op], right.Type())
+	case op == code.OpEqual:
+		return nativeBoolToBooleanObject(left == right) //booleans are singletons and enum variants are unique, so pointer comparison is enough
+	case op == code.OpNotEqual:
+		return nativeBoolToBooleanObject(left != right)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
+	}
+}
+
+func executeIntegerOperation(op code.Opcode, left, right int64) object.Object {
+	switch op {
+	case code.OpAdd:
+		return &object.Integer{Value: left + right}
+	case code.OpSub:
+		return &object.Integer{Value: left - right}
+	case code.OpMul:
+		return &object.Integer{Value: left * right}
+	case code.OpDiv:
+		if right == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: left / right}
+	case code.OpMod:
+		if right == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: left % right}
+	case code.OpLessThan:
+		return nativeBoolToBooleanObject(left < right)
+	case code.OpGreaterThan:
+		return nativeBoolToBooleanObject(left > right)
+	case code.OpLessEqual:
+		return nativeBoolToBooleanObject(left <= right)
+	case code.OpGreaterEqual:
+		return nativeBoolToBooleanObject(left >= right)
+	case code.OpEqual:
+		return nativeBoolToBooleanObject(left == right)
+	default: //OpNotEqual
+		return nativeBoolToBooleanObject(left != right)
+	}
+}
+
+func executeFloatOperation(op code.Opcode, left, right float64) object.Object {
+	switch op {
+	case code.OpAdd:
+		return &object.Float{Value: left + right}
+	case code.OpSub:
+		return &object.Float{Value: left - right}
+	case code.OpMul:
+		return &object.Float{Value: left * right}
+	case code.OpDiv:
+		if right == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: left / right}
+	case code.OpLessThan:
+		return nativeBoolToBooleanObject(left < right)
+	case code.OpGreaterThan:
+		return nativeBoolToBooleanObject(left > right)
+	case code.OpLessEqual:
+		return nativeBoolToBooleanObject(left <= right)
+	case code.OpGreaterEqual:
+		return nativeBoolToBooleanObject(left >= right)
+	case code.OpEqual:
+		return nativeBoolToBooleanObject(left == right)
+	case code.OpNotEqual:
+		return nativeBoolToBooleanObject(left != right)
+	default:
+		return newError("unknown operator: %s %s %s", object.FLOAT_OBJ, operators[op], object.FLOAT_OBJ)
+	}
+}
+
+func executeStringOperation(op code.Opcode, left, right string) object.Object {
+	switch op {
+	case code.OpAdd:
+		return &object.String{Value: left + right}
+	case code.OpEqual:
+		return nativeBoolToBooleanObject(left == right)
+	case code.OpNotEqual:
+		return nativeBoolToBooleanObject(left != right)
+	default:
+		return newError("unknown operator: %s %s %s", object.STRING_OBJ, operators[op], object.STRING_OBJ)
+	}
+}
+
+func executeMinusOperator(operand object.Object) object.Object {
+	switch operand := operand.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -operand.Value}
+	case *object.Float:
+		return &object.Float{Value: -operand.Value}
+	default:
+		return newError("unknown operator: -%s", operand.Type())
+	}
+}
+
+//REQUIRES: the keys of a key statement and the values of one of its locks
+//MODIFIES:
+//EFFECTS: returns whether the lock unlocks, the same way the evaluator decides it: a BOOLEAN value fits a non BOOLEAN key when it is true,
+//any other value fits when it equals the key, and a single value over several keys is a condition
+func executeMatch(keys, values []object.Object) (bool, error) {
+	if len(values) == 1 && len(keys) > 1 { //a single condition tested against all of the keys
+		if values[0].Type() != object.BOOLEAN_OBJ {
+			return false, fmt.Errorf("lock condition must be BOOLEAN, got %s", values[0].Type())
+		}
+		return values[0] == True, nil
+	}
+
+	for i, value := range values {
+		if value.Type() == object.BOOLEAN_OBJ && keys[i].Type() != object.BOOLEAN_OBJ { //a condition in the position of this key
+			if value != True {
+				return false, nil
+			}
+			continue
+		}
+		if value.Type() != keys[i].Type() {
+			return false, fmt.Errorf("type mismatch: lock value %s does not match key %s", value.Type(), keys[i].Type())
+		}
+		if !objectsEqual(value, keys[i]) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Arrays, maps and structs
+
+//builds a map out of the key value pairs on the stack between start and end. A key written twice keeps the last value given for it
+func (vm *VM) buildMap(start, end int) (object.Object, error) {
+	m := object.NewMap()
+
+	for i := start; i < end; i += 2 {
+		key, ok := vm.stack[i].(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("unusable as map key: %s", vm.stack[i].Type())
+		}
+		m.Set(key, vm.stack[i+1])
+	}
+
+	return m, nil
+}
+
+//REQUIRES: the struct type, and a name and a value for each field, on top of the stack
+//MODIFIES: the stack of the vm
+//EFFECTS: pops them and returns the struct. Every field of the struct has to be given a value exactly once
+func (vm *VM) buildStruct(numFields int) (object.Object, error) {
+	start := vm.sp - 2*numFields
+	typ := vm.stack[start-1]
+	vm.sp = start - 1
+
+	st, ok := typ.(*object.StructType)
+	if !ok {
+		return nil, fmt.Errorf("not a struct type: %s", typ.Inspect())
+	}
+
+	s := &object.Struct{StructType: st, Fields: make(map[string]object.Object)}
+	for i := start; i < start+2*numFields; i += 2 {
+		name := vm.stack[i].(*object.String).Value
+		if !st.HasField(name) {
+			return nil, fmt.Errorf("unknown field %s in struct %s", name, st.Name)
+		}
+		if _, ok := s.Fields[name]; ok {
+			return nil, fmt.Errorf("duplicate field %s in struct literal", name)
+		}
+		s.Fields[name] = vm.stack[i+1]
+	}
+
+	for _, f := range st.Fields {
+		if _, ok := s.Fields[f.Name]; !ok {
+			return nil, fmt.Errorf("missing field %s in %s literal", f.Name, st.Name)
+		}
+	}
+
+	return s, nil
+}
+
+//returns the element of an array or the value of a map the index points at. A RANGE index into an array is a slice of it
+func executeIndexExpression(left, index object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		length := int64(len(left.Elements))
+
+		switch index := index.(type) {
+		case *object.Integer:
+			if index.Value < 0 || index.Value >= length {
+				return newError("index out of range [%d] with length %d", index.Value, length)
+			}
+			return left.Elements[index.Value]
+		case *object.Range:
+			if index.Start < 0 || index.Start > length || index.End < index.Start-1 || index.End >= length { //xs[i..i-1] is an empty slice
+				return newError("slice bounds out of range [%d..%d] with length %d", index.Start, index.End, length)
+			}
+			elements := make([]object.Object, index.End-index.Start+1)
+			copy(elements, left.Elements[index.Start:index.End+1]) //a copy, so the slice and the array don't share elements
+			return &object.Array{Elements: elements}
+		default:
+			return newError("array index must be INTEGER or RANGE, got %s", index.Type())
+		}
+
+	case *object.Map:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as map key: %s", index.Type())
+		}
+		value, ok := left.Get(key)
+		if !ok {
+			return newError("key not found: %s", key.Inspect())
+		}
+		return value
+
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+//REQUIRES: an array or map, an index into it, the value being stored and the update operator of the set statement (see code.UpdateOperator)
+//MODIFIES: the array or map
+//EFFECTS: stores the value in the element the index points at, which keeps the type it had. Setting a key that isn't in a map yet adds it
+func executeSetIndex(container, index, val object.Object, operator code.Opcode) error {
+	if operator != 0 { //set xs[0] :+ 3 is set xs[0] := xs[0] + 3
+		current := executeIndexExpression(container, index)
+		if err, ok := current.(*object.Error); ok {
+			return fmt.Errorf("%s", err.Message)
+		}
+		val = executeBinaryOperation(operator, current, val)
+		if err, ok := val.(*object.Error); ok {
+			return fmt.Errorf("%s", err.Message)
+		}
+	}
+
+	switch container := container.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok { //this includes ranges, a slice can't be set
+			return fmt.Errorf("cannot set element %s of an array, the index must be INTEGER", index.Inspect())
+		}
+		if i.Value < 0 || i.Value >= int64(len(container.Elements)) {
+			return fmt.Errorf("index out of range [%d] with length %d", i.Value, len(container.Elements))
+		}
+		if current := container.Elements[i.Value]; current.Type() != val.Type() {
+			return fmt.Errorf("type mismatch: cannot set %s element to %s", current.Type(), val.Type())
+		}
+		container.Elements[i.Value] = val
+	case *object.Map:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return fmt.Errorf("unusable as map key: %s", index.Type())
+		}
+		if current, ok := container.Get(key); ok && current.Type() != val.Type() {
+			return fmt.Errorf("type mismatch: cannot set %s value to %s", current.Type(), val.Type())
+		}
+		container.Set(key, val)
+	default:
+		return fmt.Errorf("index operator not supported: %s", container.Type())
+	}
+
+	return nil
+}
+
+//returns the value of the field of a struct with the given name, or the variant of an enum type (ie Month.Feb)
+func executeSelectorExpression(left object.Object, field string) object.Object {
+	switch left := left.(type) {
+	case *object.Struct:
+		value, ok := left.Fields[field]
+		if !ok {
+			return newError("unknown field %s in struct %s", field, left.StructType.Name)
+		}
+		return value
+	case *object.EnumType:
+		variant, ok := left.Variant(field)
+		if !ok {
+			return newError("unknown variant %s in enum %s", field, left.Name)
+		}
+		return variant
+	default:
+		return newError("field access not supported: %s", left.Type())
+	}
+}
+
+//REQUIRES: a struct, the name of one of its fields, the value being stored and the update operator of the set statement
+//MODIFIES: the struct
+//EFFECTS: stores the value in the field, which keeps the type it had
+func executeSetField(left object.Object, field string, val object.Object, operator code.Opcode) error {
+	s, ok := left.(*object.Struct)
+	if !ok { //this includes enum types, their variants can't be changed
+		return fmt.Errorf("cannot set field %s of %s", field, left.Type())
+	}
+	current := executeSelectorExpression(s, field)
+	if err, ok := current.(*object.Error); ok {
+		return fmt.Errorf("%s", err.Message)
+	}
+
+	if operator != 0 { //set p.x :+ 3 is set p.x := p.x + 3
+		val = executeBinaryOperation(operator, current, val)
+		if err, ok := val.(*object.Error); ok {
+			return fmt.Errorf("%s", err.Message)
+		}
+	}
+
+	if val.Type() != current.Type() {
+		return fmt.Errorf("type mismatch: cannot set %s field %s to %s", current.Type(), field, val.Type())
+	}
+
+	s.Fields[field] = val
+	return nil
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% Iterators
+
+const ITERATOR_OBJ = "ITERATOR"
+
+//an iterator is what a for in loop keeps in a hidden local while it runs. It never ends up anywhere the program can see it
+type iterator struct {
+	iterable  object.Object
+	variables int //how many loop variables the loop has
+
+	position int64 //how many elements have been visited (for ranges, the next integer)
+	done     bool
+	runes    []rune           //the characters of a string
+	keys     []object.HashKey //the keys a map had when the loop started, keys added by the body aren't visited
+}
+
+func (it *iterator) Type() object.ObjectType { return ITERATOR_OBJ }
+func (it *iterator) Inspect() string         { return "iterator over " + it.iterable.Inspect() }
+
+//REQUIRES: what a for in loop goes over and how many loop variables it has
+//MODIFIES:
+//EFFECTS: returns an iterator over it, or an error if it can't be looped over (with that many variables)
+func newIterator(iterable object.Object, variables int) (*iterator, error) {
+	it := &iterator{iterable: iterable, variables: variables}
+
+	switch iterable := iterable.(type) {
+	case *object.Range:
+		if variables == 2 {
+			break
+		}
+		it.position = iterable.Start
+		it.done = iterable.Start > iterable.End //if start is past end there is nothing to loop over
+		return it, nil
+	case *object.Array:
+		return it, nil
+	case *object.Map:
+		it.keys = iterable.Keys
+		return it, nil
+	case *object.String:
+		if variables == 2 {
+			break
+		}
+		it.runes = []rune(iterable.Value) //one character at a time (not one byte at a time)
+		return it, nil
+	default:
+		return nil, fmt.Errorf("cannot iterate over %s", iterable.Type())
+	}
+
+	return nil, fmt.Errorf("cannot iterate over %s with two variables", iterable.Type()) //only arrays and maps have keys to go with the elements
+}
+
+//returns the next element and its key (the index in an array, the key in a map and nil for ranges and strings), or false once there are none left
+func (it *iterator) next() (object.Object, object.Object, bool) {
+	switch iterable := it.iterable.(type) {
+	case *object.Range:
+		if it.done {
+			return nil, nil, false
+		}
+		element := &object.Integer{Value: it.position}
+		if it.position == iterable.End { //stops the position from overflowing when the range ends at the largest int
+			it.done = true
+		} else {
+			it.position++
+		}
+		return nil, element, true
+
+	case *object.Array:
+		if it.position >= int64(len(iterable.Elements)) {
+			return nil, nil, false
+		}
+		key := &object.Integer{Value: it.position}
+		it.position++
+		return key, iterable.Elements[key.Value], true
+
+	case *object.Map:
+		if it.position >= int64(len(it.keys)) {
+			return nil, nil, false
+		}
+		pair := iterable.Pairs[it.keys[it.position]]
+		it.position++
+		return pair.Key, pair.Value, true
+
+	default: //strings
+		if it.position >= int64(len(it.runes)) {
+			return nil, nil, false
+		}
+		element := &object.String{Value: string(it.runes[it.position])}
+		it.position++
+		return nil, element, true
+	}
+}
+
+//%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% VM HELPER METHODS
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+	return False
+}
+
+//two objects are equal when they are of the same type and hold the same value
+func objectsEqual(left, right object.Object) bool {
+	if left.Type() != right.Type() {
+		return false
+	}
+
+	switch left := left.(type) {
+	case *object.Integer:
+		return left.Value == right.(*object.Integer).Value
+	case *object.Float:
+		return left.Value == right.(*object.Float).Value
+	case *object.String:
+		return left.Value == right.(*object.String).Value
+	default: //booleans, null and enum variants are unique, so pointer comparison is enough
+		return left == right
+	}
+}
+
+func functionName(fn *object.CompiledFunction) string {
+	if fn.Name == "" {
+		return "anonymous function"
+	}
+	return fn.Name
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
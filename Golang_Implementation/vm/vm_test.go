@@ -0,0 +1,328 @@
+package vm
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"../ast"
+	"../checker"
+	"../compiler"
+	"../evaluator"
+	"../lexer"
+	"../object"
+	"../parser"
+)
+
+//programs that have to end the same way whether they are evaluated or compiled and run on the vm: the same result, the same output and
+//the same error. The expected result is written the way the REPL would print it
+var sharedTests = []struct {
+	input    string
+	expected string
+}{
+	// Arithmetic and comparisons
+	{"1", "1"},
+	{"1 + 2 * 3 - 4 / 2", "5"},
+	{"(5 + 10 * 2 + 15 / 3) * 2 + -10", "50"},
+	{"-7 % 3", "-1"},
+	{"2.5 * 2.0 - 0.5", "4.5"},
+	{"-1.5", "-1.5"},
+	{"1 < 2", "true"},
+	{"2 <= 1", "false"},
+	{"1.5 >= 1.5", "true"},
+	{"1 = 1", "true"},
+	{`"squid" = "squid"`, "true"},
+	{`"a" != "b"`, "true"},
+	{"true != false", "true"},
+	{"!true", "false"},
+	{"!(1 > 2)", "true"},
+	{`"squid" + "script"`, "squidscript"},
+	{`let int n := 3; "${n} squids and ${n * 8} arms"`, "3 squids and 24 arms"},
+	{"1 / 0", "ERROR: division by zero"},
+	{"1 % 0", "ERROR: division by zero"},
+	{"1.0 / 0.0", "ERROR: division by zero"},
+
+	// and/or
+	{"true and false", "false"},
+	{"false or true", "true"},
+	{"let int[] xs := [1]; len(xs) > 1 and xs[1] = 2", "false"},
+	{"let int[] xs := [1]; len(xs) = 1 or xs[1] = 2", "true"},
+
+	// Variables and blocks
+	{"let int x := 5; let int y := x * 2; y + x", "15"},
+	{"let int x := 1; set x :+ 4; set x :* 3; set x :- 1; set x :/ 2; x", "7"},
+	{"let int x := 1; if (true) { let int x := 2; set x :+ 1; }; x", "1"},
+	{"let int x := 1; if (true) { set x := 10; }; x", "10"},
+	{"let int x := 1", "NULL"},
+	{"if (1 > 2) { 10 }", "null"},
+	{"if (1 < 2) { 10 } else { 20 }", "10"},
+	{"if (1 > 2) { 10 } else { 20 }", "20"},
+	{"let int x := if (false) { 1 } else { if (true) { 2 } else { 3 } }; x", "2"},
+
+	// Key statements
+	{"let int n := 2; key (n) { lock 1 { 10 } lock 2 { 20 } lock else { 30 } }", "20"},
+	{"let int n := 5; key (n) { lock 1 { 10 } lock n > 3 { 40 } lock else { 30 } }", "40"},
+	{"let int n := 9; key (n) { lock 1 { 10 } lock else { 30 } }", "30"},
+	{"let int x := 1; let int y := 1; key (x, y) { lock x = y { 1 } lock else { 2 } }", "1"},
+	{"let int x := 1; let int y := 2; key (x, y) { lock 1, 3 { 1 } lock 1, 2 { 2 } lock else { 3 } }", "2"},
+	{`let string s := "b"; let int r := 0; key (s) { lock "a" { set r := 1; } lock "b" { set r := 2; } }; r`, "2"},
+
+	// Loops
+	{"let int sum := 0; for i in 1..10 { set sum :+ i; }; sum", "55"},
+	{"let int sum := 0; for i in 5..1 { set sum :+ i; }; sum", "0"},
+	{"let int sum := 0; for x in [1, 2, 3] { set sum :+ x * x; }; sum", "14"},
+	{"let int sum := 0; for i, x in [10, 20, 30] { set sum :+ i * x; }; sum", "80"},
+	{`let string out := ""; for c in "squid" { set out := c + out; }; out`, "diuqs"},
+	{`let map[string]int m := {"a": 1, "b": 2}; let string ks := ""; for k in m { set ks :+ k; }; ks`, "ab"},
+	{`let map[string]int m := {"a": 1, "b": 2}; let int sum := 0; for k, v in m { set sum :+ v; }; sum`, "3"},
+	{"let int i := 0; while (i < 10) { set i :+ 1; }; i", "10"},
+	{"let int i := 0; while (true) { set i :+ 1; if (i = 5) { break; } }; i", "5"},
+	{"let int sum := 0; for i in 1..10 { if (i % 2 = 0) { continue; } set sum :+ i; }; sum", "25"},
+	{"let int n := 0; for i in 1..3 { for j in 1..3 { if (j = 2) { break; } set n :+ 1; } }; n", "3"},
+	{"let int i := 0; while (i < 3) { let int j := i * 2; set i :+ 1; }; i", "3"},
+	{"for i in 1..3 { i }", "NULL"},
+
+	// Arrays and maps
+	{"[1, 2 * 2, 3 + 3]", "[1, 4, 6]"},
+	{"let int[] xs := [1, 2, 3]; xs[0] + xs[2]", "4"},
+	{"[1, 2, 3, 4][1..2]", "[2, 3]"},
+	{"[1, 2, 3][1..0]", "[]"},
+	{"[1, 2, 3][3]", "ERROR: index out of range [3] with length 3"},
+	{"[1, 2, 3][2..3]", "ERROR: slice bounds out of range [2..3] with length 3"},
+	{"let int[] xs := [1, 2]; set xs[1] :+ 5; xs", "[1, 7]"},
+	{`{"a": 1, "b": 2, "a": 3}`, "{a: 3, b: 2}"},
+	{`let map[string]int m := {"a": 1}; set m["b"] := 2; set m["a"] :+ 10; m`, "{a: 11, b: 2}"},
+	{`let map[string]int m := {"a": 1}; m["z"]`, "ERROR: key not found: z"},
+	{`let map[int]string m := {1: "one"}; m[1]`, "one"},
+	{"1..5", "1..5"},
+
+	// Structs and enums
+	{"struct Point { int x; int y }; let Point p := Point{x: 1, y: 2}; p", "Point{x: 1, y: 2}"},
+	{"struct Point { int x; int y }; let Point p := Point{y: 2, x: 1}; set p.x :+ 10; p.x * p.y", "22"},
+	{"struct Point { int x; int y }; let Point p := Point{x: 1, y: 2}; let Point q := p; set q.y := 5; p", "Point{x: 1, y: 5}"},
+	{"struct Point { int x; int y }; let Point p := Point{x:-1, y:-(2 * 3)}; p", "Point{x: -1, y: -6}"},
+	{"enum Month { Jan, Feb }; Month.Feb", "Month.Feb"},
+	{"enum Month { Jan, Feb }; let Month m := Month.Jan; m = Month.Jan", "true"},
+	{"enum Month { Jan, Feb }; let Month m := Month.Feb; key (m) { lock Month.Jan { 1 } lock Month.Feb { 2 } }", "2"},
+	{`enum Month { Jan, Feb }; let map[Month]string names := {Month.Jan: "january"}; names[Month.Jan]`, "january"},
+
+	// Functions and closures
+	{"func add(int a, int b) returns int { return a + b; }; add(2, 3)", "5"},
+	{"let func(int) returns int double := func(int x) returns int { return x * 2; }; double(21)", "42"},
+	{"let int x := 1; func bump() { set x :+ 1; }; bump(); bump(); x", "3"},
+	{"func nothing() { return; }; nothing()", "null"},
+	{"func early(int n) returns int { for i in 1..n { if (i = 3) { return i * 100; } }; return 0; }; early(10)", "300"},
+	{"func early(int n) returns int { while (true) { key (n) { lock 1 { return 10; } lock else { return 20; } } } }; early(2)", "20"},
+	{`
+func newAdder(int x) returns func(int) returns int {
+  return func(int y) returns int { return x + y; };
+}
+let func(int) returns int addTwo := newAdder(2);
+addTwo(3)`, "5"},
+	{`
+func counter() returns func() returns int {
+  let int count := 0;
+  return func() returns int {
+    set count :+ 1;
+    return count;
+  };
+}
+let func() returns int next := counter();
+let func() returns int other := counter();
+next();
+next();
+other();
+next()`, "3"},
+	{`
+func outer() returns int {
+  let int x := 1;
+  func middle() returns int {
+    func inner() returns int { set x :+ 10; return x; }
+    return inner();
+  }
+  middle();
+  return x + middle();
+}
+outer()`, "32"},
+	{`
+func makeGetters() returns map[int]func() returns int {
+  let map[int]func() returns int fs := {};
+  for i in 1..3 {
+    let int j := i * 10;
+    func get() returns int { return i + j; }
+    set fs[i] := get;
+  }
+  return fs;
+}
+let map[int]func() returns int fs := makeGetters();
+fs[1]() + fs[3]()`, "44"},
+	{`
+func fib(int n) returns int {
+  if (n < 2) { return n; }
+  return fib(n - 1) + fib(n - 2);
+}
+fib(15)`, "610"},
+	{`
+func count(int n) returns int {
+  if (n = 0) { return 0; }
+  let int a := n;
+  let int b := a - 1;
+  return 1 + count(b);
+}
+count(9999)`, "9999"}, //10000 calls deep, just at the limit
+	{`
+func count(int n) returns int {
+  if (n = 0) { return 0; }
+  return 1 + count(n - 1);
+}
+count(10000)`, "ERROR: stack overflow"}, //one call too many
+	{"return 5; 10", "5"},
+
+	// Builtins
+	{`len("squid") + len([1, 2]) + len({1: 2})`, "8"},
+	{"int(2.9) + int(-2.9)", "0"},
+	{"float(3) / 2.0", "1.5"},
+	{`print("squids:", 2, 1.5, true); print("${1 + 1}!")`, "null"},
+	{"let int len := 3; len", "3"},
+	{"int(1e300 * 1e300)", "ERROR: cannot convert +Inf to int, it is out of range"},
+	{"args()", "[]"},
+}
+
+func TestMatchesEvaluator(t *testing.T) {
+	for _, tt := range sharedTests {
+		program := parse(t, tt.input)
+		if program == nil {
+			continue
+		}
+
+		evaluated, evaluatorOutput := runEvaluator(program)
+		if evaluated != tt.expected {
+			t.Errorf("evaluator gave the wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated)
+		}
+
+		ran, vmOutput := runVM(t, program)
+		if ran != tt.expected {
+			t.Errorf("vm gave the wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, ran)
+		}
+		if vmOutput != evaluatorOutput {
+			t.Errorf("vm printed something else than the evaluator for %q. evaluator=%q, vm=%q", tt.input, evaluatorOutput, vmOutput)
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	program := parse(t, `let int n := 2; print("squids:", n, n > 1); for i in 1..2 { print("${i}!") }`)
+
+	_, output := runVM(t, program)
+	if output != "squids: 2 true\n1!\n2!\n" {
+		t.Errorf("wrong output. got=%q", output)
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	program := parse(t, "func forever(int n) returns int { return forever(n + 1); }; forever(0)")
+
+	result, _ := runVM(t, program)
+	if result != "ERROR: stack overflow" {
+		t.Errorf("expected a stack overflow. got=%q", result)
+	}
+}
+
+func TestErrorLine(t *testing.T) {
+	program := parse(t, "let int[] xs := [1, 2];\nfunc at(int i) returns int {\n  return xs[i];\n}\nat(1) + at(5)")
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	machine := New(comp.Bytecode())
+	if err := machine.Run(); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if machine.Line() != 3 { //the line of the function the error happened in, not the line of the call
+		t.Errorf("wrong line for the error. want=3, got=%d", machine.Line())
+	}
+}
+
+//fibonacci is what we compare the speed of the evaluator and the vm on, since it is nothing but calls, arithmetic and branches
+const fibonacci = `
+func fib(int n) returns int {
+  if (n < 2) { return n; }
+  return fib(n - 1) + fib(n - 2);
+}
+fib(20)`
+
+func BenchmarkFibonacci(b *testing.B) {
+	program := parser.New(lexer.New(fibonacci)).ParseProgram()
+
+	b.Run("evaluator", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			evaluator.Eval(program, object.NewEnvironment())
+		}
+	})
+
+	b.Run("vm", func(b *testing.B) {
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			b.Fatalf("compiler error: %s", err)
+		}
+		bytecode := comp.Bytecode()
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := New(bytecode).Run(); err != nil {
+				b.Fatalf("vm error: %s", err)
+			}
+		}
+	})
+}
+
+//parses and checks input, failing the test if it isn't a valid program (the vm, like the compiler, only ever runs programs that checked)
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Errorf("parser errors for %q: %v", input, p.Errors())
+		return nil
+	}
+	if diagnostics := checker.New().Check(program); diagnostics.HasErrors() {
+		t.Errorf("checker errors for %q: %v", input, diagnostics.Errors())
+		return nil
+	}
+	return program
+}
+
+//returns what the program ended with and what it printed when it is evaluated
+func runEvaluator(program *ast.Program) (string, string) {
+	var out bytes.Buffer
+	object.Output = &out
+	defer func() { object.Output = os.Stdout }()
+
+	return inspect(evaluator.Eval(program, object.NewEnvironment())), out.String()
+}
+
+//returns what the program ended with and what it printed when it is compiled and run on the vm
+func runVM(t *testing.T, program *ast.Program) (string, string) {
+	var out bytes.Buffer
+	object.Output = &out
+	defer func() { object.Output = os.Stdout }()
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		return "ERROR: " + err.Error(), out.String()
+	}
+	return inspect(machine.Result()), out.String()
+}
+
+//nil, which is what a program ends with when its last statement doesn't have a value, is written as NULL
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "NULL"
+	}
+	return obj.Inspect()
+}